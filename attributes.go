@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// AttributedUser is a User that exposes Attributes to Conditions as `user.*`.
+type AttributedUser interface {
+	User
+	Attributes() Attributes
+}
+
+// AttributedResource is a Resource that exposes Attributes to Conditions as
+// `resource.*`.
+type AttributedResource interface {
+	Resource
+	Attributes() Attributes
+}
+
+// attributesKey is a non-simple type for request Attributes in a context.Context.
+type attributesKey struct{}
+
+// WithAttributes returns a Context carrying the given request Attributes, merged over any
+// it already carries. They are exposed to Conditions as `request.*`. Sessions set
+// `request.ip` and `request.method` on their own.
+func WithAttributes(ctx context.Context, attrs Attributes) context.Context {
+	merged := make(Attributes)
+	for k, v := range RequestAttributes(ctx) {
+		merged[k] = v
+	}
+
+	for k, v := range attrs {
+		merged[k] = v
+	}
+
+	return context.WithValue(ctx, attributesKey{}, merged)
+}
+
+// RequestAttributes returns the request Attributes carried by the Context.
+func RequestAttributes(ctx context.Context) (attrs Attributes) {
+	attrs, _ = ctx.Value(attributesKey{}).(Attributes)
+	return
+}
+
+// ConditionAttributes builds the Attributes that Conditions are evaluated against for the
+// given User and Resource: `request.*` from the Context, with `request.time` defaulting
+// to now, `user.id` and `resource.kind`, `resource.id` along with any Attributes the User
+// or Resource expose on their own.
+func ConditionAttributes(ctx context.Context, user User, resource Resource) (
+	attrs Attributes,
+) {
+	request := Attributes{"time": time.Now()}
+	for k, v := range RequestAttributes(ctx) {
+		request[k] = v
+	}
+
+	u := Attributes{}
+	if attributed, ok := user.(AttributedUser); ok {
+		for k, v := range attributed.Attributes() {
+			u[k] = v
+		}
+	}
+
+	u["id"] = user.GetID()
+
+	res := Attributes{}
+	if attributed, ok := resource.(AttributedResource); ok {
+		for k, v := range attributed.Attributes() {
+			res[k] = v
+		}
+	}
+
+	res["kind"] = string(resource.Kind())
+	res["id"] = string(resource.Identifier())
+
+	attrs = Attributes{
+		"request":  request,
+		"user":     u,
+		"resource": res,
+	}
+
+	return
+}
@@ -0,0 +1,69 @@
+// Package authtest provides in-memory implementations of auth's repositories, so that
+// code depending on `auth.Groups`, `auth.Teams`, `auth.Invitations` and Sessions can be
+// unit-tested without a database.
+package authtest
+
+import (
+	"context"
+
+	"github.com/angadn/auth"
+)
+
+// Fixture holds the in-memory repositories that `Install` configured `auth` with, for
+// seeding and inspection.
+type Fixture struct {
+	Users       *Repository
+	Groups      auth.GroupRepository
+	Teams       auth.TeamRepository
+	Invitations auth.InvitationRepository
+}
+
+// Install configures `auth` to refer fresh in-memory repositories, seeded with the given
+// Users. As the configuration is global, tests that Install shouldn't run in parallel.
+func Install(users ...auth.User) (fixture Fixture) {
+	fixture.Users = NewRepository(users...)
+	fixture.Groups = NewGroupMemoryRepositoryImpl()
+	fixture.Teams = NewTeamMemoryRepositoryImpl()
+	fixture.Invitations = NewInvitationMemoryRepositoryImpl()
+
+	auth.WithRepository(fixture.Users)
+	auth.WithGroupRepository(fixture.Groups)
+	auth.WithTeamRepository(fixture.Teams)
+	auth.WithInvitationRepository(fixture.Invitations)
+	return
+}
+
+// Seed adds the User to the Fixture's Repository, and grants her the given Roles.
+func (fixture Fixture) Seed(ctx context.Context, user auth.User, roles ...auth.Role) (
+	err error,
+) {
+	fixture.Users.Put(user)
+	for _, role := range roles {
+		if err = fixture.Groups.Add(ctx, user, role); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// SeedTeam adds the given Users to the Fixture's Repository and to the Team, and grants
+// the Team the given Roles.
+func (fixture Fixture) SeedTeam(
+	ctx context.Context, team auth.TeamID, roles auth.Roles, members ...auth.User,
+) (err error) {
+	for _, member := range members {
+		fixture.Users.Put(member)
+		if err = fixture.Teams.AddMember(ctx, team, auth.PrincipalOf(member)); err != nil {
+			return
+		}
+	}
+
+	for _, role := range roles {
+		if err = fixture.Groups.AddTeam(ctx, team, role); err != nil {
+			return
+		}
+	}
+
+	return
+}
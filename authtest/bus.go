@@ -0,0 +1,66 @@
+package authtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/angadn/auth"
+)
+
+// InvalidationBus implements auth.InvalidationBus in memory, so that GroupCaches within
+// a test can stand in for the replicas of a service. Invalidations are delivered to
+// every subscriber before Publish returns. It is safe for concurrent use.
+type InvalidationBus struct {
+	mu          sync.RWMutex
+	subscribers map[int]auth.Invalidator
+	next        int
+}
+
+// NewInvalidationBus is a constructor for InvalidationBus.
+func NewInvalidationBus() (bus *InvalidationBus) {
+	bus = new(InvalidationBus)
+	bus.subscribers = make(map[int]auth.Invalidator)
+	return
+}
+
+// Publish the Invalidations to every subscriber.
+func (bus *InvalidationBus) Publish(ctx context.Context, invs []auth.Invalidation) (
+	err error,
+) {
+	bus.mu.RLock()
+	subscribers := make([]auth.Invalidator, 0, len(bus.subscribers))
+	for _, invalidator := range bus.subscribers {
+		subscribers = append(subscribers, invalidator)
+	}
+
+	bus.mu.RUnlock()
+
+	for _, invalidator := range subscribers {
+		invalidator.Invalidate(invs...)
+	}
+
+	return
+}
+
+// Subscribe the Invalidator to the Invalidations published from now on, until the
+// Context is done.
+func (bus *InvalidationBus) Subscribe(
+	ctx context.Context, invalidator auth.Invalidator,
+) (err error) {
+	bus.mu.Lock()
+	id := bus.next
+	bus.next++
+	bus.subscribers[id] = invalidator
+	bus.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+
+		delete(bus.subscribers, id)
+	}()
+
+	return
+}
@@ -0,0 +1,759 @@
+package authtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/angadn/auth"
+)
+
+// assignmentKey is the unique key of an assignment, which `Add` depends upon to be
+// idempotent, just like the unique key of the `groups` table.
+type assignmentKey struct {
+	tenant    auth.TenantID
+	kind      auth.ResourceKind
+	id        auth.ResourceID
+	name      auth.RoleName
+	principal auth.Principal
+}
+
+// resourceKey identifies a Resource of a tenant independently of it's implementation.
+type resourceKey struct {
+	tenant auth.TenantID
+	kind   auth.ResourceKind
+	id     auth.ResourceID
+}
+
+// principalKey identifies a Principal of a tenant.
+type principalKey struct {
+	tenant    auth.TenantID
+	principal auth.Principal
+}
+
+func keyOf(
+	tenant auth.TenantID, principal auth.Principal, role auth.Role,
+) (key assignmentKey) {
+	key.tenant = tenant
+	key.kind = role.Resource.Kind()
+	key.id = role.Resource.Identifier()
+	key.name = role.Name
+	key.principal = principal
+	return
+}
+
+func (key assignmentKey) resource() (res resourceKey) {
+	res.tenant = key.tenant
+	res.kind = key.kind
+	res.id = key.id
+	return
+}
+
+func (key assignmentKey) principalKey() (p principalKey) {
+	p.tenant = key.tenant
+	p.principal = key.principal
+	return
+}
+
+func resourceKeyOf(tenant auth.TenantID, resource auth.Resource) (res resourceKey) {
+	res.tenant = tenant
+	res.kind = resource.Kind()
+	res.id = resource.Identifier()
+	return
+}
+
+// GroupMemoryRepository implements GroupRepository in memory, with the same semantics
+// as GroupMySQLRepository, including it's isolation of tenants. It is safe for concurrent
+// use, and indexes it's assignments by Principal and by Resource.
+type GroupMemoryRepository struct {
+	mu          sync.RWMutex
+	assignments map[assignmentKey]auth.Assignment
+	createdAt   map[assignmentKey]time.Time
+	byPrincipal map[principalKey]map[assignmentKey]bool
+	byResource  map[resourceKey]map[assignmentKey]bool
+}
+
+// NewGroupMemoryRepositoryImpl is a constructor for GroupMemoryRepository.
+func NewGroupMemoryRepositoryImpl() (repo auth.GroupRepository) {
+	memRepo := new(GroupMemoryRepository)
+	memRepo.assignments = make(map[assignmentKey]auth.Assignment)
+	memRepo.createdAt = make(map[assignmentKey]time.Time)
+	memRepo.byPrincipal = make(map[principalKey]map[assignmentKey]bool)
+	memRepo.byResource = make(map[resourceKey]map[assignmentKey]bool)
+	repo.GroupRepositoryImpl = memRepo
+	return
+}
+
+// Add a User to a Group for the given Role. See GroupMySQLRepository.Add.
+func (repo *GroupMemoryRepository) Add(
+	ctx context.Context, user auth.User, role auth.Role, opts ...auth.GrantOption,
+) (err error) {
+	err = repo.assign(ctx, auth.PrincipalOf(user), role, auth.EffectAllow, auth.NewGrant(opts...))
+	return
+}
+
+// AddTeam adds a Team to a Group for the given Role.
+func (repo *GroupMemoryRepository) AddTeam(
+	ctx context.Context, team auth.TeamID, role auth.Role, opts ...auth.GrantOption,
+) (err error) {
+	err = repo.assign(ctx, team.Principal(), role, auth.EffectAllow, auth.NewGrant(opts...))
+	return
+}
+
+// Deny explicitly bars a User from the given Role. See GroupMySQLRepository.Deny.
+func (repo *GroupMemoryRepository) Deny(
+	ctx context.Context, user auth.User, role auth.Role, opts ...auth.GrantOption,
+) (err error) {
+	err = repo.assign(ctx, auth.PrincipalOf(user), role, auth.EffectDeny, auth.NewGrant(opts...))
+	return
+}
+
+// assign upserts a Principal's assignment to the Group for the given Role.
+func (repo *GroupMemoryRepository) assign(
+	ctx context.Context,
+	principal auth.Principal,
+	role auth.Role,
+	effect auth.Effect,
+	grant auth.Grant,
+) (err error) {
+	if err = grant.Validate(); err != nil {
+		return
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	tenant := auth.TenantFromContext(ctx)
+	if effect == auth.EffectDeny {
+		if err = repo.guard(tenant, []auth.Member{{Role: role, Principal: principal}}); err != nil {
+			return
+		}
+	}
+
+	repo.put(keyOf(tenant, principal, role), role, effect, grant)
+	return
+}
+
+// put upserts an assignment and it's index entries. The caller must hold the lock.
+func (repo *GroupMemoryRepository) put(
+	key assignmentKey, role auth.Role, effect auth.Effect, grant auth.Grant,
+) {
+	if _, ok := repo.assignments[key]; !ok {
+		repo.createdAt[key] = time.Now()
+	}
+
+	repo.assignments[key] = auth.Assignment{
+		Role:      role,
+		Principal: key.principal,
+		Effect:    effect,
+		Grant:     grant,
+	}
+
+	if repo.byPrincipal[key.principalKey()] == nil {
+		repo.byPrincipal[key.principalKey()] = make(map[assignmentKey]bool)
+	}
+
+	if repo.byResource[key.resource()] == nil {
+		repo.byResource[key.resource()] = make(map[assignmentKey]bool)
+	}
+
+	repo.byPrincipal[key.principalKey()][key] = true
+	repo.byResource[key.resource()][key] = true
+}
+
+// AddMany adds the Users and Teams of every Group to it's Role, atomically. See
+// GroupMySQLRepository.AddMany.
+func (repo *GroupMemoryRepository) AddMany(
+	ctx context.Context, groups []auth.Group, opts ...auth.GrantOption,
+) (err error) {
+	grant := auth.NewGrant(opts...)
+	if err = grant.Validate(); err != nil {
+		return
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	tenant := auth.TenantFromContext(ctx)
+	for _, m := range auth.MembersOf(groups) {
+		repo.put(keyOf(tenant, m.Principal, m.Role), m.Role, auth.EffectAllow, grant)
+	}
+
+	return
+}
+
+// Delete a Role for a User, removing either a grant or a denial.
+func (repo *GroupMemoryRepository) Delete(
+	ctx context.Context, user auth.User, role auth.Role,
+) (err error) {
+	err = repo.remove(ctx, []auth.Member{{Role: role, Principal: auth.PrincipalOf(user)}})
+	return
+}
+
+// DeleteTeam deletes a Role for a Team.
+func (repo *GroupMemoryRepository) DeleteTeam(
+	ctx context.Context, team auth.TeamID, role auth.Role,
+) (err error) {
+	err = repo.remove(ctx, []auth.Member{{Role: role, Principal: team.Principal()}})
+	return
+}
+
+// DeleteMany deletes the Roles of the Users and Teams of every Group, atomically.
+func (repo *GroupMemoryRepository) DeleteMany(ctx context.Context, groups []auth.Group) (
+	err error,
+) {
+	err = repo.remove(ctx, auth.MembersOf(groups))
+	return
+}
+
+// remove deletes the assignments of Members to their Roles atomically, unless that
+// would empty a required Role.
+func (repo *GroupMemoryRepository) remove(ctx context.Context, members []auth.Member) (
+	err error,
+) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	tenant := auth.TenantFromContext(ctx)
+	if err = repo.guard(tenant, members); err != nil {
+		return
+	}
+
+	for _, m := range members {
+		repo.unassign(keyOf(tenant, m.Principal, m.Role))
+	}
+
+	return
+}
+
+// Transfer a Role from one User to another atomically. See
+// GroupMySQLRepository.Transfer.
+func (repo *GroupMemoryRepository) Transfer(
+	ctx context.Context, role auth.Role, from auth.User, to auth.User,
+) (err error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var (
+		tenant = auth.TenantFromContext(ctx)
+		held   bool
+	)
+
+	for _, principal := range repo.holders(tenant, role) {
+		if held = principal == auth.PrincipalOf(from); held {
+			break
+		}
+	}
+
+	if !held {
+		err = fmt.Errorf("%w: %s of %s", auth.ErrNotMember, from.GetID(), role.Name)
+		return
+	}
+
+	if from.GetID() == to.GetID() {
+		return
+	}
+
+	var (
+		removed = auth.Member{Role: role, Principal: auth.PrincipalOf(from)}
+		added   = auth.Member{Role: role, Principal: auth.PrincipalOf(to)}
+	)
+
+	if err = repo.guard(tenant, []auth.Member{removed}, added); err != nil {
+		return
+	}
+
+	repo.put(keyOf(tenant, added.Principal, role), role, auth.EffectAllow, auth.Grant{})
+	repo.unassign(keyOf(tenant, removed.Principal, role))
+	return
+}
+
+// guard checks that removing some Members, and adding others, wouldn't empty a required
+// Role. The caller must hold the lock.
+func (repo *GroupMemoryRepository) guard(
+	tenant auth.TenantID, removals []auth.Member, additions ...auth.Member,
+) (err error) {
+	err = auth.Required.Guard(removals, func(role auth.Role) ([]auth.Principal, error) {
+		principals := repo.holders(tenant, role)
+		for _, m := range additions {
+			if m.Key() == (auth.Member{Role: role, Principal: m.Principal}).Key() {
+				principals = append(principals, m.Principal)
+			}
+		}
+
+		return principals, nil
+	})
+
+	return
+}
+
+// holders lists the Principals with active grants of the Role. The caller must hold the
+// lock.
+func (repo *GroupMemoryRepository) holders(tenant auth.TenantID, role auth.Role) (
+	principals []auth.Principal,
+) {
+	now := time.Now()
+	for key := range repo.byResource[resourceKeyOf(tenant, role.Resource)] {
+		a := repo.assignments[key]
+		if key.name == role.Name && a.Effect == auth.EffectAllow && a.Grant.IsActiveAt(now) {
+			principals = append(principals, key.principal)
+		}
+	}
+
+	return
+}
+
+// Replace the grants upon a Resource with the desired Groups, atomically. See
+// GroupMySQLRepository.Replace.
+func (repo *GroupMemoryRepository) Replace(
+	ctx context.Context, resource auth.Resource, desired []auth.Group,
+) (err error) {
+	var (
+		tenant  = auth.TenantFromContext(ctx)
+		members = auth.MembersOf(desired)
+		wanted  = make(map[assignmentKey]auth.Role)
+	)
+
+	for _, m := range members {
+		if m.Role.Resource.Kind() != resource.Kind() ||
+			m.Role.Resource.Identifier() != resource.Identifier() {
+			err = fmt.Errorf("%w: %s upon %s:%s", auth.ErrForeignRole, m.Role.Name,
+				m.Role.Resource.Kind(), m.Role.Resource.Identifier())
+			return
+		}
+
+		wanted[keyOf(tenant, m.Principal, m.Role)] = m.Role
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var stale, added []auth.Member
+	for key := range repo.byResource[resourceKeyOf(tenant, resource)] {
+		if _, ok := wanted[key]; !ok && repo.assignments[key].Effect == auth.EffectAllow {
+			stale = append(stale, auth.Member{
+				Role:      repo.assignments[key].Role,
+				Principal: key.principal,
+			})
+		}
+	}
+
+	for key, role := range wanted {
+		added = append(added, auth.Member{Role: role, Principal: key.principal})
+	}
+
+	if err = repo.guard(tenant, stale, added...); err != nil {
+		return
+	}
+
+	for _, m := range stale {
+		repo.unassign(keyOf(tenant, m.Principal, m.Role))
+	}
+
+	now := time.Now()
+	for key, role := range wanted {
+		a, ok := repo.assignments[key]
+		if !ok || a.Effect != auth.EffectAllow || !a.Grant.IsActiveAt(now) {
+			repo.put(key, role, auth.EffectAllow, auth.Grant{})
+		}
+	}
+
+	return
+}
+
+// unassign deletes an assignment and it's index entries. The caller must hold the lock.
+func (repo *GroupMemoryRepository) unassign(key assignmentKey) {
+	delete(repo.assignments, key)
+	delete(repo.createdAt, key)
+	if keys := repo.byPrincipal[key.principalKey()]; keys != nil {
+		if delete(keys, key); len(keys) == 0 {
+			delete(repo.byPrincipal, key.principalKey())
+		}
+	}
+
+	if keys := repo.byResource[key.resource()]; keys != nil {
+		if delete(keys, key); len(keys) == 0 {
+			delete(repo.byResource, key.resource())
+		}
+	}
+}
+
+// Free deletes all Groups attached to the given Resource.
+func (repo *GroupMemoryRepository) Free(ctx context.Context, resource auth.Resource) (
+	err error,
+) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for key := range repo.byResource[resourceKeyOf(auth.TenantFromContext(ctx), resource)] {
+		repo.unassign(key)
+	}
+
+	return
+}
+
+// Find a Group of Users and Teams that are granted a given Role, leaving out denials
+// and assignments outside their bounds. Users and Teams are listed in order of their IDs.
+func (repo *GroupMemoryRepository) Find(ctx context.Context, role auth.Role) (
+	group auth.Group, err error,
+) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	now := time.Now()
+	group.Role = role
+	for _, a := range repo.onResource(ctx, role.Resource) {
+		if a.Role.Name != role.Name || a.Effect != auth.EffectAllow ||
+			!a.Grant.IsActiveAt(now) {
+			continue
+		}
+
+		switch a.Principal.Type {
+		case auth.TeamPrincipal:
+			group.Teams = append(group.Teams, auth.TeamID(a.Principal.ID))
+		default:
+			group.Users = append(group.Users, a.Principal.ID)
+		}
+	}
+
+	sort.Strings(group.Users)
+	sort.Slice(group.Teams, func(i, j int) bool {
+		return group.Teams[i] < group.Teams[j]
+	})
+
+	return
+}
+
+// Denials lists the Users explicitly denied each Role upon the given Resource, as one
+// Group per RoleName in order of their names.
+func (repo *GroupMemoryRepository) Denials(ctx context.Context, resource auth.Resource) (
+	groups []auth.Group, err error,
+) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var (
+		now    = time.Now()
+		byName = make(map[auth.RoleName][]string)
+		names  []string
+	)
+
+	for _, a := range repo.onResource(ctx, resource) {
+		if a.Effect != auth.EffectDeny || !a.Grant.IsActiveAt(now) {
+			continue
+		}
+
+		if _, ok := byName[a.Role.Name]; !ok {
+			names = append(names, string(a.Role.Name))
+		}
+
+		byName[a.Role.Name] = append(byName[a.Role.Name], a.Principal.ID)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		users := byName[auth.RoleName(name)]
+		sort.Strings(users)
+		groups = append(groups, auth.Group{
+			Role:  auth.NewRole(auth.RoleName(name), resource),
+			Users: users,
+		})
+	}
+
+	return
+}
+
+// onResource lists the assignments upon the given Resource in the tenant of the Context.
+// The caller must hold the lock.
+func (repo *GroupMemoryRepository) onResource(
+	ctx context.Context, resource auth.Resource,
+) (assignments []auth.Assignment) {
+	for key := range repo.byResource[resourceKeyOf(auth.TenantFromContext(ctx), resource)] {
+		assignments = append(assignments, repo.assignments[key])
+	}
+
+	return
+}
+
+// IsUserInAny checks whether the given User, or any Team she transitively belongs to, has
+// one or more of the given Roles. See GroupMySQLRepository.IsUserInAny.
+func (repo *GroupMemoryRepository) IsUserInAny(
+	ctx context.Context, user auth.User, roles auth.Roles,
+) (ok bool, err error) {
+	if ok = auth.IsMasterIn(ctx, user); ok {
+		return
+	}
+
+	if len(roles) == 0 {
+		return
+	}
+
+	var assignments []auth.Assignment
+	if assignments, err = repo.Assignments(ctx, user, roles); err != nil {
+		return
+	}
+
+	ok, err = auth.Decide(ctx, user, roles, assignments)
+	return
+}
+
+// Assignments lists the active Assignments of the given User, and of every Team she
+// transitively belongs to, to any of the given Roles or to the same Roles upon AllOf
+// their Resources' kinds.
+func (repo *GroupMemoryRepository) Assignments(
+	ctx context.Context, user auth.User, roles auth.Roles,
+) (assignments []auth.Assignment, err error) {
+	var principals []auth.Principal
+	if principals, err = principalsOf(ctx, user); err != nil {
+		return
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var (
+		now    = time.Now()
+		tenant = auth.TenantFromContext(ctx)
+	)
+
+	for _, principal := range principals {
+		for key := range repo.byPrincipal[principalKey{tenant, principal}] {
+			a := repo.assignments[key]
+			if !a.Grant.IsActiveAt(now) {
+				continue
+			}
+
+			for _, role := range roles {
+				if a.AppliesTo(role) {
+					assignments = append(assignments, a)
+					break
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// Resources lists all of the Resources of a given ResourceKind that a User has access to
+// via any Role granted to her or to any Team she transitively belongs to. See
+// GroupMySQLRepository.Resources.
+func (repo *GroupMemoryRepository) Resources(
+	ctx context.Context, kind auth.ResourceKind, user auth.User,
+) (roles auth.Roles, err error) {
+	var principals []auth.Principal
+	if principals, err = principalsOf(ctx, user); err != nil {
+		return
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var (
+		now    = time.Now()
+		tenant = auth.TenantFromContext(ctx)
+		seen   = make(map[assignmentKey]bool)
+	)
+
+	for _, principal := range principals {
+		for key := range repo.byPrincipal[principalKey{tenant, principal}] {
+			a := repo.assignments[key]
+			if key.kind != kind || a.Effect != auth.EffectAllow ||
+				!a.Grant.IsActiveAt(now) {
+				continue
+			}
+
+			role := assignmentKey{kind: key.kind, id: key.id, name: key.name}
+			if seen[role] {
+				continue
+			}
+
+			seen[role] = true
+			roles = append(roles, a.Role)
+		}
+	}
+
+	return
+}
+
+// FindPage lists a page of the Users and Teams that are granted any Role upon the given
+// Resource. See GroupMySQLRepository.FindPage.
+func (repo *GroupMemoryRepository) FindPage(
+	ctx context.Context, resource auth.Resource, page auth.Page,
+) (members auth.MemberPage, err error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var (
+		now     = time.Now()
+		listed  []auth.Member
+		cursors []auth.Cursor
+	)
+
+	for key := range repo.byResource[resourceKeyOf(auth.TenantFromContext(ctx), resource)] {
+		a := repo.assignments[key]
+		if a.Effect != auth.EffectAllow || !a.Grant.IsActiveAt(now) ||
+			!page.HasRoleName(key.name) {
+			continue
+		}
+
+		listed = append(listed, auth.Member{
+			Role:      auth.NewRole(key.name, resource),
+			Principal: key.principal,
+			CreatedAt: repo.createdAt[key],
+		})
+
+		cursors = append(cursors, auth.NewCursor(
+			repo.createdAt[key],
+			string(key.name),
+			string(key.principal.Type),
+			key.principal.ID,
+		))
+	}
+
+	var indexes []int
+	if indexes, members.Next, err = paginate(page, cursors); err != nil {
+		return
+	}
+
+	members.Total = len(listed)
+	for _, i := range indexes {
+		members.Members = append(members.Members, listed[i])
+	}
+
+	return
+}
+
+// ResourcesPage lists a page of the Roles that a User holds upon Resources of a given
+// ResourceKind. See GroupMySQLRepository.ResourcesPage.
+func (repo *GroupMemoryRepository) ResourcesPage(
+	ctx context.Context, kind auth.ResourceKind, user auth.User, page auth.Page,
+) (roles auth.ResourcePage, err error) {
+	var principals []auth.Principal
+	if principals, err = principalsOf(ctx, user); err != nil {
+		return
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var (
+		now    = time.Now()
+		tenant = auth.TenantFromContext(ctx)
+		listed auth.Roles
+		seen   = make(map[assignmentKey]bool)
+	)
+
+	for _, principal := range principals {
+		for key := range repo.byPrincipal[principalKey{tenant, principal}] {
+			a := repo.assignments[key]
+			if key.kind != kind || a.Effect != auth.EffectAllow ||
+				!a.Grant.IsActiveAt(now) || !page.HasRoleName(key.name) {
+				continue
+			}
+
+			// A Role granted both to the User and to her Teams is listed once.
+			role := assignmentKey{kind: key.kind, id: key.id, name: key.name}
+			if !seen[role] {
+				seen[role] = true
+				listed = append(listed, a.Role)
+			}
+		}
+	}
+
+	cursors := make([]auth.Cursor, len(listed))
+	for i, role := range listed {
+		cursors[i] = auth.NewCursor(
+			time.Time{}, string(role.Resource.Identifier()), string(role.Name),
+		)
+	}
+
+	var indexes []int
+	if indexes, roles.Next, err = paginate(page, cursors); err != nil {
+		return
+	}
+
+	roles.Total = len(listed)
+	for _, i := range indexes {
+		roles.Roles = append(roles.Roles, listed[i])
+	}
+
+	return
+}
+
+// paginate sorts the Cursors of a listing's entries in the Page's Order, returning the
+// indexes of the entries in the Page along with the Cursor of the next one.
+func paginate(page auth.Page, cursors []auth.Cursor) (
+	indexes []int, next string, err error,
+) {
+	var after *auth.Cursor
+	if page.Cursor != "" && len(cursors) > 0 {
+		var cursor auth.Cursor
+		if cursor, err = auth.ParseCursor(page.Cursor, len(cursors[0].Key)); err != nil {
+			return
+		}
+
+		after = &cursor
+	}
+
+	for i := range cursors {
+		if after == nil || after.Precedes(cursors[i], page.Order) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		return cursors[indexes[i]].Precedes(cursors[indexes[j]], page.Order)
+	})
+
+	if size := page.Size(); len(indexes) > size {
+		indexes = indexes[:size]
+		next = cursors[indexes[size-1]].String()
+	}
+
+	return
+}
+
+// PurgeExpired deletes every assignment of the tenant whose expiry has passed, returning
+// the number of assignments deleted.
+func (repo *GroupMemoryRepository) PurgeExpired(ctx context.Context) (
+	n int64, err error,
+) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var (
+		now    = time.Now()
+		tenant = auth.TenantFromContext(ctx)
+	)
+
+	for key, a := range repo.assignments {
+		if key.tenant == tenant && !a.Grant.ExpiresAt.IsZero() &&
+			!now.Before(a.Grant.ExpiresAt) {
+			repo.unassign(key)
+			n++
+		}
+	}
+
+	return
+}
+
+// principalsOf lists the Principal of the given User and of every Team she transitively
+// belongs to, per `auth.Teams`.
+func principalsOf(ctx context.Context, user auth.User) (
+	principals []auth.Principal, err error,
+) {
+	var teams []auth.TeamID
+	if teams, err = auth.Teams.Resolve(ctx, user); err != nil {
+		return
+	}
+
+	principals = append(principals, auth.PrincipalOf(user))
+	for _, team := range teams {
+		principals = append(principals, team.Principal())
+	}
+
+	return
+}
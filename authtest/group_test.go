@@ -0,0 +1,53 @@
+package authtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/authtest"
+	"github.com/angadn/auth/grouptest"
+)
+
+func TestGroupMemoryRepository(t *testing.T) {
+	grouptest.Run(t, func(t *testing.T) auth.GroupRepository {
+		return authtest.NewGroupMemoryRepositoryImpl()
+	})
+}
+
+func TestGroupCache(t *testing.T) {
+	grouptest.Run(t, func(t *testing.T) auth.GroupRepository {
+		return auth.NewGroupCacheImpl(authtest.NewGroupMemoryRepositoryImpl())
+	})
+}
+
+// coreRepository implements none of the optional interfaces of GroupRepositoryImpl.
+type coreRepository struct {
+	auth.GroupRepositoryImpl
+}
+
+func TestGroupCoreRepository(t *testing.T) {
+	groups := auth.GroupRepository{
+		GroupRepositoryImpl: coreRepository{authtest.NewGroupMemoryRepositoryImpl()},
+	}
+
+	if _, err := groups.PurgeExpired(context.Background()); !errors.Is(
+		err, auth.ErrUnsupported,
+	) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+
+	grouptest.Run(t, func(t *testing.T) auth.GroupRepository {
+		return auth.GroupRepository{
+			GroupRepositoryImpl: coreRepository{authtest.NewGroupMemoryRepositoryImpl()},
+		}
+	})
+}
+
+func TestInvalidationBus(t *testing.T) {
+	bus := authtest.NewInvalidationBus()
+	grouptest.RunBus(t, func(t *testing.T) auth.InvalidationBus {
+		return bus
+	})
+}
@@ -0,0 +1,128 @@
+package authtest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/angadn/auth"
+)
+
+// InvitationMemoryRepository implements InvitationRepository in memory, with the same
+// semantics as InvitationMySQLRepository, including it's isolation of tenants. It is
+// safe for concurrent use, but unlike our SQL repositories, a failure to grant an
+// accepted Invitation's Role doesn't restore it.
+type InvitationMemoryRepository struct {
+	mu          sync.Mutex
+	invitations map[invitationKey]auth.Invitation
+}
+
+// invitationKey identifies an Invitation of a tenant.
+type invitationKey struct {
+	tenant auth.TenantID
+	id     auth.InvitationID
+}
+
+// NewInvitationMemoryRepositoryImpl is a constructor for InvitationMemoryRepository.
+func NewInvitationMemoryRepositoryImpl() (repo auth.InvitationRepository) {
+	memRepo := new(InvitationMemoryRepository)
+	memRepo.invitations = make(map[invitationKey]auth.Invitation)
+	repo.InvitationRepositoryImpl = memRepo
+	return
+}
+
+// Put persists a pending Invitation.
+func (repo *InvitationMemoryRepository) Put(
+	ctx context.Context, invitation auth.Invitation,
+) (err error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.invitations[invitationKey{auth.TenantFromContext(ctx), invitation.ID}] = invitation
+	return
+}
+
+// Take deletes a pending Invitation and returns it, if it's still pending.
+func (repo *InvitationMemoryRepository) Take(
+	ctx context.Context, id auth.InvitationID,
+) (invitation auth.Invitation, ok bool, err error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := invitationKey{auth.TenantFromContext(ctx), id}
+	if invitation, ok = repo.invitations[key]; ok {
+		delete(repo.invitations, key)
+	}
+
+	return
+}
+
+// Pending lists the Invitations to Roles upon a Resource that are yet to be accepted,
+// declined, revoked or expire, oldest first.
+func (repo *InvitationMemoryRepository) Pending(
+	ctx context.Context, resource auth.Resource,
+) (invitations []auth.Invitation, err error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var (
+		tenant = auth.TenantFromContext(ctx)
+		now    = time.Now()
+	)
+
+	for key, invitation := range repo.invitations {
+		if key.tenant != tenant ||
+			invitation.Role.Resource.Kind() != resource.Kind() ||
+			invitation.Role.Resource.Identifier() != resource.Identifier() ||
+			!now.Before(invitation.ExpiresAt) {
+			continue
+		}
+
+		invitations = append(invitations, invitation)
+	}
+
+	sort.Slice(invitations, func(i, j int) bool {
+		if !invitations[i].CreatedAt.Equal(invitations[j].CreatedAt) {
+			return invitations[i].CreatedAt.Before(invitations[j].CreatedAt)
+		}
+
+		return invitations[i].ID < invitations[j].ID
+	})
+
+	return
+}
+
+// Revoke a pending Invitation, so that it's token can no longer be accepted. Revoke is
+// an idempotent action.
+func (repo *InvitationMemoryRepository) Revoke(
+	ctx context.Context, id auth.InvitationID,
+) (err error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.invitations, invitationKey{auth.TenantFromContext(ctx), id})
+	return
+}
+
+// PurgeExpired deletes the Invitations that have expired, returning how many were.
+func (repo *InvitationMemoryRepository) PurgeExpired(ctx context.Context) (
+	n int64, err error,
+) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var (
+		tenant = auth.TenantFromContext(ctx)
+		now    = time.Now()
+	)
+
+	for key, invitation := range repo.invitations {
+		if key.tenant == tenant && !now.Before(invitation.ExpiresAt) {
+			delete(repo.invitations, key)
+			n++
+		}
+	}
+
+	return
+}
@@ -0,0 +1,88 @@
+package authtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/angadn/auth"
+)
+
+// User is a plain implementation of auth.User for tests.
+type User struct {
+	ID         string
+	Secret     string
+	Email      string
+	IsVerified bool
+}
+
+// NewUser is a convenience-constructor for a verified User without a secret.
+func NewUser(id string) (user User) {
+	user.ID = id
+	user.IsVerified = true
+	return
+}
+
+// GetID implements auth.User.
+func (user User) GetID() string {
+	return user.ID
+}
+
+// GetSecret implements auth.User.
+func (user User) GetSecret() string {
+	return user.Secret
+}
+
+// GetEmail implements auth.EmailUser.
+func (user User) GetEmail() string {
+	return user.Email
+}
+
+// GetIsVerified implements auth.User.
+func (user User) GetIsVerified() bool {
+	return user.IsVerified
+}
+
+// Repository implements auth.Repository in memory. It is safe for concurrent use.
+type Repository struct {
+	mu    sync.RWMutex
+	users map[string]auth.User
+}
+
+// NewRepository is a constructor for Repository, seeded with the given Users.
+func NewRepository(users ...auth.User) (repo *Repository) {
+	repo = new(Repository)
+	repo.users = make(map[string]auth.User)
+	repo.Put(users...)
+	return
+}
+
+// Put adds the given Users to the Repository, replacing any with the same IDs.
+func (repo *Repository) Put(users ...auth.User) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, user := range users {
+		repo.users[user.GetID()] = user
+	}
+}
+
+// Remove deletes the Users with the given IDs from the Repository.
+func (repo *Repository) Remove(ids ...string) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, id := range ids {
+		delete(repo.users, id)
+	}
+}
+
+// FindAuthUser implements auth.Repository.
+func (repo *Repository) FindAuthUser(ctx context.Context, id string) (
+	user auth.User, ok bool, err error,
+) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	user, ok = repo.users[id]
+	return
+}
@@ -0,0 +1,134 @@
+package authtest
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/angadn/auth"
+)
+
+// TeamMemoryRepository implements TeamRepository in memory, with the same semantics as
+// TeamMySQLRepository, including it's isolation of tenants. It is safe for concurrent use.
+type TeamMemoryRepository struct {
+	mu      sync.RWMutex
+	members map[teamKey]map[auth.Principal]bool
+	teamsOf map[principalKey]map[auth.TeamID]bool
+}
+
+// teamKey identifies a Team of a tenant.
+type teamKey struct {
+	tenant auth.TenantID
+	team   auth.TeamID
+}
+
+// NewTeamMemoryRepositoryImpl is a constructor for TeamMemoryRepository.
+func NewTeamMemoryRepositoryImpl() (repo auth.TeamRepository) {
+	memRepo := new(TeamMemoryRepository)
+	memRepo.members = make(map[teamKey]map[auth.Principal]bool)
+	memRepo.teamsOf = make(map[principalKey]map[auth.TeamID]bool)
+	repo.TeamRepositoryImpl = memRepo
+	return
+}
+
+// AddMember adds a User or another Team to a Team. AddMember is an idempotent action.
+func (repo *TeamMemoryRepository) AddMember(
+	ctx context.Context, team auth.TeamID, member auth.Principal,
+) (err error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var (
+		tenant = auth.TenantFromContext(ctx)
+		t      = teamKey{tenant, team}
+		m      = principalKey{tenant, member}
+	)
+
+	if repo.members[t] == nil {
+		repo.members[t] = make(map[auth.Principal]bool)
+	}
+
+	if repo.teamsOf[m] == nil {
+		repo.teamsOf[m] = make(map[auth.TeamID]bool)
+	}
+
+	repo.members[t][member] = true
+	repo.teamsOf[m][team] = true
+	return
+}
+
+// RemoveMember removes a User or another Team from a Team.
+func (repo *TeamMemoryRepository) RemoveMember(
+	ctx context.Context, team auth.TeamID, member auth.Principal,
+) (err error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var (
+		tenant = auth.TenantFromContext(ctx)
+		t      = teamKey{tenant, team}
+		m      = principalKey{tenant, member}
+	)
+
+	if members := repo.members[t]; members != nil {
+		if delete(members, member); len(members) == 0 {
+			delete(repo.members, t)
+		}
+	}
+
+	if teams := repo.teamsOf[m]; teams != nil {
+		if delete(teams, team); len(teams) == 0 {
+			delete(repo.teamsOf, m)
+		}
+	}
+
+	return
+}
+
+// Members lists the direct members of a Team, in order of their types and IDs.
+func (repo *TeamMemoryRepository) Members(ctx context.Context, team auth.TeamID) (
+	members []auth.Principal, err error,
+) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	for member := range repo.members[teamKey{auth.TenantFromContext(ctx), team}] {
+		members = append(members, member)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Type != members[j].Type {
+			return members[i].Type < members[j].Type
+		}
+
+		return members[i].ID < members[j].ID
+	})
+
+	return
+}
+
+// TeamsOf lists the Teams that any of the given Principals directly belong to.
+func (repo *TeamMemoryRepository) TeamsOf(
+	ctx context.Context, members ...auth.Principal,
+) (teams []auth.TeamID, err error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var (
+		tenant = auth.TenantFromContext(ctx)
+		seen   = make(map[auth.TeamID]bool)
+	)
+
+	for _, member := range members {
+		for team := range repo.teamsOf[principalKey{tenant, member}] {
+			if seen[team] {
+				continue
+			}
+
+			seen[team] = true
+			teams = append(teams, team)
+		}
+	}
+
+	return
+}
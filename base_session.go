@@ -66,20 +66,23 @@ type baseSession struct {
 	cancelFunc context.CancelFunc
 }
 
+// init the Session's Context, which memoizes parent lookups for the rest of the request
+// per WithResolverMemo.
 func (session *baseSession) init(ctx context.Context) {
-	session.ctx, session.cancelFunc = context.WithCancel(ctx)
+	session.ctx, session.cancelFunc = context.WithCancel(WithResolverMemo(ctx))
 }
 
-func (session *baseSession) auth(id string, secret string, opts ...Option) (
-	ctx context.Context, err error,
-) {
+func (session *baseSession) auth(
+	tenant TenantID, id string, secret string, opts ...Option,
+) (ctx context.Context, err error) {
 	var (
 		ok   bool
 		user User
 	)
 
+	session.ctx = WithTenant(session.ctx, tenant)
 	if isRBACSet {
-		if ok = IsMaster(id, secret); ok {
+		if ok = IsMaster(id, secret) || IsTenantMaster(tenant, id, secret); ok {
 			ctx = context.WithValue(session.ctx, UserKey, userImpl{
 				id: id, secret: secret,
 			})
@@ -111,6 +114,20 @@ func (session *baseSession) auth(id string, secret string, opts ...Option) (
 		return
 	}
 
+	// The tenant is named by the client, so only it's own Users may act within it, lest
+	// any other pick it's scope by naming it. Masters were let through above.
+	if tenantUser, isTenantUser := user.(TenantUser); isTenantUser {
+		if tenant != "" && tenant != tenantUser.GetTenantID() {
+			err = ErrInvalidUserCredentials
+			return
+		}
+
+		session.ctx = WithTenant(session.ctx, tenantUser.GetTenantID())
+	} else if tenant != "" {
+		err = ErrInvalidUserCredentials
+		return
+	}
+
 	for _, o := range opts {
 		if o == IgnoreUnverified {
 			return
@@ -0,0 +1,370 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/angadn/tabular"
+)
+
+// ErrForeignRole when a Role passed to `Replace` is upon another Resource than the one
+// being replaced.
+var ErrForeignRole = fmt.Errorf("role upon another resource")
+
+// batchRows is the most rows that a single multi-row statement writes, which keeps it's
+// parameters within the limits of every database we support.
+const batchRows = 64
+
+// Members lists the Users and Teams of the Group as Members of it's Role.
+func (group Group) Members() (members []Member) {
+	for _, user := range group.Users {
+		members = append(members, Member{
+			Role:      group.Role,
+			Principal: Principal{Type: UserPrincipal, ID: user},
+		})
+	}
+
+	for _, team := range group.Teams {
+		members = append(members, Member{
+			Role:      group.Role,
+			Principal: team.Principal(),
+		})
+	}
+
+	return
+}
+
+// MemberKey identifies a Member independently of the implementation of it's Resource.
+type MemberKey struct {
+	Kind      ResourceKind
+	ID        ResourceID
+	Name      RoleName
+	Principal Principal
+}
+
+// Key of the Member.
+func (m Member) Key() (key MemberKey) {
+	key.Kind = m.Role.Resource.Kind()
+	key.ID = m.Role.Resource.Identifier()
+	key.Name = m.Role.Name
+	key.Principal = m.Principal
+	return
+}
+
+// MembersOf lists the Members of the given Groups, leaving out any listed twice.
+func MembersOf(groups []Group) (members []Member) {
+	seen := make(map[MemberKey]bool)
+	for _, group := range groups {
+		for _, m := range group.Members() {
+			if seen[m.Key()] {
+				continue
+			}
+
+			seen[m.Key()] = true
+			members = append(members, m)
+		}
+	}
+
+	return
+}
+
+// BulkRepositoryImpl is implemented by GroupRepositoryImpls that can write many Groups
+// in a single transaction.
+type BulkRepositoryImpl interface {
+	AddMany(ctx context.Context, groups []Group, opts ...GrantOption) (err error)
+	DeleteMany(ctx context.Context, groups []Group) (err error)
+	Replace(ctx context.Context, resource Resource, desired []Group) (err error)
+}
+
+// AddMany adds the Users and Teams of every Group to it's Role in a single transaction,
+// failing with ErrUnsupported unless it's GroupRepositoryImpl is a BulkRepositoryImpl.
+func (repo GroupRepository) AddMany(
+	ctx context.Context, groups []Group, opts ...GrantOption,
+) (err error) {
+	impl, ok := repo.GroupRepositoryImpl.(BulkRepositoryImpl)
+	if !ok {
+		err = fmt.Errorf("%w: AddMany", ErrUnsupported)
+		return
+	}
+
+	err = impl.AddMany(ctx, groups, opts...)
+	return
+}
+
+// DeleteMany deletes the Roles of the Users and Teams of every Group in a single
+// transaction, failing with ErrUnsupported unless it's GroupRepositoryImpl is a
+// BulkRepositoryImpl.
+func (repo GroupRepository) DeleteMany(ctx context.Context, groups []Group) (err error) {
+	impl, ok := repo.GroupRepositoryImpl.(BulkRepositoryImpl)
+	if !ok {
+		err = fmt.Errorf("%w: DeleteMany", ErrUnsupported)
+		return
+	}
+
+	err = impl.DeleteMany(ctx, groups)
+	return
+}
+
+// Replace the grants upon a Resource with the desired Groups in a single transaction,
+// failing with ErrUnsupported unless it's GroupRepositoryImpl is a BulkRepositoryImpl.
+func (repo GroupRepository) Replace(
+	ctx context.Context, resource Resource, desired []Group,
+) (err error) {
+	impl, ok := repo.GroupRepositoryImpl.(BulkRepositoryImpl)
+	if !ok {
+		err = fmt.Errorf("%w: Replace", ErrUnsupported)
+		return
+	}
+
+	err = impl.Replace(ctx, resource, desired)
+	return
+}
+
+// AddMany adds the Users and Teams of every Group to it's Role, just like `Add` does, in
+// a single transaction. The GrantOptions apply to all of them.
+func (repo *groupSQLRepository) AddMany(
+	ctx context.Context, groups []Group, opts ...GrantOption,
+) (err error) {
+	err = repo.transact(ctx, func(txRepo *groupSQLRepository) error {
+		return txRepo.assignMany(ctx, MembersOf(groups), EffectAllow, NewGrant(opts...))
+	})
+
+	return
+}
+
+// DeleteMany deletes the Roles of the Users and Teams of every Group, just like `Delete`
+// does, in a single transaction. It fails with ErrLastMember, deleting none of them, if
+// it would empty a required Role.
+func (repo *groupSQLRepository) DeleteMany(ctx context.Context, groups []Group) (
+	err error,
+) {
+	err = repo.transact(ctx, func(txRepo *groupSQLRepository) error {
+		return txRepo.remove(ctx, MembersOf(groups))
+	})
+
+	return
+}
+
+// Replace the grants upon a Resource with the desired Groups in a single transaction,
+// adding the Users and Teams that don't hold their Roles and deleting the grants of any
+// that aren't desired. Grants that are desired and active are left as they are, bounds
+// included. Denials of undesired Roles are left alone, while a desired Role that's denied
+// is granted instead, just as `Add` does. Every desired Role must be upon the Resource,
+// or Replace fails with ErrForeignRole, and it fails with ErrLastMember if it would empty
+// a required Role.
+func (repo *groupSQLRepository) Replace(
+	ctx context.Context, resource Resource, desired []Group,
+) (err error) {
+	members := MembersOf(desired)
+	if err = checkResource(resource, members); err != nil {
+		return
+	}
+
+	err = repo.transact(ctx, func(txRepo *groupSQLRepository) (err error) {
+		var current map[MemberKey]bool
+		if current, err = txRepo.grants(ctx, resource); err != nil {
+			return
+		}
+
+		var (
+			wanted  = make(map[MemberKey]bool)
+			missing []Member
+			stale   []Member
+		)
+
+		for _, m := range members {
+			wanted[m.Key()] = true
+			if !current[m.Key()] {
+				missing = append(missing, m)
+			}
+		}
+
+		for key := range current {
+			if !wanted[key] {
+				stale = append(stale, Member{
+					Role:      NewRole(key.Name, resource),
+					Principal: key.Principal,
+				})
+			}
+		}
+
+		// Missing Members are added first, so that they count towards required Roles.
+		if err = txRepo.assignMany(ctx, missing, EffectAllow, Grant{}); err != nil {
+			return
+		}
+
+		err = txRepo.remove(ctx, stale)
+		return
+	})
+
+	return
+}
+
+// checkResource checks that every Member holds a Role upon the given Resource.
+func checkResource(resource Resource, members []Member) (err error) {
+	for _, m := range members {
+		if m.Role.Resource.Kind() != resource.Kind() ||
+			m.Role.Resource.Identifier() != resource.Identifier() {
+			err = fmt.Errorf(
+				"%w: %s upon %s:%s rather than %s:%s",
+				ErrForeignRole,
+				m.Role.Name,
+				m.Role.Resource.Kind(),
+				m.Role.Resource.Identifier(),
+				resource.Kind(),
+				resource.Identifier(),
+			)
+
+			return
+		}
+	}
+
+	return
+}
+
+// grants lists the keys of every grant upon the Resource, mapped to whether it's active.
+func (repo *groupSQLRepository) grants(ctx context.Context, resource Resource) (
+	grants map[MemberKey]bool, err error,
+) {
+	var rows *sql.Rows
+	if rows, err = repo.query(ctx, table.Selection(
+		"SELECT %s FROM `groups` WHERE `groups`.`tenant_id` = ? AND `groups`.`resource_kind` = ? AND `groups`.`resource_id` = ? AND `groups`.`effect` = ?",
+	),
+		string(TenantFromContext(ctx)),
+		string(resource.Kind()),
+		string(resource.Identifier()),
+		string(EffectAllow),
+	); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	now := time.Now()
+	grants = make(map[MemberKey]bool)
+	for rows.Next() {
+		var (
+			key   = MemberKey{Kind: resource.Kind(), ID: resource.Identifier()}
+			grant Grant
+		)
+
+		if err = newScanner(
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&key.Name,
+			&key.Principal.ID,
+			&key.Principal.Type,
+			&tabular.Scapegoat{},
+			timestamp{&grant.NotBefore},
+			timestamp{&grant.ExpiresAt},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+		).Scan(rows); err != nil {
+			return
+		}
+
+		grants[key] = grant.IsActiveAt(now)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// assignMany upserts the assignments of Members to their Roles with multi-row
+// statements.
+func (repo *groupSQLRepository) assignMany(
+	ctx context.Context, members []Member, effect Effect, grant Grant,
+) (err error) {
+	if err = grant.Validate(); err != nil {
+		return
+	}
+
+	// Times are bound rather than left to CURRENT_TIMESTAMP, so that they're stored in
+	// the same form as the Cursors of `FindPage` are bound in.
+	var (
+		tenant = string(TenantFromContext(ctx))
+		now    = time.Now().UTC()
+	)
+
+	for len(members) > 0 {
+		batch := members
+		if len(batch) > batchRows {
+			batch = batch[:batchRows]
+		}
+
+		members = members[len(batch):]
+
+		var args []interface{}
+		for _, m := range batch {
+			args = append(
+				args,
+				tenant,
+				string(m.Role.Resource.Kind()),
+				string(m.Role.Resource.Identifier()),
+				string(m.Role.Name),
+				m.Principal.ID,
+				string(m.Principal.Type),
+				string(effect),
+				nullTime(grant.NotBefore),
+				nullTime(grant.ExpiresAt),
+				nullString(grant.Condition),
+				now,
+				now,
+			)
+		}
+
+		if _, err = repo.exec(ctx, table.BatchInsertion(
+			"%s "+repo.dialect.upsert(
+				groupKey, "effect", "not_before", "expires_at", "condition", "updated_at",
+			),
+			len(batch),
+		), args...); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// unassignMany deletes the assignments of Members to their Roles with multi-row
+// statements.
+func (repo *groupSQLRepository) unassignMany(ctx context.Context, members []Member) (
+	err error,
+) {
+	for len(members) > 0 {
+		batch := members
+		if len(batch) > batchRows {
+			batch = batch[:batchRows]
+		}
+
+		members = members[len(batch):]
+
+		args := []interface{}{string(TenantFromContext(ctx))}
+		for _, m := range batch {
+			args = append(
+				args,
+				string(m.Role.Resource.Kind()),
+				string(m.Role.Resource.Identifier()),
+				string(m.Role.Name),
+				m.Principal.ID,
+				string(m.Principal.Type),
+			)
+		}
+
+		if _, err = repo.exec(ctx, fmt.Sprintf(
+			"DELETE FROM `groups` WHERE `tenant_id` = ? AND (%s)",
+			strings.TrimRight(strings.Repeat(
+				"(`resource_kind` = ? AND `resource_id` = ? AND `role_name` = ? AND `user_id` = ? AND `principal_type` = ?) OR ", len(batch),
+			), " OR "),
+		), args...); err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// InvalidationBus carries the Invalidations of writes to Groups between the processes of
+// a service, such as it's replicas, each of which keeps a GroupCache of it's own. Every
+// GroupCache configured with CacheBus publishes the Invalidations of it's writes, and
+// evicts whatever it's subscribed to.
+//
+// Staleness is bounded as follows. A decision is evicted from every subscribed
+// GroupCache once the Invalidations of the write are delivered, which takes the
+// InvalidationBus' latency. Writes within a transaction publish them as they're made and
+// again once it commits with `Commit`; committed otherwise, a replica that cached a
+// decision between the write and it's commit keeps it until the TTL. Should they be
+// lost, such as when a subscription drops, the InvalidationBus flushes the subscriber
+// once it notices, and again once it's resubscribed, as decisions may have been cached
+// from stale reads meanwhile. Either way, no decision is ever kept for longer than it's
+// GroupCache's TTL, so that's the bound when everything else fails.
+type InvalidationBus interface {
+	// Publish the Invalidations to every subscriber, including any of this process.
+	Publish(ctx context.Context, invs []Invalidation) (err error)
+
+	// Subscribe the Invalidator to the Invalidations published from now on, until the
+	// Context is done. It returns once subscribed, delivering them in the background,
+	// and fails if the subscription can't be made.
+	Subscribe(ctx context.Context, invalidator Invalidator) (err error)
+}
+
+// EncodeInvalidations encodes Invalidations as a message of an InvalidationBus.
+func EncodeInvalidations(invs []Invalidation) (msg []byte, err error) {
+	msg, err = json.Marshal(invs)
+	return
+}
+
+// DecodeInvalidations decodes Invalidations from a message of an InvalidationBus.
+func DecodeInvalidations(msg []byte) (invs []Invalidation, err error) {
+	err = json.Unmarshal(msg, &invs)
+	return
+}
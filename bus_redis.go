@@ -0,0 +1,356 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// DefaultRedisChannel is the channel that a RedisInvalidationBus publishes to unless
+	// configured otherwise with RedisChannel.
+	DefaultRedisChannel = "auth:invalidations"
+
+	// DefaultRedisTimeout bounds dialing Redis and each command to it unless configured
+	// otherwise with RedisTimeout.
+	DefaultRedisTimeout = 5 * time.Second
+
+	// DefaultRedisHealthInterval is how often a RedisInvalidationBus pings Redis over
+	// each subscription unless configured otherwise with RedisHealthInterval.
+	DefaultRedisHealthInterval = 15 * time.Second
+
+	// maxRedisIdle bounds the idle connections that Invalidations are published over.
+	maxRedisIdle = 4
+
+	// maxRedisBackoff bounds the delay between attempts to resubscribe.
+	maxRedisBackoff = 5 * time.Second
+)
+
+// RedisBusConfig configures a RedisInvalidationBus.
+type RedisBusConfig struct {
+	// Addr of the Redis server, as a host and port.
+	Addr string
+
+	// Username that Redis is authenticated as with it's ACLs, along with the Password,
+	// unless it's empty.
+	Username string
+
+	// Password that Redis is authenticated with, unless it's empty.
+	Password string
+
+	// TLS configures connecting to Redis over TLS, unless it's nil.
+	TLS *tls.Config
+
+	// Channel that Invalidations are published to. Services sharing a Redis server but
+	// not their Groups should each have one of their own.
+	Channel string
+
+	// Timeout bounds dialing Redis and each command to it.
+	Timeout time.Duration
+
+	// HealthInterval is how often each subscription pings Redis. A subscription that
+	// hears nothing back for twice as long is deemed lost.
+	HealthInterval time.Duration
+
+	// OnError is called with the errors of subscriptions, which are retried in the
+	// background, unless it's nil.
+	OnError func(err error)
+}
+
+// RedisBusOption is a functional option to configure a RedisInvalidationBus.
+type RedisBusOption func(config *RedisBusConfig)
+
+// RedisUsername authenticates with Redis as the given ACL user, along with RedisPassword.
+func RedisUsername(username string) RedisBusOption {
+	return func(config *RedisBusConfig) {
+		config.Username = username
+	}
+}
+
+// RedisPassword authenticates with Redis.
+func RedisPassword(password string) RedisBusOption {
+	return func(config *RedisBusConfig) {
+		config.Password = password
+	}
+}
+
+// RedisTLS connects to Redis over TLS, per the given configuration.
+func RedisTLS(tlsConfig *tls.Config) RedisBusOption {
+	return func(config *RedisBusConfig) {
+		config.TLS = tlsConfig
+	}
+}
+
+// RedisChannel names the channel that Invalidations are published to.
+func RedisChannel(channel string) RedisBusOption {
+	return func(config *RedisBusConfig) {
+		config.Channel = channel
+	}
+}
+
+// RedisTimeout bounds dialing Redis and each command to it.
+func RedisTimeout(timeout time.Duration) RedisBusOption {
+	return func(config *RedisBusConfig) {
+		config.Timeout = timeout
+	}
+}
+
+// RedisHealthInterval sets how often each subscription pings Redis, and so how soon a
+// lost one is noticed.
+func RedisHealthInterval(interval time.Duration) RedisBusOption {
+	return func(config *RedisBusConfig) {
+		config.HealthInterval = interval
+	}
+}
+
+// RedisOnError reports the errors of subscriptions, which are retried in the
+// background.
+func RedisOnError(onError func(err error)) RedisBusOption {
+	return func(config *RedisBusConfig) {
+		config.OnError = onError
+	}
+}
+
+// RedisInvalidationBus implements InvalidationBus with Redis' pub/sub, using the
+// `github.com/gomodule/redigo` client. Invalidations are published over a pool of
+// connections, while each subscription holds one of it's own and pings Redis every
+// HealthInterval, so a lost one is noticed within twice that, upon which it's Invalidator
+// is flushed, and flushed again once it's resubscribed. It is safe for concurrent use.
+type RedisInvalidationBus struct {
+	config RedisBusConfig
+	pool   *redis.Pool
+}
+
+// NewRedisInvalidationBus is a constructor for RedisInvalidationBus. It doesn't dial
+// Redis until it's first used.
+func NewRedisInvalidationBus(addr string, opts ...RedisBusOption) (
+	bus *RedisInvalidationBus,
+) {
+	bus = new(RedisInvalidationBus)
+	bus.config = RedisBusConfig{
+		Addr:           addr,
+		Channel:        DefaultRedisChannel,
+		Timeout:        DefaultRedisTimeout,
+		HealthInterval: DefaultRedisHealthInterval,
+	}
+
+	for _, opt := range opts {
+		opt(&bus.config)
+	}
+
+	if bus.config.Timeout <= 0 {
+		bus.config.Timeout = DefaultRedisTimeout
+	}
+
+	if bus.config.HealthInterval <= 0 {
+		bus.config.HealthInterval = DefaultRedisHealthInterval
+	}
+
+	bus.pool = &redis.Pool{
+		DialContext: bus.dial,
+		MaxIdle:     maxRedisIdle,
+		IdleTimeout: bus.config.HealthInterval,
+	}
+
+	return
+}
+
+// Publish the Invalidations to every subscriber of the channel, retrying once upon a
+// fresh connection should a pooled one have been lost.
+func (bus *RedisInvalidationBus) Publish(ctx context.Context, invs []Invalidation) (
+	err error,
+) {
+	if len(invs) == 0 {
+		return
+	}
+
+	var msg []byte
+	if msg, err = EncodeInvalidations(invs); err != nil {
+		return
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = bus.publish(ctx, msg); err == nil {
+			return
+		}
+
+		var redisErr redis.Error
+		if errors.As(err, &redisErr) || ctx.Err() != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// publish the message over a pooled connection.
+func (bus *RedisInvalidationBus) publish(ctx context.Context, msg []byte) (err error) {
+	var conn redis.Conn
+	if conn, err = bus.pool.GetContext(ctx); err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	_, err = redis.DoWithTimeout(
+		conn, bus.config.Timeout, "PUBLISH", bus.config.Channel, msg,
+	)
+
+	return
+}
+
+// Subscribe the Invalidator to the channel until the Context is done.
+func (bus *RedisInvalidationBus) Subscribe(
+	ctx context.Context, invalidator Invalidator,
+) (err error) {
+	var conn redis.PubSubConn
+	if conn, err = bus.subscribe(ctx); err != nil {
+		return
+	}
+
+	go bus.listen(ctx, conn, invalidator)
+	return
+}
+
+// Close the connections that Invalidations are published over. Subscriptions end with
+// their Contexts.
+func (bus *RedisInvalidationBus) Close() (err error) {
+	err = bus.pool.Close()
+	return
+}
+
+// listen delivers the Invalidations of a subscription to the Invalidator, resubscribing
+// whenever it's lost, until the Context is done.
+func (bus *RedisInvalidationBus) listen(
+	ctx context.Context, conn redis.PubSubConn, invalidator Invalidator,
+) {
+	for {
+		err := bus.receive(ctx, conn, invalidator)
+		conn.Close()
+		if ctx.Err() != nil {
+			return
+		}
+
+		bus.report(fmt.Errorf("redis subscription lost: %w", err))
+		invalidator.Flush()
+
+		for backoff := 100 * time.Millisecond; ; {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			if conn, err = bus.subscribe(ctx); err == nil {
+				break
+			}
+
+			bus.report(fmt.Errorf("redis resubscription failed: %w", err))
+			if backoff *= 2; backoff > maxRedisBackoff {
+				backoff = maxRedisBackoff
+			}
+		}
+
+		// Decisions may have been cached from stale reads while unsubscribed.
+		invalidator.Flush()
+	}
+}
+
+// receive the messages of a subscription until it fails or the Context is done, pinging
+// Redis every HealthInterval.
+func (bus *RedisInvalidationBus) receive(
+	ctx context.Context, conn redis.PubSubConn, invalidator Invalidator,
+) (err error) {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(bus.config.HealthInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-ticker.C:
+				conn.Ping("")
+			}
+		}
+	}()
+
+	for {
+		switch reply := conn.ReceiveWithTimeout(2 * bus.config.HealthInterval).(type) {
+		case error:
+			err = reply
+			return
+		case redis.Message:
+			var invs []Invalidation
+			if invs, err = DecodeInvalidations(reply.Data); err != nil {
+				bus.report(fmt.Errorf("undecodable invalidations: %w", err))
+				invalidator.Flush()
+				continue
+			}
+
+			invalidator.Invalidate(invs...)
+		}
+	}
+}
+
+// subscribe dials Redis and subscribes to the channel, awaiting it's confirmation.
+func (bus *RedisInvalidationBus) subscribe(ctx context.Context) (
+	conn redis.PubSubConn, err error,
+) {
+	var c redis.Conn
+	if c, err = bus.dial(ctx); err != nil {
+		return
+	}
+
+	conn = redis.PubSubConn{Conn: c}
+	if err = conn.Subscribe(bus.config.Channel); err != nil {
+		conn.Close()
+		return
+	}
+
+	for {
+		switch reply := conn.ReceiveWithTimeout(bus.config.Timeout).(type) {
+		case error:
+			err = reply
+			conn.Close()
+			return
+		case redis.Subscription:
+			if reply.Kind == "subscribe" && reply.Channel == bus.config.Channel {
+				return
+			}
+		}
+	}
+}
+
+// dial Redis, over TLS and authenticating if configured to.
+func (bus *RedisInvalidationBus) dial(ctx context.Context) (conn redis.Conn, err error) {
+	conn, err = redis.DialContext(
+		ctx,
+		"tcp",
+		bus.config.Addr,
+		redis.DialConnectTimeout(bus.config.Timeout),
+		redis.DialWriteTimeout(bus.config.Timeout),
+		redis.DialUsername(bus.config.Username),
+		redis.DialPassword(bus.config.Password),
+		redis.DialUseTLS(bus.config.TLS != nil),
+		redis.DialTLSConfig(bus.config.TLS),
+	)
+
+	return
+}
+
+func (bus *RedisInvalidationBus) report(err error) {
+	if bus.config.OnError != nil {
+		bus.config.OnError(err)
+	}
+}
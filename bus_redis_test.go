@@ -0,0 +1,78 @@
+package auth_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/grouptest"
+)
+
+func TestRedisInvalidationBus(t *testing.T) {
+	srv := miniredis.RunT(t)
+	srv.RequireUserAuth("auth", "secret")
+
+	grouptest.RunBus(t, func(t *testing.T) auth.InvalidationBus {
+		bus := auth.NewRedisInvalidationBus(
+			srv.Addr(), auth.RedisUsername("auth"), auth.RedisPassword("secret"),
+		)
+
+		t.Cleanup(func() {
+			bus.Close()
+		})
+
+		return bus
+	})
+}
+
+// flushCounter is an Invalidator that counts it's flushes.
+type flushCounter struct {
+	flushes int32
+}
+
+func (counter *flushCounter) Invalidate(invs ...auth.Invalidation) {}
+
+func (counter *flushCounter) Flush() {
+	atomic.AddInt32(&counter.flushes, 1)
+}
+
+func TestRedisInvalidationBusResubscribes(t *testing.T) {
+	srv := miniredis.RunT(t)
+	bus := auth.NewRedisInvalidationBus(
+		srv.Addr(), auth.RedisHealthInterval(50*time.Millisecond),
+	)
+
+	t.Cleanup(func() {
+		bus.Close()
+	})
+
+	// The subscription ends with the test, rather than outliving it upon the Context.
+	subCtx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	counter := new(flushCounter)
+	if err := bus.Subscribe(subCtx, counter); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Close()
+	if err := srv.Restart(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flushed once the subscription is lost, and again once it's resubscribed.
+	for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(
+		&counter.flushes,
+	) < 2; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("flushed %d times, want 2", atomic.LoadInt32(&counter.flushes))
+		}
+	}
+
+	if n := srv.PubSubNumSub(auth.DefaultRedisChannel)[auth.DefaultRedisChannel]; n != 1 {
+		t.Errorf("expected a single subscriber once resubscribed, got %d", n)
+	}
+}
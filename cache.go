@@ -0,0 +1,681 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL is how long a GroupCache keeps the Assignments behind a decision
+	// unless configured otherwise with CacheTTL.
+	DefaultCacheTTL = 30 * time.Second
+
+	// DefaultNegativeCacheTTL is how long a GroupCache keeps a decision that found no
+	// Assignments at all unless configured otherwise with NegativeCacheTTL.
+	DefaultNegativeCacheTTL = 5 * time.Second
+
+	// DefaultCacheSize is the most decisions that a GroupCache keeps unless configured
+	// otherwise with CacheSize.
+	DefaultCacheSize = 10000
+)
+
+// CacheConfig configures a GroupCache.
+type CacheConfig struct {
+	// TTL bounds how long decisions are kept, and so how stale they may be when Groups
+	// change without passing through the GroupCache.
+	TTL time.Duration
+
+	// NegativeTTL is the TTL of decisions that found no Assignments at all, which are
+	// kept apart so that newly granted Roles are seen sooner. Negative caching is off
+	// unless it's positive.
+	NegativeTTL time.Duration
+
+	// Size is the most decisions that are kept, the least recently used of which are
+	// evicted first.
+	Size int
+
+	// Bus that the Invalidations of writes are published to, so that the GroupCaches of
+	// other processes evict them too, unless it's nil.
+	Bus InvalidationBus
+
+	// OnError is called with the errors of publishing to the Bus, unless it's nil.
+	OnError func(err error)
+}
+
+// CacheOption is a functional option to configure a GroupCache.
+type CacheOption func(config *CacheConfig)
+
+// CacheTTL bounds how long a GroupCache keeps decisions.
+func CacheTTL(ttl time.Duration) CacheOption {
+	return func(config *CacheConfig) {
+		config.TTL = ttl
+	}
+}
+
+// NegativeCacheTTL bounds how long a GroupCache keeps decisions that found no
+// Assignments at all, turning negative caching off unless it's positive.
+func NegativeCacheTTL(ttl time.Duration) CacheOption {
+	return func(config *CacheConfig) {
+		config.NegativeTTL = ttl
+	}
+}
+
+// CacheSize bounds the number of decisions that a GroupCache keeps.
+func CacheSize(size int) CacheOption {
+	return func(config *CacheConfig) {
+		config.Size = size
+	}
+}
+
+// CacheBus publishes the Invalidations of a GroupCache's writes to the given
+// InvalidationBus, reporting any errors in doing so to `onError` if it isn't nil. The
+// GroupCaches of other processes evict them once they're subscribed to it.
+func CacheBus(bus InvalidationBus, onError func(err error)) CacheOption {
+	return func(config *CacheConfig) {
+		config.Bus = bus
+		config.OnError = onError
+	}
+}
+
+// NewCacheConfig is a constructor for CacheConfig, with our defaults for whatever the
+// CacheOptions leave out.
+func NewCacheConfig(opts ...CacheOption) (config CacheConfig) {
+	config.TTL = DefaultCacheTTL
+	config.NegativeTTL = DefaultNegativeCacheTTL
+	config.Size = DefaultCacheSize
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	if config.Size <= 0 {
+		config.Size = DefaultCacheSize
+	}
+
+	return
+}
+
+// Invalidation names the cached decisions that a change to Groups may have affected:
+// those of a User, those upon a Resource, or every one of the tenant if neither is set.
+// Decisions upon every Resource of a kind are named with a WildcardResourceID.
+type Invalidation struct {
+	Tenant TenantID     `json:"t,omitempty"`
+	User   string       `json:"u,omitempty"`
+	Kind   ResourceKind `json:"k,omitempty"`
+	ID     ResourceID   `json:"i,omitempty"`
+}
+
+// UserInvalidation names the cached decisions of a User.
+func UserInvalidation(tenant TenantID, user string) (inv Invalidation) {
+	inv.Tenant = tenant
+	inv.User = user
+	return
+}
+
+// ResourceInvalidation names the cached decisions upon a Resource, or upon every
+// Resource of it's kind if it's a wildcard.
+func ResourceInvalidation(tenant TenantID, resource Resource) (inv Invalidation) {
+	inv.Tenant = tenant
+	inv.Kind = resource.Kind()
+	inv.ID = resource.Identifier()
+	return
+}
+
+// Invalidator is implemented by GroupRepositoryImpls that cache decisions, such as
+// GroupCache, and by GroupRepository.
+type Invalidator interface {
+	// Invalidate evicts the decisions that the given Invalidations name.
+	Invalidate(invs ...Invalidation)
+
+	// Flush evicts every decision, of every tenant.
+	Flush()
+}
+
+// Invalidate evicts the decisions that the given Invalidations name if the
+// GroupRepositoryImpl is an Invalidator, and does nothing otherwise.
+func (repo GroupRepository) Invalidate(invs ...Invalidation) {
+	if invalidator, ok := repo.GroupRepositoryImpl.(Invalidator); ok {
+		invalidator.Invalidate(invs...)
+	}
+}
+
+// Flush evicts every decision if the GroupRepositoryImpl is an Invalidator, and does
+// nothing otherwise.
+func (repo GroupRepository) Flush() {
+	if invalidator, ok := repo.GroupRepositoryImpl.(Invalidator); ok {
+		invalidator.Flush()
+	}
+}
+
+// invalidatorImpl is implemented by GroupRepositoryImpls that evict and publish the
+// decisions that writes to other repositories may affect, such as those to Teams.
+type invalidatorImpl interface {
+	invalidate(ctx context.Context, invs ...Invalidation)
+}
+
+// invalidate evicts the decisions that a write may have affected, and publishes their
+// Invalidations, if the GroupRepositoryImpl is an invalidatorImpl.
+func (repo GroupRepository) invalidate(ctx context.Context, invs ...Invalidation) {
+	if impl, ok := repo.GroupRepositoryImpl.(invalidatorImpl); ok {
+		impl.invalidate(ctx, invs...)
+	}
+}
+
+// GroupCache is a GroupRepositoryImpl that caches the Assignments behind the decisions
+// of another, so that protected requests needn't hit the database on every check. The
+// Assignments of a User to a set of Roles are cached per tenant, and `IsUserInAny` is
+// decided upon them with `Decide` on every call, so that Conditions see each request's
+// Attributes and grants lapse on time. Concurrent checks of the same User and Roles
+// share a single load.
+//
+// Writes through the GroupCache evict precisely the decisions they may affect: those of
+// the Users they name, and those upon the Resources of Teams' grants. So do changes to
+// Team memberships through `Teams` while the GroupCache is `Groups`: those of a User
+// evict her decisions, and those of a nested Team every decision of the tenant.
+// Everything else is only as fresh as the TTLs, such as grants whose NotBefore passes
+// and writes by other processes, unless they're passed to `Invalidate` or are published
+// to an InvalidationBus that it's subscribed to, per CacheBus. Reads
+// within a transaction, whether of `InTx` or of the Context, bypass the cache. Writes
+// within one evict and publish as they're made, and again once the Context's transaction
+// commits with `Commit`, lest a check that raced them cached what they replaced. A
+// transaction committed otherwise, or one of `InTx` whose methods aren't called with a
+// Context carrying it WithTx, is only evicted as it's written.
+type GroupCache struct {
+	GroupRepositoryImpl
+	*decisionCache
+
+	// tx that the GroupCache runs within, as a copy of `InTx`, if any.
+	tx *sql.Tx
+}
+
+// database is that of the decorated GroupRepositoryImpl, if it persists to one.
+func (repo *GroupCache) database() *sql.DB {
+	return databaseOf(repo.GroupRepositoryImpl)
+}
+
+// txGroupCache is a GroupCache of a GroupRepositoryImpl that can run within an *sql.Tx.
+type txGroupCache struct {
+	*GroupCache
+}
+
+// NewGroupCacheImpl is a constructor for GroupCache, decorating the given
+// GroupRepository. It's a TxRepositoryImpl as long as the GroupRepository is one.
+func NewGroupCacheImpl(groups GroupRepository, opts ...CacheOption) (
+	repo GroupRepository,
+) {
+	cache := &GroupCache{
+		GroupRepositoryImpl: groups.GroupRepositoryImpl,
+		decisionCache:       newDecisionCache(NewCacheConfig(opts...)),
+	}
+
+	repo.GroupRepositoryImpl = cache
+	if _, ok := groups.GroupRepositoryImpl.(TxRepositoryImpl); ok {
+		repo.GroupRepositoryImpl = txGroupCache{cache}
+	}
+
+	return
+}
+
+// InTx returns a copy of the GroupCache whose GroupRepositoryImpl runs within the given
+// transaction.
+func (repo txGroupCache) InTx(tx *sql.Tx) GroupRepositoryImpl {
+	txRepo := *repo.GroupCache
+	txRepo.GroupRepositoryImpl = repo.GroupRepositoryImpl.(TxRepositoryImpl).InTx(tx)
+	txRepo.tx = tx
+	return txGroupCache{&txRepo}
+}
+
+// IsUserInAny checks whether the User holds any of the given Roles, per `Decide` upon
+// her cached Assignments.
+func (repo *GroupCache) IsUserInAny(ctx context.Context, user User, roles Roles) (
+	ok bool, err error,
+) {
+	if ok = IsMasterIn(ctx, user); ok {
+		return
+	}
+
+	if len(roles) == 0 {
+		return
+	}
+
+	var assignments []Assignment
+	if assignments, err = repo.Assignments(ctx, user, roles); err != nil {
+		return
+	}
+
+	ok, err = Decide(ctx, user, roles, assignments)
+	return
+}
+
+// Assignments lists the active Assignments of the given User to any of the given Roles,
+// as cached.
+func (repo *GroupCache) Assignments(ctx context.Context, user User, roles Roles) (
+	assignments []Assignment, err error,
+) {
+	if _, ok := TxFromContext(ctx); ok || repo.tx != nil {
+		assignments, err = repo.GroupRepositoryImpl.Assignments(ctx, user, roles)
+		return
+	}
+
+	var cached []Assignment
+	if cached, err = repo.load(ctx, user, roles, repo.GroupRepositoryImpl); err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, a := range cached {
+		if a.Grant.IsActiveAt(now) {
+			assignments = append(assignments, a)
+		}
+	}
+
+	return
+}
+
+// Add a User to a Group, evicting her decisions.
+func (repo *GroupCache) Add(
+	ctx context.Context, user User, role Role, opts ...GrantOption,
+) (err error) {
+	err = repo.GroupRepositoryImpl.Add(ctx, user, role, opts...)
+	repo.invalidate(ctx, UserInvalidation(TenantFromContext(ctx), user.GetID()))
+	return
+}
+
+// AddMany adds the Users and Teams of every Group, evicting their decisions.
+func (repo *GroupCache) AddMany(
+	ctx context.Context, groups []Group, opts ...GrantOption,
+) (err error) {
+	err = GroupRepository{repo.GroupRepositoryImpl}.AddMany(ctx, groups, opts...)
+	repo.invalidate(ctx, invalidationsOf(ctx, MembersOf(groups))...)
+	return
+}
+
+// AddTeam adds a Team to a Group, evicting the decisions upon the Role's Resource.
+func (repo *GroupCache) AddTeam(
+	ctx context.Context, team TeamID, role Role, opts ...GrantOption,
+) (err error) {
+	err = repo.GroupRepositoryImpl.AddTeam(ctx, team, role, opts...)
+	repo.invalidate(ctx, ResourceInvalidation(TenantFromContext(ctx), role.Resource))
+	return
+}
+
+// Delete a User's Role, evicting her decisions.
+func (repo *GroupCache) Delete(ctx context.Context, user User, role Role) (err error) {
+	err = repo.GroupRepositoryImpl.Delete(ctx, user, role)
+	repo.invalidate(ctx, UserInvalidation(TenantFromContext(ctx), user.GetID()))
+	return
+}
+
+// DeleteMany deletes the Roles of the Users and Teams of every Group, evicting their
+// decisions.
+func (repo *GroupCache) DeleteMany(ctx context.Context, groups []Group) (err error) {
+	err = GroupRepository{repo.GroupRepositoryImpl}.DeleteMany(ctx, groups)
+	repo.invalidate(ctx, invalidationsOf(ctx, MembersOf(groups))...)
+	return
+}
+
+// DeleteTeam deletes a Team's Role, evicting the decisions upon the Role's Resource.
+func (repo *GroupCache) DeleteTeam(ctx context.Context, team TeamID, role Role) (
+	err error,
+) {
+	err = repo.GroupRepositoryImpl.DeleteTeam(ctx, team, role)
+	repo.invalidate(ctx, ResourceInvalidation(TenantFromContext(ctx), role.Resource))
+	return
+}
+
+// Deny a User a Role, evicting her decisions.
+func (repo *GroupCache) Deny(
+	ctx context.Context, user User, role Role, opts ...GrantOption,
+) (err error) {
+	err = GroupRepository{repo.GroupRepositoryImpl}.Deny(ctx, user, role, opts...)
+	repo.invalidate(ctx, UserInvalidation(TenantFromContext(ctx), user.GetID()))
+	return
+}
+
+// Free a Resource, evicting the decisions upon it.
+func (repo *GroupCache) Free(ctx context.Context, resource Resource) (err error) {
+	err = repo.GroupRepositoryImpl.Free(ctx, resource)
+	repo.invalidate(ctx, ResourceInvalidation(TenantFromContext(ctx), resource))
+	return
+}
+
+// Replace the grants upon a Resource, evicting the decisions upon it.
+func (repo *GroupCache) Replace(
+	ctx context.Context, resource Resource, desired []Group,
+) (err error) {
+	err = GroupRepository{repo.GroupRepositoryImpl}.Replace(ctx, resource, desired)
+	repo.invalidate(ctx, ResourceInvalidation(TenantFromContext(ctx), resource))
+	return
+}
+
+// Transfer a Role from one User to another, evicting the decisions of both.
+func (repo *GroupCache) Transfer(ctx context.Context, role Role, from User, to User) (
+	err error,
+) {
+	err = GroupRepository{repo.GroupRepositoryImpl}.Transfer(ctx, role, from, to)
+	repo.invalidate(
+		ctx,
+		UserInvalidation(TenantFromContext(ctx), from.GetID()),
+		UserInvalidation(TenantFromContext(ctx), to.GetID()),
+	)
+
+	return
+}
+
+// Denials lists the Users explicitly denied each Role upon the given Resource, uncached.
+func (repo *GroupCache) Denials(ctx context.Context, resource Resource) (
+	groups []Group, err error,
+) {
+	groups, err = GroupRepository{repo.GroupRepositoryImpl}.Denials(ctx, resource)
+	return
+}
+
+// FindPage lists a page of the Users and Teams that are granted any Role upon the given
+// Resource, uncached.
+func (repo *GroupCache) FindPage(ctx context.Context, resource Resource, page Page) (
+	members MemberPage, err error,
+) {
+	members, err = GroupRepository{repo.GroupRepositoryImpl}.FindPage(ctx, resource, page)
+	return
+}
+
+// ResourcesPage lists a page of the Roles that a User holds upon Resources of a given
+// ResourceKind, uncached.
+func (repo *GroupCache) ResourcesPage(
+	ctx context.Context, kind ResourceKind, user User, page Page,
+) (roles ResourcePage, err error) {
+	roles, err = GroupRepository{repo.GroupRepositoryImpl}.ResourcesPage(
+		ctx, kind, user, page,
+	)
+
+	return
+}
+
+// PurgeExpired deletes every assignment of the tenant whose expiry has passed. Expired
+// assignments are already left out of cached decisions, so nothing is evicted.
+func (repo *GroupCache) PurgeExpired(ctx context.Context) (n int64, err error) {
+	n, err = GroupRepository{repo.GroupRepositoryImpl}.PurgeExpired(ctx)
+	return
+}
+
+// invalidate evicts the decisions that a write may have affected, and publishes their
+// Invalidations to the Bus, if any. Within a transaction, it does so again once it
+// commits.
+func (repo *GroupCache) invalidate(ctx context.Context, invs ...Invalidation) {
+	if repo.tx != nil {
+		ctx = WithTx(ctx, repo.tx)
+	}
+
+	repo.publish(ctx, invs)
+	AfterCommit(ctx, func() {
+		repo.publish(ctx, invs)
+	})
+}
+
+// publish evicts the decisions that the Invalidations name, and publishes them to the
+// Bus, if any.
+func (repo *GroupCache) publish(ctx context.Context, invs []Invalidation) {
+	repo.Invalidate(invs...)
+	if repo.config.Bus == nil {
+		return
+	}
+
+	if err := repo.config.Bus.Publish(ctx, invs); err != nil && repo.config.OnError != nil {
+		repo.config.OnError(err)
+	}
+}
+
+// invalidationsOf names the decisions that writes to the Members may affect: those of
+// their Users, and those upon the Resources of their Teams' Roles.
+func invalidationsOf(ctx context.Context, members []Member) (invs []Invalidation) {
+	tenant := TenantFromContext(ctx)
+	for _, m := range members {
+		if m.Principal.Type == UserPrincipal {
+			invs = append(invs, UserInvalidation(tenant, m.Principal.ID))
+		} else {
+			invs = append(invs, ResourceInvalidation(tenant, m.Role.Resource))
+		}
+	}
+
+	return
+}
+
+// decisionCache holds the Assignments behind decisions, indexed by what may invalidate
+// them. It's shared by a GroupCache and it's copies within transactions.
+type decisionCache struct {
+	config CacheConfig
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	index   map[Invalidation]map[string]bool
+	flights map[string]*flight
+
+	// epoch counts invalidations, so that loads that overlap one aren't cached.
+	epoch uint64
+}
+
+// decision is a cached entry of a decisionCache.
+type decision struct {
+	key         string
+	assignments []Assignment
+	expiresAt   time.Time
+	keys        []Invalidation
+}
+
+// flight is a load of Assignments that concurrent checks of the same User and Roles
+// share.
+type flight struct {
+	done        chan struct{}
+	assignments []Assignment
+	err         error
+}
+
+func newDecisionCache(config CacheConfig) (cache *decisionCache) {
+	cache = new(decisionCache)
+	cache.config = config
+	cache.lru = list.New()
+	cache.entries = make(map[string]*list.Element)
+	cache.index = make(map[Invalidation]map[string]bool)
+	cache.flights = make(map[string]*flight)
+	return
+}
+
+// Invalidate evicts the decisions that the given Invalidations name, such as after
+// changes to Groups or Team memberships that didn't pass through the GroupCache.
+func (cache *decisionCache) Invalidate(invs ...Invalidation) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.epoch++
+
+	// Loads in flight may predate the invalidation, so later checks mustn't share them.
+	cache.flights = make(map[string]*flight)
+	for _, inv := range invs {
+		if inv.ID == WildcardResourceID {
+			inv.ID = ""
+		}
+
+		for key := range cache.index[inv] {
+			cache.evict(key)
+		}
+	}
+}
+
+// Flush evicts every decision, of every tenant, such as when Invalidations may have been
+// lost.
+func (cache *decisionCache) Flush() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.epoch++
+	cache.flights = make(map[string]*flight)
+	cache.lru.Init()
+	cache.entries = make(map[string]*list.Element)
+	cache.index = make(map[Invalidation]map[string]bool)
+}
+
+// Len is the number of decisions that are cached.
+func (cache *decisionCache) Len() (n int) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	n = cache.lru.Len()
+	return
+}
+
+// load the Assignments of the User to the Roles from the cache, or else from the
+// GroupRepositoryImpl, sharing loads in flight.
+func (cache *decisionCache) load(
+	ctx context.Context, user User, roles Roles, impl GroupRepositoryImpl,
+) (assignments []Assignment, err error) {
+	var (
+		tenant = TenantFromContext(ctx)
+		key    = decisionKey(tenant, user, roles)
+		now    = time.Now()
+	)
+
+	cache.mu.Lock()
+	if elem, ok := cache.entries[key]; ok {
+		if d := elem.Value.(*decision); now.Before(d.expiresAt) {
+			cache.lru.MoveToFront(elem)
+			cache.mu.Unlock()
+			assignments = d.assignments
+			return
+		}
+
+		cache.evict(key)
+	}
+
+	if f, ok := cache.flights[key]; ok {
+		cache.mu.Unlock()
+		select {
+		case <-f.done:
+			assignments, err = f.assignments, f.err
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+
+		// A load that failed as it's own Context ended is retried on behalf of ours.
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			assignments, err = cache.load(ctx, user, roles, impl)
+		}
+
+		return
+	}
+
+	f := &flight{done: make(chan struct{})}
+	cache.flights[key] = f
+	epoch := cache.epoch
+	cache.mu.Unlock()
+
+	assignments, err = impl.Assignments(ctx, user, roles)
+
+	cache.mu.Lock()
+	if cache.flights[key] == f {
+		delete(cache.flights, key)
+	}
+
+	if err == nil && epoch == cache.epoch {
+		cache.store(key, decisionKeys(tenant, user, roles), assignments)
+	}
+
+	cache.mu.Unlock()
+
+	f.assignments, f.err = assignments, err
+	close(f.done)
+	return
+}
+
+// store a decision, evicting the least recently used ones beyond the cache's Size.
+func (cache *decisionCache) store(key string, keys []Invalidation, assignments []Assignment) {
+	ttl := cache.config.TTL
+	if len(assignments) == 0 {
+		ttl = cache.config.NegativeTTL
+	}
+
+	if ttl <= 0 {
+		return
+	}
+
+	cache.evict(key)
+	cache.entries[key] = cache.lru.PushFront(&decision{
+		key:         key,
+		assignments: assignments,
+		expiresAt:   time.Now().Add(ttl),
+		keys:        keys,
+	})
+
+	for _, k := range keys {
+		if cache.index[k] == nil {
+			cache.index[k] = make(map[string]bool)
+		}
+
+		cache.index[k][key] = true
+	}
+
+	for cache.lru.Len() > cache.config.Size {
+		cache.evict(cache.lru.Back().Value.(*decision).key)
+	}
+}
+
+// evict a decision, if it's cached.
+func (cache *decisionCache) evict(key string) {
+	elem, ok := cache.entries[key]
+	if !ok {
+		return
+	}
+
+	d := cache.lru.Remove(elem).(*decision)
+	delete(cache.entries, key)
+	for _, k := range d.keys {
+		if delete(cache.index[k], key); len(cache.index[k]) == 0 {
+			delete(cache.index, k)
+		}
+	}
+}
+
+// decisionKey identifies the decisions of a User upon a set of Roles, in any order.
+func decisionKey(tenant TenantID, user User, roles Roles) string {
+	var names []string
+	for _, r := range roles {
+		names = append(names, strings.Join([]string{
+			string(r.Resource.Kind()), string(r.Resource.Identifier()), string(r.Name),
+		}, "\x00"))
+	}
+
+	sort.Strings(names)
+	return strings.Join(append(
+		[]string{string(tenant), user.GetID()}, names...,
+	), "\x01")
+}
+
+// decisionKeys are the Invalidations that name a decision of the User upon the Roles:
+// those of the User, of the Roles' Resources, of their kinds and of the tenant.
+func decisionKeys(tenant TenantID, user User, roles Roles) (keys []Invalidation) {
+	keys = []Invalidation{{Tenant: tenant}, UserInvalidation(tenant, user.GetID())}
+	seen := make(map[Invalidation]bool)
+	for _, r := range roles {
+		for _, k := range []Invalidation{
+			{Tenant: tenant, Kind: r.Resource.Kind(), ID: r.Resource.Identifier()},
+			{Tenant: tenant, Kind: r.Resource.Kind()},
+		} {
+			if k.ID == WildcardResourceID {
+				continue
+			}
+
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	return
+}
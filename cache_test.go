@@ -0,0 +1,264 @@
+package auth_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/authtest"
+)
+
+// countingRepo counts the loads of Assignments from an in-memory GroupRepositoryImpl,
+// holding each until `gate` is closed if it isn't nil.
+type countingRepo struct {
+	auth.GroupRepositoryImpl
+	loads int32
+	gate  chan struct{}
+}
+
+func (repo *countingRepo) Assignments(
+	ctx context.Context, user auth.User, roles auth.Roles,
+) ([]auth.Assignment, error) {
+	atomic.AddInt32(&repo.loads, 1)
+	if repo.gate != nil {
+		<-repo.gate
+	}
+
+	return repo.GroupRepositoryImpl.Assignments(ctx, user, roles)
+}
+
+// newCache returns a GroupCache of a countingRepo, and the GroupRepository beneath it for
+// writes that bypass the cache.
+func newCache(opts ...auth.CacheOption) (
+	cache auth.GroupRepository, inner auth.GroupRepository, counter *countingRepo,
+) {
+	inner = authtest.NewGroupMemoryRepositoryImpl()
+	counter = &countingRepo{GroupRepositoryImpl: inner.GroupRepositoryImpl}
+	cache = auth.NewGroupCacheImpl(
+		auth.GroupRepository{GroupRepositoryImpl: counter}, opts...,
+	)
+
+	return
+}
+
+func isUserInAny(
+	t *testing.T, repo auth.GroupRepository, user auth.User, role auth.Role,
+) bool {
+	t.Helper()
+	ok, err := repo.IsUserInAny(ctx, user, auth.Roles{role})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ok
+}
+
+func TestGroupCacheHits(t *testing.T) {
+	cache, inner, counter := newCache()
+	alice := authtest.NewUser("alice")
+	editor := auth.NewRole("editor", testResource{"campaign", "1"})
+	if err := inner.Add(ctx, alice, editor); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if !isUserInAny(t, cache, alice, editor) {
+			t.Fatalf("IsUserInAny = false")
+		}
+	}
+
+	if counter.loads != 1 {
+		t.Errorf("loaded %d times, want 1", counter.loads)
+	}
+
+	// Writes that bypass the cache are only seen once they're invalidated.
+	if err := inner.Delete(ctx, alice, editor); err != nil {
+		t.Fatal(err)
+	}
+
+	if !isUserInAny(t, cache, alice, editor) {
+		t.Errorf("IsUserInAny = false before Invalidate")
+	}
+
+	cache.Invalidate(auth.UserInvalidation("", alice.GetID()))
+	if isUserInAny(t, cache, alice, editor) {
+		t.Errorf("IsUserInAny = true after Invalidate")
+	}
+}
+
+func TestGroupCacheWritesEvict(t *testing.T) {
+	cache, _, _ := newCache()
+	campaign := testResource{"campaign", "1"}
+	alice, editor := authtest.NewUser("alice"), auth.NewRole("editor", campaign)
+	if isUserInAny(t, cache, alice, editor) {
+		t.Fatalf("IsUserInAny = true before Add")
+	}
+
+	if err := cache.Add(ctx, alice, editor); err != nil {
+		t.Fatal(err)
+	}
+
+	if !isUserInAny(t, cache, alice, editor) {
+		t.Errorf("IsUserInAny = false after Add")
+	}
+
+	if err := cache.Free(ctx, campaign); err != nil {
+		t.Fatal(err)
+	}
+
+	if isUserInAny(t, cache, alice, editor) {
+		t.Errorf("IsUserInAny = true after Free")
+	}
+}
+
+func TestGroupCacheTeamMemberships(t *testing.T) {
+	cache, _, _ := newCache(auth.CacheTTL(time.Hour), auth.NegativeCacheTTL(time.Hour))
+	groups, teams := auth.Groups, auth.Teams
+	auth.WithGroupRepository(cache)
+	auth.WithTeamRepository(authtest.NewTeamMemoryRepositoryImpl())
+	t.Cleanup(func() {
+		auth.Groups, auth.Teams = groups, teams
+	})
+
+	var (
+		alice, bob = authtest.NewUser("alice"), authtest.NewUser("bob")
+		editor     = auth.NewRole("editor", testResource{"campaign", "1"})
+		inner      = auth.TeamID("inner").Principal()
+		must       = func(err error) {
+			t.Helper()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	)
+
+	must(cache.AddTeam(ctx, "ops", editor))
+
+	// Changes to Team memberships evict the decisions they affect, well within the TTLs.
+	if isUserInAny(t, cache, alice, editor) {
+		t.Fatalf("IsUserInAny = true before AddMember")
+	}
+
+	must(auth.Teams.AddMember(ctx, "ops", auth.PrincipalOf(alice)))
+	if !isUserInAny(t, cache, alice, editor) {
+		t.Errorf("IsUserInAny = false after AddMember")
+	}
+
+	must(auth.Teams.AddMember(ctx, "inner", auth.PrincipalOf(bob)))
+	if isUserInAny(t, cache, bob, editor) {
+		t.Fatalf("IsUserInAny = true before nesting her Team")
+	}
+
+	must(auth.Teams.AddMember(ctx, "ops", inner))
+	if !isUserInAny(t, cache, bob, editor) {
+		t.Errorf("IsUserInAny = false after nesting her Team")
+	}
+
+	must(auth.Teams.RemoveMember(ctx, "ops", inner))
+	if isUserInAny(t, cache, bob, editor) {
+		t.Errorf("IsUserInAny = true after removing her Team")
+	}
+
+	must(auth.Teams.RemoveMember(ctx, "ops", auth.PrincipalOf(alice)))
+	if isUserInAny(t, cache, alice, editor) {
+		t.Errorf("IsUserInAny = true after RemoveMember")
+	}
+}
+
+func TestGroupCacheTTLs(t *testing.T) {
+	cache, inner, counter := newCache(
+		auth.CacheTTL(50*time.Millisecond), auth.NegativeCacheTTL(0),
+	)
+
+	alice := authtest.NewUser("alice")
+	editor := auth.NewRole("editor", testResource{"campaign", "1"})
+
+	// Decisions without Assignments aren't cached without a NegativeTTL.
+	isUserInAny(t, cache, alice, editor)
+	isUserInAny(t, cache, alice, editor)
+	if counter.loads != 2 {
+		t.Errorf("loaded %d times without negative caching, want 2", counter.loads)
+	}
+
+	if err := inner.Add(ctx, alice, editor); err != nil {
+		t.Fatal(err)
+	}
+
+	isUserInAny(t, cache, alice, editor)
+	isUserInAny(t, cache, alice, editor)
+	if counter.loads != 3 {
+		t.Errorf("loaded %d times within the TTL, want 3", counter.loads)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	isUserInAny(t, cache, alice, editor)
+	if counter.loads != 4 {
+		t.Errorf("loaded %d times after the TTL, want 4", counter.loads)
+	}
+}
+
+func TestGroupCacheExpiry(t *testing.T) {
+	cache, _, _ := newCache()
+	alice := authtest.NewUser("alice")
+	editor := auth.NewRole("editor", testResource{"campaign", "1"})
+	err := cache.Add(ctx, alice, editor, auth.ExpiresIn(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !isUserInAny(t, cache, alice, editor) {
+		t.Fatalf("IsUserInAny = false before expiry")
+	}
+
+	// Cached grants lapse on time, without waiting for the TTL.
+	time.Sleep(60 * time.Millisecond)
+	if isUserInAny(t, cache, alice, editor) {
+		t.Errorf("IsUserInAny = true after expiry")
+	}
+}
+
+func TestGroupCacheSize(t *testing.T) {
+	cache, _, _ := newCache(auth.CacheSize(2))
+	editor := auth.NewRole("editor", testResource{"campaign", "1"})
+	for _, id := range []string{"alice", "bob", "carol"} {
+		isUserInAny(t, cache, authtest.NewUser(id), editor)
+	}
+
+	if n := cache.GroupRepositoryImpl.(interface{ Len() int }).Len(); n != 2 {
+		t.Errorf("Len = %d, want 2", n)
+	}
+
+	cache.Flush()
+	if n := cache.GroupRepositoryImpl.(interface{ Len() int }).Len(); n != 0 {
+		t.Errorf("Len = %d after Flush, want 0", n)
+	}
+}
+
+func TestGroupCacheSharesLoads(t *testing.T) {
+	cache, _, counter := newCache()
+	counter.gate = make(chan struct{})
+	alice := authtest.NewUser("alice")
+	editor := auth.NewRole("editor", testResource{"campaign", "1"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.IsUserInAny(ctx, alice, auth.Roles{editor}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(counter.gate)
+	wg.Wait()
+
+	if loads := atomic.LoadInt32(&counter.loads); loads != 1 {
+		t.Errorf("loaded %d times, want 1", loads)
+	}
+}
@@ -0,0 +1,751 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Conditions are small boolean expressions attached to grants, and evaluated at check
+// time against Attributes of the request, the User and the Resource, such as:
+//
+//     cidr(request.ip, "10.0.0.0/8") && hour(request.time) >= 9
+//     resource.region == user.region
+//     request.method in ["GET", "HEAD"]
+//
+// The language has string, number, boolean and null literals, lists for use with `in`,
+// dotted attribute paths, the operators `!`, `&&`, `||`, `==`, `!=`, `<`, `<=`, `>`,
+// `>=` and `in`, parentheses, and a fixed set of functions: cidr, hour, weekday, lower.
+// Nothing else, and in particular nothing reflective, is reachable from an expression.
+
+var (
+	// ErrInvalidCondition when a condition expression can't be parsed.
+	ErrInvalidCondition = fmt.Errorf("invalid condition")
+
+	// ErrConditionEvaluation when a condition expression can't be evaluated against the
+	// given Attributes, such as when an attribute is missing or of the wrong type.
+	ErrConditionEvaluation = fmt.Errorf("condition evaluation failed")
+)
+
+// Attributes are the values a Condition is evaluated against. Nested Attributes are
+// reached with dotted paths.
+type Attributes map[string]interface{}
+
+// Condition is a parsed condition expression.
+type Condition struct {
+	src  string
+	root condNode
+}
+
+// ParseCondition parses a condition expression, failing with ErrInvalidCondition.
+func ParseCondition(src string) (cond Condition, err error) {
+	p := condParser{src: src}
+	if err = p.lex(); err != nil {
+		return
+	}
+
+	if cond.root, err = p.parseOr(); err != nil {
+		return
+	}
+
+	if !p.done() {
+		err = p.errorf("unexpected %q", p.peek().text)
+		return
+	}
+
+	cond.src = src
+	return
+}
+
+// String returns the source of the Condition.
+func (cond Condition) String() string {
+	return cond.src
+}
+
+// Eval evaluates the Condition against the given Attributes, failing with
+// ErrConditionEvaluation.
+func (cond Condition) Eval(attrs Attributes) (ok bool, err error) {
+	var v interface{}
+	if v, err = cond.root.eval(attrs); err != nil {
+		return
+	}
+
+	var isBool bool
+	if ok, isBool = v.(bool); !isBool {
+		err = fmt.Errorf("%w: %q is not a boolean", ErrConditionEvaluation, cond.src)
+	}
+
+	return
+}
+
+type condTokenKind int
+
+const (
+	tokIdent condTokenKind = iota
+	tokString
+	tokNumber
+	tokOp
+)
+
+type condToken struct {
+	kind condTokenKind
+	text string
+	pos  int
+}
+
+type condParser struct {
+	src    string
+	tokens []condToken
+	i      int
+}
+
+func (p *condParser) errorf(format string, args ...interface{}) error {
+	pos := len(p.src)
+	if !p.done() {
+		pos = p.peek().pos
+	}
+
+	return fmt.Errorf(
+		"%w: %s at offset %d of %q",
+		ErrInvalidCondition, fmt.Sprintf(format, args...), pos, p.src,
+	)
+}
+
+func (p *condParser) lex() (err error) {
+	src := p.src
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && rune(src[j]) != c {
+				if src[j] == '\\' {
+					j++
+				}
+
+				j++
+			}
+
+			if j >= len(src) {
+				return fmt.Errorf(
+					"%w: unterminated string at offset %d of %q", ErrInvalidCondition, i, src,
+				)
+			}
+
+			text := strings.NewReplacer(`\\`, `\`, `\`+string(c), string(c)).Replace(
+				src[i+1 : j],
+			)
+
+			p.tokens = append(p.tokens, condToken{tokString, text, i})
+			i = j + 1
+
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+
+			p.tokens = append(p.tokens, condToken{tokNumber, src[i:j], i})
+			i = j
+
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) ||
+				unicode.IsDigit(rune(src[j])) || src[j] == '_' || src[j] == '.') {
+				j++
+			}
+
+			p.tokens = append(p.tokens, condToken{tokIdent, src[i:j], i})
+			i = j
+
+		default:
+			var op string
+			for _, candidate := range []string{
+				"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",",
+			} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+
+			if op == "" {
+				return fmt.Errorf(
+					"%w: unexpected %q at offset %d of %q", ErrInvalidCondition, c, i, src,
+				)
+			}
+
+			p.tokens = append(p.tokens, condToken{tokOp, op, i})
+			i += len(op)
+		}
+	}
+
+	return
+}
+
+func (p *condParser) done() bool {
+	return p.i >= len(p.tokens)
+}
+
+func (p *condParser) peek() condToken {
+	return p.tokens[p.i]
+}
+
+// accept consumes the next token if it is the given operator or keyword.
+func (p *condParser) accept(text string) (ok bool) {
+	if ok = !p.done() && p.peek().kind != tokString && p.peek().text == text; ok {
+		p.i++
+	}
+
+	return
+}
+
+func (p *condParser) expect(text string) (err error) {
+	if !p.accept(text) {
+		err = p.errorf("expected %q", text)
+	}
+
+	return
+}
+
+func (p *condParser) parseOr() (node condNode, err error) {
+	if node, err = p.parseAnd(); err != nil {
+		return
+	}
+
+	for p.accept("||") {
+		var rhs condNode
+		if rhs, err = p.parseAnd(); err != nil {
+			return
+		}
+
+		node = condBinary{"||", node, rhs}
+	}
+
+	return
+}
+
+func (p *condParser) parseAnd() (node condNode, err error) {
+	if node, err = p.parseNot(); err != nil {
+		return
+	}
+
+	for p.accept("&&") {
+		var rhs condNode
+		if rhs, err = p.parseNot(); err != nil {
+			return
+		}
+
+		node = condBinary{"&&", node, rhs}
+	}
+
+	return
+}
+
+func (p *condParser) parseNot() (node condNode, err error) {
+	if p.accept("!") {
+		if node, err = p.parseNot(); err != nil {
+			return
+		}
+
+		node = condNot{node}
+		return
+	}
+
+	node, err = p.parseComparison()
+	return
+}
+
+func (p *condParser) parseComparison() (node condNode, err error) {
+	if node, err = p.parsePrimary(); err != nil {
+		return
+	}
+
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">", "in"} {
+		if p.accept(op) {
+			var rhs condNode
+			if rhs, err = p.parsePrimary(); err != nil {
+				return
+			}
+
+			node = condBinary{op, node, rhs}
+			return
+		}
+	}
+
+	return
+}
+
+func (p *condParser) parsePrimary() (node condNode, err error) {
+	if p.done() {
+		err = p.errorf("unexpected end of condition")
+		return
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.kind == tokString:
+		p.i++
+		node = condLiteral{tok.text}
+
+	case tok.kind == tokNumber:
+		p.i++
+
+		var f float64
+		if f, err = strconv.ParseFloat(tok.text, 64); err != nil {
+			p.i--
+			err = p.errorf("invalid number %q", tok.text)
+			return
+		}
+
+		node = condLiteral{f}
+
+	case tok.kind == tokIdent:
+		p.i++
+		switch tok.text {
+		case "true":
+			node = condLiteral{true}
+		case "false":
+			node = condLiteral{false}
+		case "null":
+			node = condLiteral{nil}
+		default:
+			if !p.accept("(") {
+				node = condAttr{strings.Split(tok.text, ".")}
+				return
+			}
+
+			fn, ok := condFuncs[tok.text]
+			if !ok {
+				p.i -= 2
+				err = p.errorf("unknown function %q", tok.text)
+				return
+			}
+
+			call := condCall{name: tok.text, fn: fn}
+			if call.args, err = p.parseList(")"); err != nil {
+				return
+			}
+
+			node = call
+		}
+
+	case p.accept("("):
+		if node, err = p.parseOr(); err != nil {
+			return
+		}
+
+		err = p.expect(")")
+
+	case p.accept("["):
+		var elems []condNode
+		if elems, err = p.parseList("]"); err != nil {
+			return
+		}
+
+		node = condList(elems)
+
+	default:
+		err = p.errorf("unexpected %q", tok.text)
+	}
+
+	return
+}
+
+// parseList parses comma-separated expressions up to the closing token.
+func (p *condParser) parseList(closing string) (elems []condNode, err error) {
+	if p.accept(closing) {
+		return
+	}
+
+	for {
+		var elem condNode
+		if elem, err = p.parseOr(); err != nil {
+			return
+		}
+
+		elems = append(elems, elem)
+		if p.accept(closing) {
+			return
+		}
+
+		if err = p.expect(","); err != nil {
+			return
+		}
+	}
+}
+
+type condNode interface {
+	eval(attrs Attributes) (v interface{}, err error)
+}
+
+type condLiteral struct {
+	v interface{}
+}
+
+func (node condLiteral) eval(Attributes) (interface{}, error) {
+	return node.v, nil
+}
+
+type condAttr struct {
+	path []string
+}
+
+func (node condAttr) eval(attrs Attributes) (v interface{}, err error) {
+	var (
+		cur = map[string]interface{}(attrs)
+		ok  bool
+	)
+
+	for i, key := range node.path {
+		if v, ok = cur[key]; !ok {
+			err = fmt.Errorf(
+				"%w: missing attribute %q", ErrConditionEvaluation,
+				strings.Join(node.path, "."),
+			)
+
+			return
+		}
+
+		if i == len(node.path)-1 {
+			break
+		}
+
+		switch next := v.(type) {
+		case Attributes:
+			cur = next
+		case map[string]interface{}:
+			cur = next
+		default:
+			err = fmt.Errorf(
+				"%w: attribute %q has no fields", ErrConditionEvaluation,
+				strings.Join(node.path[:i+1], "."),
+			)
+
+			return
+		}
+	}
+
+	v = normalize(v)
+	return
+}
+
+type condList []condNode
+
+func (node condList) eval(attrs Attributes) (v interface{}, err error) {
+	list := make([]interface{}, len(node))
+	for i, elem := range node {
+		if list[i], err = elem.eval(attrs); err != nil {
+			return
+		}
+	}
+
+	v = list
+	return
+}
+
+type condNot struct {
+	x condNode
+}
+
+func (node condNot) eval(attrs Attributes) (v interface{}, err error) {
+	var b bool
+	if b, err = evalBool(node.x, attrs); err != nil {
+		return
+	}
+
+	v = !b
+	return
+}
+
+type condBinary struct {
+	op       string
+	lhs, rhs condNode
+}
+
+func (node condBinary) eval(attrs Attributes) (v interface{}, err error) {
+	switch node.op {
+	case "&&", "||":
+		var l, r bool
+		if l, err = evalBool(node.lhs, attrs); err != nil {
+			return
+		}
+
+		if node.op == "&&" && !l || node.op == "||" && l {
+			v = l
+			return
+		}
+
+		if r, err = evalBool(node.rhs, attrs); err != nil {
+			return
+		}
+
+		v = r
+		return
+	}
+
+	var l, r interface{}
+	if l, err = node.lhs.eval(attrs); err != nil {
+		return
+	}
+
+	if r, err = node.rhs.eval(attrs); err != nil {
+		return
+	}
+
+	switch node.op {
+	case "==", "!=":
+		var eq bool
+		if eq, err = equal(l, r); err == nil {
+			v = eq == (node.op == "==")
+		}
+	case "in":
+		list, ok := r.([]interface{})
+		if !ok {
+			err = fmt.Errorf("%w: right of `in` is not a list", ErrConditionEvaluation)
+			return
+		}
+
+		var found bool
+		for _, elem := range list {
+			if found, err = equal(elem, l); err != nil || found {
+				break
+			}
+		}
+
+		v = found
+	default:
+		v, err = compare(node.op, l, r)
+	}
+
+	return
+}
+
+// equal compares two values, where lists are never equal to anything. Only the types
+// that expressions produce are compared, and any other, such as maps or slices from
+// Attributes, fails with ErrConditionEvaluation.
+func equal(l, r interface{}) (ok bool, err error) {
+	if _, isList := l.([]interface{}); isList {
+		return
+	}
+
+	if _, isList := r.([]interface{}); isList {
+		return
+	}
+
+	for _, v := range []interface{}{l, r} {
+		switch v.(type) {
+		case nil, string, float64, bool, time.Time:
+		default:
+			err = fmt.Errorf("%w: cannot compare %T", ErrConditionEvaluation, v)
+			return
+		}
+	}
+
+	ok = l == r
+	return
+}
+
+func evalBool(node condNode, attrs Attributes) (b bool, err error) {
+	var v interface{}
+	if v, err = node.eval(attrs); err != nil {
+		return
+	}
+
+	var ok bool
+	if b, ok = v.(bool); !ok {
+		err = fmt.Errorf("%w: %v is not a boolean", ErrConditionEvaluation, v)
+	}
+
+	return
+}
+
+func compare(op string, l, r interface{}) (v interface{}, err error) {
+	var c int
+	switch l := l.(type) {
+	case float64:
+		rf, ok := r.(float64)
+		if !ok {
+			err = fmt.Errorf("%w: cannot compare %v with %v", ErrConditionEvaluation, l, r)
+			return
+		}
+
+		switch {
+		case l < rf:
+			c = -1
+		case l > rf:
+			c = 1
+		}
+
+	case string:
+		rs, ok := r.(string)
+		if !ok {
+			err = fmt.Errorf("%w: cannot compare %q with %v", ErrConditionEvaluation, l, r)
+			return
+		}
+
+		c = strings.Compare(l, rs)
+
+	default:
+		err = fmt.Errorf("%w: cannot order %v", ErrConditionEvaluation, l)
+		return
+	}
+
+	switch op {
+	case "<":
+		v = c < 0
+	case "<=":
+		v = c <= 0
+	case ">":
+		v = c > 0
+	default:
+		v = c >= 0
+	}
+
+	return
+}
+
+// normalize maps attribute values onto the types of our literals, so that they can be
+// compared with them.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case fmt.Stringer:
+		if _, ok := v.(time.Time); ok {
+			return v
+		}
+
+		return v.String()
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, s := range v {
+			list[i] = s
+		}
+
+		return list
+	}
+
+	return v
+}
+
+type condCall struct {
+	name string
+	fn   condFunc
+	args []condNode
+}
+
+func (node condCall) eval(attrs Attributes) (v interface{}, err error) {
+	args := make([]interface{}, len(node.args))
+	for i, arg := range node.args {
+		if args[i], err = arg.eval(attrs); err != nil {
+			return
+		}
+	}
+
+	if v, err = node.fn(args...); err != nil {
+		err = fmt.Errorf("%w: %s(): %s", ErrConditionEvaluation, node.name, err.Error())
+	}
+
+	return
+}
+
+type condFunc func(args ...interface{}) (v interface{}, err error)
+
+var condFuncs = map[string]condFunc{
+	// cidr(ip, range) checks whether an IP address lies within a CIDR range.
+	"cidr": func(args ...interface{}) (v interface{}, err error) {
+		var ip, cidr string
+		if err = stringArgs(args, &ip, &cidr); err != nil {
+			return
+		}
+
+		var network *net.IPNet
+		if _, network, err = net.ParseCIDR(cidr); err != nil {
+			return
+		}
+
+		v = network.Contains(net.ParseIP(ip))
+		return
+	},
+
+	// hour(time) returns the hour of a time.Time, from 0 to 23.
+	"hour": func(args ...interface{}) (v interface{}, err error) {
+		var t time.Time
+		if t, err = timeArg(args); err == nil {
+			v = float64(t.Hour())
+		}
+
+		return
+	},
+
+	// weekday(time) returns the day of the week of a time.Time, from 0 for Sunday to 6
+	// for Saturday.
+	"weekday": func(args ...interface{}) (v interface{}, err error) {
+		var t time.Time
+		if t, err = timeArg(args); err == nil {
+			v = float64(t.Weekday())
+		}
+
+		return
+	},
+
+	// lower(s) lower-cases a string.
+	"lower": func(args ...interface{}) (v interface{}, err error) {
+		var s string
+		if err = stringArgs(args, &s); err == nil {
+			v = strings.ToLower(s)
+		}
+
+		return
+	},
+}
+
+func stringArgs(args []interface{}, dst ...*string) (err error) {
+	if len(args) != len(dst) {
+		err = fmt.Errorf("expected %d arguments, got %d", len(dst), len(args))
+		return
+	}
+
+	for i, arg := range args {
+		var ok bool
+		if *dst[i], ok = arg.(string); !ok {
+			err = fmt.Errorf("argument %d is not a string", i+1)
+			return
+		}
+	}
+
+	return
+}
+
+func timeArg(args []interface{}) (t time.Time, err error) {
+	if len(args) != 1 {
+		err = fmt.Errorf("expected 1 argument, got %d", len(args))
+		return
+	}
+
+	var ok bool
+	if t, ok = args[0].(time.Time); !ok {
+		err = fmt.Errorf("argument is not a time")
+	}
+
+	return
+}
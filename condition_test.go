@@ -0,0 +1,127 @@
+package auth_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/angadn/auth"
+)
+
+func TestConditionEval(t *testing.T) {
+	attrs := auth.Attributes{
+		"request": auth.Attributes{
+			"ip":     "10.1.2.3",
+			"method": "GET",
+			"time":   time.Date(2020, 12, 14, 10, 30, 0, 0, time.UTC),
+		},
+		"user": map[string]interface{}{
+			"region": "eu",
+			"level":  3,
+			"groups": []string{"ops", "dev"},
+		},
+		"resource": auth.Attributes{
+			"region": "eu",
+			"size":   int64(10),
+		},
+	}
+
+	for _, c := range []struct {
+		src  string
+		want bool
+	}{
+		{`true`, true},
+		{`!false`, true},
+		{`resource.region == user.region`, true},
+		{`resource.region != user.region`, false},
+		{`user.level >= 3 && user.level < 4`, true},
+		{`user.level > 3 || resource.size <= 10`, true},
+		{`resource.size == 10.0`, true},
+		{`"abc" < "abd"`, true},
+		{`request.method in ["GET", "HEAD"]`, true},
+		{`request.method in ['POST']`, false},
+		{`"ops" in user.groups`, true},
+		{`[1] == [1]`, false},
+		{`null == null`, true},
+		{`cidr(request.ip, "10.0.0.0/8")`, true},
+		{`cidr(request.ip, "192.168.0.0/16")`, false},
+		{`hour(request.time) >= 9 && weekday(request.time) == 1`, true},
+		{`lower("EU") == resource.region`, true},
+		{`!(user.level == 3)`, false},
+		{`false && missing.attribute`, false},
+		{`true || missing.attribute`, true},
+		{`'it\'s' == "it's"`, true},
+	} {
+		cond, err := auth.ParseCondition(c.src)
+		if err != nil {
+			t.Errorf("ParseCondition(%s): %v", c.src, err)
+			continue
+		}
+
+		if got, err := cond.Eval(attrs); err != nil {
+			t.Errorf("Eval(%s): %v", c.src, err)
+		} else if got != c.want {
+			t.Errorf("Eval(%s) = %v, want %v", c.src, got, c.want)
+		}
+
+		if cond.String() != c.src {
+			t.Errorf("String() = %q, want %q", cond.String(), c.src)
+		}
+	}
+}
+
+func TestConditionEvalErrors(t *testing.T) {
+	attrs := auth.Attributes{
+		"user":     auth.Attributes{"region": "eu", "level": 3, "tags": []int{1}},
+		"resource": auth.Attributes{"tags": []int{1}},
+		"request":  auth.Attributes{"ip": "10.1.2.3", "port": struct{ n int }{443}},
+	}
+
+	for _, src := range []string{
+		`user.name == "alice"`,
+		`user.region.code == "eu"`,
+		`user.region`,
+		`!user.level`,
+		`user.level < "3"`,
+		`true < false`,
+		`user.region in "eu"`,
+		`cidr(request.ip)`,
+		`cidr(request.ip, "nonsense")`,
+		`hour(request.ip)`,
+		`lower(1)`,
+		`user.tags == resource.tags`,
+		`user.tags != resource.tags`,
+		`user == resource`,
+		`1 in [user.tags]`,
+		`request.port == 443`,
+	} {
+		cond, err := auth.ParseCondition(src)
+		if err != nil {
+			t.Errorf("ParseCondition(%s): %v", src, err)
+			continue
+		}
+
+		if _, err = cond.Eval(attrs); !errors.Is(err, auth.ErrConditionEvaluation) {
+			t.Errorf("Eval(%s) = %v, want ErrConditionEvaluation", src, err)
+		}
+	}
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`user.region ==`,
+		`(true`,
+		`true)`,
+		`"unterminated`,
+		`user.region = "eu"`,
+		`exec("rm")`,
+		`[1, 2`,
+		`1.2.3 == 1`,
+		`true false`,
+	} {
+		if _, err := auth.ParseCondition(src); !errors.Is(err, auth.ErrInvalidCondition) {
+			t.Errorf("ParseCondition(%s) = %v, want ErrInvalidCondition", src, err)
+		}
+	}
+}
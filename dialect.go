@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dialect captures the differences among the SQL databases we persist to. Queries are
+// written once in MySQL's syntax, with backtick-quoted identifiers and `?` placeholders,
+// and rebound for the others.
+type dialect interface {
+	// rebind a query written in MySQL's syntax.
+	rebind(query string) string
+
+	// upsert returns the clause that turns an INSERT into an upsert, updating the given
+	// columns when a row conflicts with the given key.
+	upsert(key []string, update ...string) string
+
+	// forUpdate returns the clause that locks the rows that a SELECT reads until the end
+	// of it's transaction, which also reads their latest committed versions.
+	forUpdate() string
+}
+
+// mysqlDialect is MySQL's.
+type mysqlDialect struct{}
+
+func (mysqlDialect) rebind(query string) string {
+	return query
+}
+
+func (mysqlDialect) upsert(key []string, update ...string) string {
+	sets := make([]string, len(update))
+	for i, col := range update {
+		sets[i] = fmt.Sprintf("`%s` = VALUES(`%s`)", col, col)
+	}
+
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+func (mysqlDialect) forUpdate() string {
+	return " FOR UPDATE"
+}
+
+// postgresDialect is PostgreSQL's, quoting identifiers with double-quotes and numbering
+// it's placeholders.
+type postgresDialect struct{}
+
+func (postgresDialect) rebind(query string) string {
+	var (
+		b        strings.Builder
+		n        int
+		inString bool
+	)
+
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+			b.WriteRune(r)
+		case inString:
+			b.WriteRune(r)
+		case r == '`':
+			b.WriteRune('"')
+		case r == '?':
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+func (postgresDialect) upsert(key []string, update ...string) string {
+	sets := make([]string, len(update))
+	for i, col := range update {
+		sets[i] = fmt.Sprintf("`%s` = EXCLUDED.`%s`", col, col)
+	}
+
+	return fmt.Sprintf(
+		"ON CONFLICT (`%s`) DO UPDATE SET %s",
+		strings.Join(key, "`, `"),
+		strings.Join(sets, ", "),
+	)
+}
+
+func (postgresDialect) forUpdate() string {
+	return " FOR UPDATE"
+}
+
+// sqliteDialect is SQLite's, which understands MySQL's backticks and placeholders, but
+// upserts like PostgreSQL does.
+type sqliteDialect struct{}
+
+func (sqliteDialect) rebind(query string) string {
+	return query
+}
+
+func (sqliteDialect) upsert(key []string, update ...string) string {
+	return postgresDialect{}.upsert(key, update...)
+}
+
+// forUpdate is empty for SQLite, which locks the whole database for each write
+// transaction instead.
+func (sqliteDialect) forUpdate() string {
+	return ""
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"testing"
+)
+
+func TestPostgresDialect(t *testing.T) {
+	for _, c := range []struct {
+		query, want string
+	}{
+		{
+			"SELECT `role_name` FROM `groups` WHERE `user_id` = ? AND `resource_id` = ?",
+			`SELECT "role_name" FROM "groups" WHERE "user_id" = $1 AND "resource_id" = $2`,
+		},
+		{
+			"SELECT ? FROM `groups` WHERE `effect` = 'it''s `not` ?' AND `tenant_id` = ?",
+			`SELECT $1 FROM "groups" WHERE "effect" = 'it''s ` + "`not`" + ` ?' AND "tenant_id" = $2`,
+		},
+	} {
+		if got := (postgresDialect{}).rebind(c.query); got != c.want {
+			t.Errorf("rebind(%s) = %s, want %s", c.query, got, c.want)
+		}
+	}
+
+	want := "ON CONFLICT (`tenant_id`, `user_id`) DO UPDATE SET `effect` = EXCLUDED.`effect`"
+	if got := (postgresDialect{}).upsert(
+		[]string{"tenant_id", "user_id"}, "effect",
+	); got != want {
+		t.Errorf("upsert = %s, want %s", got, want)
+	}
+
+	if got := (postgresDialect{}).forUpdate(); got != " FOR UPDATE" {
+		t.Errorf("forUpdate = %q, want FOR UPDATE", got)
+	}
+}
+
+func TestMySQLDialect(t *testing.T) {
+	query := "SELECT `role_name` FROM `groups` WHERE `user_id` = ?"
+	if got := (mysqlDialect{}).rebind(query); got != query {
+		t.Errorf("rebind(%s) = %s", query, got)
+	}
+
+	want := "ON DUPLICATE KEY UPDATE `effect` = VALUES(`effect`), `condition` = VALUES(`condition`)"
+	if got := (mysqlDialect{}).upsert([]string{"user_id"}, "effect", "condition"); got != want {
+		t.Errorf("upsert = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Match is an Assignment that applies to one of the Roles being explained.
+type Match struct {
+	Assignment Assignment
+
+	// Role being explained that the Assignment applies to.
+	Role Role
+
+	// Holds is whether the Assignment's Condition, if any, held.
+	Holds bool
+
+	// Error is why the Assignment's Condition failed to evaluate, if it did.
+	Error string
+}
+
+// Explanation of whether a User holds any of the given Roles, as returned by `Explain`.
+type Explanation struct {
+	User    string
+	Roles   Roles
+	Allowed bool
+
+	// Master is whether the User is the Master, or the master of the tenant, who bypass
+	// all checks.
+	Master bool
+
+	// Implied lists the Roles that imply any of the given ones per the configured
+	// RoleGraph, which were checked along with them.
+	Implied Roles
+
+	// Matches lists every active Assignment that applies to any of the Roles. It is
+	// empty when none did.
+	Matches []Match
+
+	// Member is the outcome of checking the User's Groups alone, which the configured
+	// PolicyEngine, if any, decided upon.
+	Member bool
+
+	// Policy is the Decision of the configured PolicyEngine, whose Effect is empty when it
+	// had no opinion or when there's none.
+	Policy Decision
+}
+
+// Explain why the given User does or doesn't hold any of the given Roles, per the same
+// rules as `IsUserInAnyImplied`: which Assignments matched the Roles or those implying
+// them and whether their Conditions held, or that none did, or that the Master bypass
+// applied, along with the Decision of the configured PolicyEngine and it's Reasons. It
+// is suitable for logging, and for answering support queries in an admin UI. A Condition
+// that fails to evaluate fails the check, so it's error is returned along with the
+// Explanation of how far it got.
+func (repo GroupRepository) Explain(ctx context.Context, user User, roles Roles) (
+	explanation Explanation, err error,
+) {
+	explanation.User = user.GetID()
+	explanation.Roles = roles
+	if explanation.Master = IsMasterIn(ctx, user); explanation.Master {
+		explanation.Allowed = true
+		return
+	}
+
+	type roleKey struct {
+		resource resourceKey
+		name     RoleName
+	}
+
+	given := make(map[roleKey]bool)
+	for _, role := range roles {
+		given[roleKey{keyOf(role.Resource), role.Name}] = true
+	}
+
+	expanded := roleGraph.Expand(roles...)
+	for _, role := range expanded {
+		if !given[roleKey{keyOf(role.Resource), role.Name}] {
+			explanation.Implied = append(explanation.Implied, role)
+		}
+	}
+
+	if len(roles) > 0 {
+		var assignments []Assignment
+		if assignments, err = repo.Assignments(ctx, user, expanded); err != nil {
+			return
+		}
+
+		if explanation.Matches, explanation.Member, err = evaluate(
+			ctx, user, expanded, assignments,
+		); err != nil {
+			return
+		}
+	}
+
+	explanation.Allowed, explanation.Policy, err = consultDecision(
+		ctx, rolesRequest(user, roles, explanation.Member),
+	)
+
+	return
+}
+
+// String formats the Explanation in a single line, for logging.
+func (explanation Explanation) String() string {
+	verdict := "denied"
+	if explanation.Allowed {
+		verdict = "allowed"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "user %q %s", explanation.User, verdict)
+	switch {
+	case explanation.Master:
+		b.WriteString(": master bypass")
+		return b.String()
+	case len(explanation.Matches) == 0:
+		fmt.Fprintf(&b, ": no assignment matched any of %d role(s)", len(explanation.Roles))
+	default:
+		b.WriteString(":")
+	}
+
+	for i, m := range explanation.Matches {
+		if i > 0 {
+			b.WriteString(";")
+		}
+
+		fmt.Fprintf(
+			&b, " %s %s for %s:%s upon %s:%s",
+			m.Assignment.Effect,
+			m.Assignment.Role.Name,
+			m.Assignment.Principal.Type,
+			m.Assignment.Principal.ID,
+			m.Assignment.Role.Resource.Kind(),
+			m.Assignment.Role.Resource.Identifier(),
+		)
+
+		switch {
+		case m.Error != "":
+			fmt.Fprintf(&b, " (condition failed: %s)", m.Error)
+		case m.Assignment.Grant.Condition != "" && m.Holds:
+			fmt.Fprintf(&b, " (condition %q held)", m.Assignment.Grant.Condition)
+		case m.Assignment.Grant.Condition != "":
+			fmt.Fprintf(&b, " (condition %q did not hold)", m.Assignment.Grant.Condition)
+		}
+	}
+
+	if policy := explanation.Policy; policy.Effect != "" {
+		fmt.Fprintf(&b, "; policy %s", policy.Effect)
+		if len(policy.Reasons) > 0 {
+			fmt.Fprintf(&b, " per %s", strings.Join(policy.Reasons, ", "))
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package auth_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/authtest"
+)
+
+func TestExplain(t *testing.T) {
+	campaign := testResource{"campaign", "1"}
+	fixture := authtest.Install()
+	alice, bob := authtest.NewUser("alice"), authtest.NewUser("bob")
+	viewer, owner := auth.NewRole("viewer", campaign), auth.NewRole("owner", campaign)
+	if err := fixture.Groups.Add(ctx, alice, owner); err != nil {
+		t.Fatal(err)
+	}
+
+	graph := auth.NewRoleGraph()
+	graph.Imply("campaign", "owner", "viewer")
+	auth.WithRoleGraph(graph)
+	defer auth.WithRoleGraph(nil)
+
+	explanation, err := fixture.Groups.Explain(ctx, alice, auth.Roles{viewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !explanation.Allowed || !explanation.Member || len(explanation.Matches) != 1 {
+		t.Errorf("Explain = %+v, want allowed as an owner", explanation)
+	}
+
+	if !reflect.DeepEqual(explanation.Implied, auth.Roles{owner}) {
+		t.Errorf("Implied = %v, want %v", explanation.Implied, auth.Roles{owner})
+	}
+
+	// The PolicyEngine's Decision overrides the User's Groups, and is explained.
+	auth.WithPolicyEngine(auth.Policy{Rules: []auth.PolicyRule{{
+		ID:     "readonly",
+		Effect: auth.EffectDeny,
+		Kinds:  []auth.ResourceKind{"campaign"},
+		Reason: "campaigns are read-only",
+	}}})
+
+	defer auth.WithPolicyEngine(nil)
+
+	if explanation, err = fixture.Groups.Explain(ctx, alice, auth.Roles{viewer}); err != nil {
+		t.Fatal(err)
+	}
+
+	if explanation.Allowed || !explanation.Member {
+		t.Errorf("Explain = %+v, want denied by policy", explanation)
+	}
+
+	want := auth.Decision{
+		Effect: auth.EffectDeny, Reasons: []string{"readonly: campaigns are read-only"},
+	}
+
+	if !reflect.DeepEqual(explanation.Policy, want) {
+		t.Errorf("Policy = %+v, want %+v", explanation.Policy, want)
+	}
+
+	if s := explanation.String(); !strings.Contains(s, "policy deny per readonly") {
+		t.Errorf("String() = %q, want the policy's reasons", s)
+	}
+
+	ok, err := fixture.Groups.IsUserInAnyImplied(ctx, alice, auth.Roles{viewer})
+	if err != nil || ok != explanation.Allowed {
+		t.Errorf("IsUserInAnyImplied = %v, %v, want %v", ok, err, explanation.Allowed)
+	}
+
+	// Conditions that fail to evaluate fail the explanation, like the check.
+	if err = fixture.Groups.Add(ctx, bob, viewer, auth.When(`user.missing`)); err != nil {
+		t.Fatal(err)
+	}
+
+	explanation, err = fixture.Groups.Explain(ctx, bob, auth.Roles{viewer})
+	if !errors.Is(err, auth.ErrConditionEvaluation) {
+		t.Errorf("Explain = %v, want ErrConditionEvaluation", err)
+	}
+
+	if len(explanation.Matches) != 1 || explanation.Matches[0].Error == "" {
+		t.Errorf("Matches = %+v, want the failed Condition", explanation.Matches)
+	}
+}
@@ -0,0 +1,15 @@
+package auth
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+// ForgetTenantsInUse forgets that any tenant was named, until the end of the test, so
+// that it doesn't depend upon the tests that ran before it.
+func ForgetTenantsInUse(t *testing.T) {
+	prev := atomic.SwapInt32(&tenantsInUse, 0)
+	t.Cleanup(func() {
+		atomic.StoreInt32(&tenantsInUse, prev)
+	})
+}
@@ -1,6 +1,10 @@
 package auth
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	"github.com/angadn/tabular"
 )
 
@@ -28,7 +32,9 @@ func NewRole(name RoleName, resource Resource) (role Role) {
 type Roles []Role
 
 // IDs gives us all ResourceIDs in our Roles as []string. It is handy for looking up
-// lists of our entities that the User has some kind of access to.
+// lists of our entities that the User has some kind of access to. Beware that a Role
+// upon AllOf a ResourceKind contributes WildcardResourceID, which callers should check
+// for with `HasWildcard` before using the IDs as a filter.
 func (roles Roles) IDs() (ids []string) {
 	for _, role := range roles {
 		ids = append(ids, string(role.Resource.Identifier()))
@@ -37,6 +43,17 @@ func (roles Roles) IDs() (ids []string) {
 	return
 }
 
+// HasWildcard checks whether any of our Roles is upon AllOf a ResourceKind.
+func (roles Roles) HasWildcard() (ok bool) {
+	for _, role := range roles {
+		if ok = IsWildcard(role.Resource); ok {
+			return
+		}
+	}
+
+	return
+}
+
 // RolesFor is a convenience-constructor for constructing an array of Roles with the same
 // name but for different Resources. This is handy when a Role 'propagates'
 // hierarchically through a set of Resources. For example, if an "Editor" Role for an
@@ -67,6 +84,197 @@ func RolesFor(name RoleName, resources ...Resource) (roles Roles) {
 type Group struct {
 	Role  Role
 	Users []string
+	Teams []TeamID
+}
+
+// Effect of a User's assignment to a Group: either a grant of the Role, or an explicit
+// denial of it.
+type Effect string
+
+const (
+	// EffectAllow grants the Role to the User.
+	EffectAllow = Effect("allow")
+
+	// EffectDeny explicitly bars the User from the Role, overriding any grant that would
+	// otherwise satisfy a check.
+	EffectDeny = Effect("deny")
+)
+
+// Grant holds the optional bounds of a User's assignment to a Group. A zero time.Time
+// leaves the corresponding bound open, and an empty Condition always holds.
+type Grant struct {
+	NotBefore time.Time
+	ExpiresAt time.Time
+	Condition string
+}
+
+// GrantOption configures a Grant.
+type GrantOption func(grant *Grant)
+
+// NotBefore defers an assignment until the given time.
+func NotBefore(t time.Time) GrantOption {
+	return func(grant *Grant) {
+		grant.NotBefore = t
+	}
+}
+
+// ExpiresAt ends an assignment at the given time.
+func ExpiresAt(t time.Time) GrantOption {
+	return func(grant *Grant) {
+		grant.ExpiresAt = t
+	}
+}
+
+// ExpiresIn ends an assignment after the given duration from now, which is handy for
+// temporary access by contractors or support engineers.
+func ExpiresIn(d time.Duration) GrantOption {
+	return ExpiresAt(time.Now().Add(d))
+}
+
+// When attaches a Condition expression to an assignment, such that it only applies when
+// the Condition holds at check time. See ParseCondition for the expression language.
+func When(condition string) GrantOption {
+	return func(grant *Grant) {
+		grant.Condition = condition
+	}
+}
+
+// NewGrant applies GrantOptions to a zero Grant.
+func NewGrant(opts ...GrantOption) (grant Grant) {
+	for _, opt := range opts {
+		opt(&grant)
+	}
+
+	return
+}
+
+// IsActiveAt checks whether the Grant's bounds include the given time.
+func (grant Grant) IsActiveAt(t time.Time) (ok bool) {
+	if !grant.NotBefore.IsZero() && t.Before(grant.NotBefore) {
+		return
+	}
+
+	if !grant.ExpiresAt.IsZero() && !t.Before(grant.ExpiresAt) {
+		return
+	}
+
+	ok = true
+	return
+}
+
+// Validate checks that the Grant's Condition, if any, parses.
+func (grant Grant) Validate() (err error) {
+	if grant.Condition != "" {
+		_, err = parseConditionCached(grant.Condition)
+	}
+
+	return
+}
+
+// Assignment is a single member of a Group: a Principal's grant or denial of a Role.
+type Assignment struct {
+	Role      Role
+	Principal Principal
+	Effect    Effect
+	Grant     Grant
+}
+
+// AppliesTo checks whether the Assignment is for the given Role, either upon the same
+// Resource or upon AllOf it's kind.
+func (a Assignment) AppliesTo(role Role) (ok bool) {
+	ok = a.Role.Name == role.Name &&
+		a.Role.Resource.Kind() == role.Resource.Kind() &&
+		(IsWildcard(a.Role.Resource) ||
+			a.Role.Resource.Identifier() == role.Resource.Identifier())
+
+	return
+}
+
+// holdsFor checks whether the Assignment's Condition, if any, holds for the given User
+// and Role.
+func (a Assignment) holdsFor(ctx context.Context, user User, role Role) (
+	ok bool, err error,
+) {
+	if a.Grant.Condition == "" {
+		ok = true
+		return
+	}
+
+	var cond Condition
+	if cond, err = parseConditionCached(a.Grant.Condition); err != nil {
+		return
+	}
+
+	ok, err = cond.Eval(ConditionAttributes(ctx, user, role.Resource))
+	return
+}
+
+// Decide whether the given User holds any of the given Roles, per the active Assignments
+// that were matched for her. Denials override grants, and Conditions are evaluated upon
+// the Context. It lets implementations of GroupRepositoryImpl share the semantics of
+// `IsUserInAny` with our own.
+func Decide(
+	ctx context.Context, user User, roles Roles, assignments []Assignment,
+) (ok bool, err error) {
+	_, ok, err = evaluate(ctx, user, roles, assignments)
+	return
+}
+
+// evaluate the active Assignments that were matched for the given User against each of
+// the given Roles they apply to. A Condition that fails to evaluate is reported in it's
+// Match, and fails the whole evaluation.
+func evaluate(
+	ctx context.Context, user User, roles Roles, assignments []Assignment,
+) (matches []Match, ok bool, err error) {
+	var denied bool
+	for _, a := range assignments {
+		for _, role := range roles {
+			if !a.AppliesTo(role) {
+				continue
+			}
+
+			m := Match{Assignment: a, Role: role}
+			if holds, evalErr := a.holdsFor(ctx, user, role); evalErr != nil {
+				m.Error = evalErr.Error()
+				if err == nil {
+					err = evalErr
+				}
+			} else {
+				m.Holds = holds
+			}
+
+			if m.Holds && a.Effect == EffectDeny {
+				denied = true
+			} else if m.Holds {
+				ok = true
+			}
+
+			matches = append(matches, m)
+		}
+	}
+
+	if denied || err != nil {
+		ok = false
+	}
+
+	return
+}
+
+var conditions sync.Map
+
+// parseConditionCached parses a Condition, memoizing it for subsequent checks.
+func parseConditionCached(src string) (cond Condition, err error) {
+	if cached, ok := conditions.Load(src); ok {
+		cond = cached.(Condition)
+		return
+	}
+
+	if cond, err = ParseCondition(src); err != nil {
+		return
+	}
+
+	conditions.Store(src, cond)
+	return
 }
 
 // table is a tabular representation of Groups, and helps us persist them in an SQL
@@ -74,10 +282,16 @@ type Group struct {
 var table = tabular.New(
 	"groups",
 
+	"tenant_id",
 	"resource_kind",
 	"resource_id",
 	"role_name",
 	"user_id",
+	"principal_type",
+	"effect",
+	"not_before",
+	"expires_at",
+	"condition",
 	"created_at",
 	"updated_at",
 )
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"database/sql"
+)
+
+// GroupPostgresRepository implements GroupRepository in PostgreSQL. It shares it's
+// queries and semantics with GroupMySQLRepository, rebound to PostgreSQL's syntax with
+// `ON CONFLICT` upserts, double-quoted identifiers and `$n` placeholders. Bring your
+// own driver, such as github.com/lib/pq or github.com/jackc/pgx/v4/stdlib.
+type GroupPostgresRepository struct {
+	groupSQLRepository
+}
+
+// NewGroupPostgresRepositoryImpl is a constructor for GroupPostgresRepository, which
+// takes the same TableOptions as NewGroupMySQLRepositoryImpl.
+func NewGroupPostgresRepositoryImpl(db *sql.DB, opts ...TableOption) (
+	repo GroupRepository, err error,
+) {
+	pgRepo := new(GroupPostgresRepository)
+	pgRepo.db = db
+	pgRepo.dialect = postgresDialect{}
+	pgRepo.groupTable = NewGroupTable(opts...)
+	repo.GroupRepositoryImpl = pgRepo
+	if err = pgRepo.groupTable.Validate(); err != nil {
+		return
+	}
+
+	err = pgRepo.db.Ping()
+	return
+}
+
+// TeamPostgresRepository implements TeamRepository in PostgreSQL.
+type TeamPostgresRepository struct {
+	teamSQLRepository
+}
+
+// NewTeamPostgresRepositoryImpl is a constructor for TeamPostgresRepository.
+func NewTeamPostgresRepositoryImpl(db *sql.DB) (repo TeamRepository, err error) {
+	pgRepo := new(TeamPostgresRepository)
+	pgRepo.db = db
+	pgRepo.dialect = postgresDialect{}
+	repo.TeamRepositoryImpl = pgRepo
+	err = pgRepo.db.Ping()
+	return
+}
+
+// InvitationPostgresRepository implements InvitationRepository in PostgreSQL.
+type InvitationPostgresRepository struct {
+	invitationSQLRepository
+}
+
+// NewInvitationPostgresRepositoryImpl is a constructor for InvitationPostgresRepository.
+func NewInvitationPostgresRepositoryImpl(db *sql.DB) (
+	repo InvitationRepository, err error,
+) {
+	pgRepo := new(InvitationPostgresRepository)
+	pgRepo.db = db
+	pgRepo.dialect = postgresDialect{}
+	repo.InvitationRepositoryImpl = pgRepo
+	err = pgRepo.db.Ping()
+	return
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/angadn/tabular"
 )
@@ -17,10 +18,18 @@ func WithGroupRepository(r GroupRepository) {
 	Groups.GroupRepositoryImpl = r
 }
 
-// GroupRepositoryImpl defines an interface with which we can persist our Groups.
+// GroupRepositoryImpl defines an interface with which we can persist our Groups. Every
+// method reads and writes within the tenant that it's Context is scoped to alone. Further
+// capabilities, such as denials, bulk writes and pagination, are optional interfaces that
+// GroupRepository checks it's GroupRepositoryImpl for, failing with ErrUnsupported.
 type GroupRepositoryImpl interface {
-	Add(ctx context.Context, user User, role Role) (err error)
+	Add(ctx context.Context, user User, role Role, opts ...GrantOption) (err error)
+	AddTeam(ctx context.Context, team TeamID, role Role, opts ...GrantOption) (err error)
+	Assignments(ctx context.Context, user User, roles Roles) (
+		assignments []Assignment, err error,
+	)
 	Delete(ctx context.Context, user User, role Role) (err error)
+	DeleteTeam(ctx context.Context, team TeamID, role Role) (err error)
 	Find(ctx context.Context, role Role) (group Group, err error)
 	Free(ctx context.Context, resource Resource) (err error)
 	IsUserInAny(ctx context.Context, user User, roles Roles) (ok bool, err error)
@@ -29,13 +38,30 @@ type GroupRepositoryImpl interface {
 	)
 }
 
+// ErrUnsupported when a GroupRepositoryImpl doesn't implement the optional interface that
+// a method of GroupRepository requires.
+var ErrUnsupported = fmt.Errorf("unsupported by the GroupRepositoryImpl")
+
+// DenialRepositoryImpl is implemented by GroupRepositoryImpls that persist explicit
+// denials.
+type DenialRepositoryImpl interface {
+	Deny(ctx context.Context, user User, role Role, opts ...GrantOption) (err error)
+	Denials(ctx context.Context, resource Resource) (groups []Group, err error)
+}
+
+// ExpiryRepositoryImpl is implemented by GroupRepositoryImpls that can delete expired
+// assignments.
+type ExpiryRepositoryImpl interface {
+	PurgeExpired(ctx context.Context) (n int64, err error)
+}
+
 // GroupRepository persists our Groups using an underlying GroupRepositoryImpl.
 type GroupRepository struct {
 	GroupRepositoryImpl
 }
 
 // IsInAny is a convenience-method that calls `IsUserInAny` with the User in the current
-// Context.
+// Context, and consults the configured PolicyEngine on it's outcome.
 func (repo GroupRepository) IsInAny(ctx context.Context, roles Roles) (
 	ok bool, err error,
 ) {
@@ -44,73 +70,226 @@ func (repo GroupRepository) IsInAny(ctx context.Context, roles Roles) (
 		return
 	}
 
-	ok, err = repo.IsUserInAny(ctx, user, roles)
+	if ok, err = repo.IsUserInAny(ctx, user, roles); err != nil {
+		return
+	}
+
+	ok, err = consult(ctx, rolesRequest(user, roles, ok))
+	return
+}
+
+// Deny a User a Role, which overrides any grant of the same Role, failing with
+// ErrUnsupported unless it's GroupRepositoryImpl is a DenialRepositoryImpl.
+func (repo GroupRepository) Deny(
+	ctx context.Context, user User, role Role, opts ...GrantOption,
+) (err error) {
+	impl, ok := repo.GroupRepositoryImpl.(DenialRepositoryImpl)
+	if !ok {
+		err = fmt.Errorf("%w: Deny", ErrUnsupported)
+		return
+	}
+
+	err = impl.Deny(ctx, user, role, opts...)
+	return
+}
+
+// Denials lists the Users explicitly denied each Role upon the given Resource, failing
+// with ErrUnsupported unless it's GroupRepositoryImpl is a DenialRepositoryImpl.
+func (repo GroupRepository) Denials(ctx context.Context, resource Resource) (
+	groups []Group, err error,
+) {
+	impl, ok := repo.GroupRepositoryImpl.(DenialRepositoryImpl)
+	if !ok {
+		err = fmt.Errorf("%w: Denials", ErrUnsupported)
+		return
+	}
+
+	groups, err = impl.Denials(ctx, resource)
 	return
 }
 
+// PurgeExpired deletes every assignment of the tenant whose expiry has passed, failing
+// with ErrUnsupported unless it's GroupRepositoryImpl is an ExpiryRepositoryImpl.
+func (repo GroupRepository) PurgeExpired(ctx context.Context) (n int64, err error) {
+	impl, ok := repo.GroupRepositoryImpl.(ExpiryRepositoryImpl)
+	if !ok {
+		err = fmt.Errorf("%w: PurgeExpired", ErrUnsupported)
+		return
+	}
+
+	n, err = impl.PurgeExpired(ctx)
+	return
+}
+
+// SweepExpired calls `PurgeExpired` on every tick of the given interval until the
+// Context is done, reporting any errors to `onError` if it isn't nil. It blocks, and is
+// intended to be run in it's own goroutine.
+func (repo GroupRepository) SweepExpired(
+	ctx context.Context, interval time.Duration, onError func(err error),
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := repo.PurgeExpired(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// groupSQLRepository implements GroupRepository in SQL, per it's dialect.
+type groupSQLRepository struct {
+	db         *sql.DB
+	tx         *sql.Tx
+	dialect    dialect
+	groupTable GroupTable
+}
+
 // GroupMySQLRepository implements GroupRepository in MySQL.
 type GroupMySQLRepository struct {
-	db *sql.DB
+	groupSQLRepository
 }
 
-// NewGroupMySQLRepositoryImpl is a constructor for GroupMySQLRepository.
-func NewGroupMySQLRepositoryImpl(db *sql.DB) (repo GroupRepository, err error) {
+// NewGroupMySQLRepositoryImpl is a constructor for GroupMySQLRepository. TableOptions
+// name the table that it persists to, and it's columns, when they differ from our
+// defaults.
+func NewGroupMySQLRepositoryImpl(db *sql.DB, opts ...TableOption) (
+	repo GroupRepository, err error,
+) {
 	mysqlRepo := new(GroupMySQLRepository)
 	mysqlRepo.db = db
+	mysqlRepo.dialect = mysqlDialect{}
+	mysqlRepo.groupTable = NewGroupTable(opts...)
 	repo.GroupRepositoryImpl = mysqlRepo
+	if err = mysqlRepo.groupTable.Validate(); err != nil {
+		return
+	}
+
 	err = mysqlRepo.db.Ping()
 	return
 }
 
+// groupKey is the unique key of our table, which `Add` depends upon to be idempotent.
+var groupKey = []string{
+	"tenant_id", "resource_kind", "resource_id", "role_name", "user_id", "principal_type",
+}
+
+func (repo *groupSQLRepository) exec(
+	ctx context.Context, query string, args ...interface{},
+) (sql.Result, error) {
+	return repo.conn(ctx).ExecContext(ctx, repo.sql(query), args...)
+}
+
+func (repo *groupSQLRepository) query(
+	ctx context.Context, query string, args ...interface{},
+) (*sql.Rows, error) {
+	return repo.conn(ctx).QueryContext(ctx, repo.sql(query), args...)
+}
+
+func (repo *groupSQLRepository) queryRow(
+	ctx context.Context, query string, args ...interface{},
+) *sql.Row {
+	return repo.conn(ctx).QueryRowContext(ctx, repo.sql(query), args...)
+}
+
+// sql rewrites a query written against our default names in MySQL's syntax for the
+// repository's table and dialect.
+func (repo *groupSQLRepository) sql(query string) string {
+	return repo.dialect.rebind(repo.groupTable.rename(query))
+}
+
 // Add a User to a Group for the given Role, creating a Group if it doesn't exist. Add
 // is an idempotent action and does nothing silently if the User already has the given
-// Role.
-func (repo *GroupMySQLRepository) Add(
-	ctx context.Context, user User, role Role,
+// Role. Adding a User who was denied the Role replaces the denial with a grant, and the
+// bounds of the given GrantOptions replace any previous ones, so that adding a User
+// without an expiry makes their assignment permanent.
+func (repo *groupSQLRepository) Add(
+	ctx context.Context, user User, role Role, opts ...GrantOption,
 ) (err error) {
-	var (
-		kind = role.Resource.Kind()
-		id   = role.Resource.Identifier()
-	)
+	err = repo.assign(ctx, PrincipalOf(user), role, EffectAllow, NewGrant(opts...))
+	return
+}
 
-	_, err = repo.db.ExecContext(ctx, table.Insertion(
-		"%s ON DUPLICATE KEY UPDATE `user_id` = VALUES(`user_id`)",
-		"created_at", "NOW()",
-		"updated_at", "NOW()",
-	),
-		string(kind),
-		string(id),
-		string(role.Name),
-		user.GetID(),
-	)
+// AddTeam adds a Team to a Group for the given Role, just like `Add` does for a User.
+// Every member of the Team, direct or through other Teams, is granted the Role.
+func (repo *groupSQLRepository) AddTeam(
+	ctx context.Context, team TeamID, role Role, opts ...GrantOption,
+) (err error) {
+	err = repo.assign(ctx, team.Principal(), role, EffectAllow, NewGrant(opts...))
+	return
+}
+
+// Deny explicitly bars a User from the given Role, which overrides any grant that would
+// otherwise satisfy `IsUserInAny`. Denying a User who was granted the Role replaces the
+// grant with a denial, which fails with ErrLastMember if she's the last Member of a
+// required Role.
+func (repo *groupSQLRepository) Deny(
+	ctx context.Context, user User, role Role, opts ...GrantOption,
+) (err error) {
+	err = repo.assign(ctx, PrincipalOf(user), role, EffectDeny, NewGrant(opts...))
+	return
+}
+
+// assign upserts a Principal's assignment to the Group for the given Role.
+func (repo *groupSQLRepository) assign(
+	ctx context.Context,
+	principal Principal,
+	role Role,
+	effect Effect,
+	grant Grant,
+) (err error) {
+	members := []Member{{Role: role, Principal: principal}}
+	if effect == EffectAllow || !Required.IsRequired(role.Resource.Kind(), role.Name) {
+		err = repo.assignMany(ctx, members, effect, grant)
+		return
+	}
+
+	// A denial replaces any grant, so it mustn't replace the last one of a required Role.
+	err = repo.transact(ctx, func(txRepo *groupSQLRepository) (err error) {
+		if err = txRepo.guard(ctx, members); err != nil {
+			return
+		}
+
+		err = txRepo.assignMany(ctx, members, effect, grant)
+		return
+	})
 
 	return
 }
 
-// Delete a Role for a User.
-func (repo *GroupMySQLRepository) Delete(
+// Delete a Role for a User, removing either a grant or a denial. Deleting the last Member
+// of a required Role fails with ErrLastMember.
+func (repo *groupSQLRepository) Delete(
 	ctx context.Context, user User, role Role,
 ) (err error) {
-	var (
-		kind = role.Resource.Kind()
-		id   = role.Resource.Identifier()
-	)
+	err = repo.unassign(ctx, PrincipalOf(user), role)
+	return
+}
 
-	_, err = repo.db.ExecContext(
-		ctx,
-		"DELETE FROM `groups` WHERE `resource_kind` = ? AND `resource_id` = ? AND `role_name` = ? AND `user_id` = ?",
-		string(kind),
-		string(id),
-		string(role.Name),
-		user.GetID(),
-	)
+// DeleteTeam deletes a Role for a Team, just like `Delete` does for a User.
+func (repo *groupSQLRepository) DeleteTeam(
+	ctx context.Context, team TeamID, role Role,
+) (err error) {
+	err = repo.unassign(ctx, team.Principal(), role)
+	return
+}
 
+// unassign deletes a Principal's assignment to the Group for the given Role.
+func (repo *groupSQLRepository) unassign(
+	ctx context.Context, principal Principal, role Role,
+) (err error) {
+	err = repo.remove(ctx, []Member{{Role: role, Principal: principal}})
 	return
 }
 
 // Free deletes all Groups attached to the given Resource. It is intended to be called at
 // the end of the Resource's lifecycle.
-func (repo *GroupMySQLRepository) Free(ctx context.Context, resource Resource) (
+func (repo *groupSQLRepository) Free(ctx context.Context, resource Resource) (
 	err error,
 ) {
 	var (
@@ -118,9 +297,10 @@ func (repo *GroupMySQLRepository) Free(ctx context.Context, resource Resource) (
 		id   = resource.Identifier()
 	)
 
-	_, err = repo.db.ExecContext(
+	_, err = repo.exec(
 		ctx,
-		"DELETE FROM `groups` WHERE `resource_kind` = ? AND `resource_id` = ?",
+		"DELETE FROM `groups` WHERE `tenant_id` = ? AND `resource_kind` = ? AND `resource_id` = ?",
+		string(TenantFromContext(ctx)),
 		string(kind),
 		string(id),
 	)
@@ -128,9 +308,10 @@ func (repo *GroupMySQLRepository) Free(ctx context.Context, resource Resource) (
 	return
 }
 
-// Find a Group of Users that are assigned a given Role. This allows us to visually list
-// them and allow for the end-user to reconfigure our Groups.
-func (repo *GroupMySQLRepository) Find(ctx context.Context, role Role) (
+// Find a Group of Users and Teams that are granted a given Role. This allows us to visually list
+// them and allow for the end-user to reconfigure our Groups. Denied Users are listed by
+// `Denials` instead, and assignments outside their bounds are left out.
+func (repo *groupSQLRepository) Find(ctx context.Context, role Role) (
 	group Group, err error,
 ) {
 	var (
@@ -138,13 +319,19 @@ func (repo *GroupMySQLRepository) Find(ctx context.Context, role Role) (
 		id   = role.Resource.Identifier()
 	)
 
+	now := time.Now().UTC()
+
 	var rows *sql.Rows
-	if rows, err = repo.db.QueryContext(ctx, table.Selection(
-		"SELECT %s FROM `groups` WHERE `groups`.`resource_kind` = ? AND `groups`.`resource_id` = ? AND `groups`.`role_name` = ?",
+	if rows, err = repo.query(ctx, table.Selection(
+		"SELECT %s FROM `groups` WHERE `groups`.`tenant_id` = ? AND `groups`.`resource_kind` = ? AND `groups`.`resource_id` = ? AND `groups`.`role_name` = ? AND `groups`.`effect` = ? AND "+isActive,
 	),
+		string(TenantFromContext(ctx)),
 		string(kind),
 		string(id),
 		string(role.Name),
+		string(EffectAllow),
+		now,
+		now,
 	); err != nil {
 		return
 	}
@@ -153,37 +340,230 @@ func (repo *GroupMySQLRepository) Find(ctx context.Context, role Role) (
 
 	for rows.Next() {
 		var (
-			res    resourceImpl
-			userID string
+			res       resourceImpl
+			principal Principal
 		)
 
-		if err = tabular.NewScanner(
+		if err = newScanner(
+			&tabular.Scapegoat{},
 			&res.kind,
 			&res.id,
 			&group.Role.Name,
+			&principal.ID,
+			&principal.Type,
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+		).Scan(rows); err != nil {
+			return
+		}
+
+		switch principal.Type {
+		case TeamPrincipal:
+			group.Teams = append(group.Teams, TeamID(principal.ID))
+		default:
+			group.Users = append(group.Users, principal.ID)
+		}
+	}
+
+	group.Role = role
+	err = rows.Err()
+	return
+}
+
+// Denials lists the Users explicitly denied each Role upon the given Resource, as one
+// Group per RoleName. Denials outside their bounds are left out.
+func (repo *groupSQLRepository) Denials(ctx context.Context, resource Resource) (
+	groups []Group, err error,
+) {
+	var (
+		kind = resource.Kind()
+		id   = resource.Identifier()
+	)
+
+	now := time.Now().UTC()
+
+	var rows *sql.Rows
+	if rows, err = repo.query(ctx, table.Selection(
+		"SELECT %s FROM `groups` WHERE `groups`.`tenant_id` = ? AND `groups`.`resource_kind` = ? AND `groups`.`resource_id` = ? AND `groups`.`effect` = ? AND "+isActive+" ORDER BY `groups`.`role_name`",
+	),
+		string(TenantFromContext(ctx)),
+		string(kind),
+		string(id),
+		string(EffectDeny),
+		now,
+		now,
+	); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			name   RoleName
+			userID string
+		)
+
+		if err = newScanner(
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&name,
 			&userID,
 			&tabular.Scapegoat{},
 			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
 		).Scan(rows); err != nil {
 			return
 		}
 
-		group.Users = append(group.Users, userID)
+		if n := len(groups); n == 0 || groups[n-1].Role.Name != name {
+			groups = append(groups, Group{Role: NewRole(name, resource)})
+		}
+
+		groups[len(groups)-1].Users = append(groups[len(groups)-1].Users, userID)
 	}
 
+	err = rows.Err()
 	return
 }
 
-// IsUserInAny checks whether the given User has one or more of the given Roles. It's results
-// can only be reliably consumed when `err` is `nil`.
-func (repo *GroupMySQLRepository) IsUserInAny(ctx context.Context, user User, roles Roles) (
+// IsUserInAny checks whether the given User, or any Team she transitively belongs to, has
+// one or more of the given Roles. A denial of any of the given Roles overrides every
+// grant, and assignments outside their bounds or whose Conditions don't hold are
+// ignored. Roles upon AllOf a Resource's kind satisfy the same Roles upon that Resource.
+// It's results can only be reliably consumed when `err` is `nil`.
+//
+// It's decided with `EXISTS` checks alone unless any of the matching assignments bear a
+// Condition, which are then loaded with `Assignments` and evaluated by `Decide`.
+func (repo *groupSQLRepository) IsUserInAny(ctx context.Context, user User, roles Roles) (
 	ok bool, err error,
 ) {
-	if ok = IsMaster(user.GetID(), user.GetSecret()); ok {
+	if ok = IsMasterIn(ctx, user); ok {
+		return
+	}
+
+	if len(roles) == 0 {
+		return
+	}
+
+	var (
+		where string
+		args  []interface{}
+	)
+
+	if where, args, err = repo.assignmentsOf(ctx, user, roles); err != nil {
+		return
+	}
+
+	const unconditional = "(`groups`.`condition` IS NULL OR `groups`.`condition` = '')"
+
+	var params []interface{}
+	for _, effect := range []Effect{EffectAllow, EffectDeny} {
+		params = append(append(params, args...), string(effect))
+	}
+
+	params = append(params, args...)
+
+	var granted, denied, conditional bool
+	if err = repo.queryRow(ctx, fmt.Sprintf(
+		"SELECT EXISTS(SELECT 1 FROM `groups` WHERE %[1]s AND %[2]s AND `groups`.`effect` = ?), EXISTS(SELECT 1 FROM `groups` WHERE %[1]s AND %[2]s AND `groups`.`effect` = ?), EXISTS(SELECT 1 FROM `groups` WHERE %[1]s AND NOT %[2]s)",
+		where,
+		unconditional,
+	), params...).Scan(&granted, &denied, &conditional); err != nil {
+		return
+	}
+
+	if !conditional {
+		ok = granted && !denied
+		return
+	}
+
+	var assignments []Assignment
+	if assignments, err = repo.Assignments(ctx, user, roles); err != nil {
+		return
+	}
+
+	ok, err = Decide(ctx, user, roles, assignments)
+	return
+}
+
+// Assignments lists the active Assignments of the given User, and of every Team she
+// transitively belongs to, to any of the given Roles or to the same Roles upon AllOf
+// their Resources' kinds. Their Conditions are left unevaluated.
+func (repo *groupSQLRepository) Assignments(
+	ctx context.Context, user User, roles Roles,
+) (assignments []Assignment, err error) {
+	var (
+		where string
+		args  []interface{}
+	)
+
+	if where, args, err = repo.assignmentsOf(ctx, user, roles); err != nil {
+		return
+	}
+
+	var rows *sql.Rows
+	if rows, err = repo.query(
+		ctx, table.Selection("SELECT %s FROM `groups` WHERE "+where), args...,
+	); err != nil {
 		return
 	}
 
-	var args []interface{}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			a   Assignment
+			res resourceImpl
+		)
+
+		if err = newScanner(
+			&tabular.Scapegoat{},
+			&res.kind,
+			&res.id,
+			&a.Role.Name,
+			&a.Principal.ID,
+			&a.Principal.Type,
+			&a.Effect,
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&a.Grant.Condition,
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+		).Scan(rows); err != nil {
+			return
+		}
+
+		a.Role.Resource = res
+		assignments = append(assignments, a)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// assignmentsOf resolves an SQL condition matching the active assignments of the given
+// User, and of every Team she transitively belongs to, to any of the given Roles or to
+// the same Roles upon AllOf their Resources' kinds, along with it's parameters.
+func (repo *groupSQLRepository) assignmentsOf(
+	ctx context.Context, user User, roles Roles,
+) (where string, args []interface{}, err error) {
+	var principals string
+	if principals, args, err = repo.principalsOf(ctx, user); err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	args = append(args, string(TenantFromContext(ctx)), now, now)
 	for _, r := range roles {
 		var (
 			kind = r.Resource.Kind()
@@ -194,62 +574,354 @@ func (repo *GroupMySQLRepository) IsUserInAny(ctx context.Context, user User, ro
 			args,
 			string(kind),
 			string(id),
+			string(WildcardResourceID),
 			string(r.Name),
-			user.GetID(),
 		)
 	}
 
-	if len(roles) == 0 {
-		return
-	}
-
-	err = repo.db.QueryRowContext(ctx, fmt.Sprintf(
-		"SELECT IF(COUNT(*), \"true\", \"false\") FROM `groups` WHERE %s",
+	where = fmt.Sprintf(
+		"%s AND `groups`.`tenant_id` = ? AND %s AND (%s)",
+		principals,
+		isActive,
 		strings.TrimRight(strings.Repeat(
-			"(`groups`.`resource_kind` = ? AND `groups`.`resource_id` = ? AND `groups`.`role_name` = ? AND `groups`.`user_id` = ?) OR", len(roles),
+			"(`groups`.`resource_kind` = ? AND `groups`.`resource_id` IN (?, ?) AND `groups`.`role_name` = ?) OR", len(roles),
 		), " OR"),
-	), args...).Scan(&ok)
+	)
 
 	return
 }
 
 // Resources lists all of the Resources of a given ResourceKind that a User has access to
-// via any Role assigned to her.
-func (repo *GroupMySQLRepository) Resources(
+// via any Role granted to her or to any Team she transitively belongs to, leaving out
+// assignments outside their bounds. Roles upon AllOf the given kind are listed with
+// WildcardResourceID. Conditions can only be evaluated at check time, so Roles granted
+// with a Condition are listed regardless of it.
+func (repo *groupSQLRepository) Resources(
 	ctx context.Context, kind ResourceKind, user User,
 ) (roles Roles, err error) {
+	var (
+		principals string
+		args       []interface{}
+	)
+
+	if principals, args, err = repo.principalsOf(ctx, user); err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	args = append(args, string(TenantFromContext(ctx)), now, now, string(kind), string(EffectAllow))
+
 	var rows *sql.Rows
-	if rows, err = repo.db.QueryContext(ctx, table.Selection(
-		"SELECT %s FROM `groups` WHERE `groups`.`resource_kind` = ? AND `groups`.`user_id` = ?",
-	),
-		string(kind),
-		user.GetID(),
-	); err != nil {
+	if rows, err = repo.query(ctx, table.Selection(
+		"SELECT %s FROM `groups` WHERE "+principals+" AND `groups`.`tenant_id` = ? AND "+isActive+" AND `groups`.`resource_kind` = ? AND `groups`.`effect` = ?",
+	), args...); err != nil {
 		return
 	}
 
 	defer rows.Close()
 
+	seen := make(map[Role]bool)
 	for rows.Next() {
 		var (
 			role Role
 			res  resourceImpl
 		)
 
-		if err = tabular.NewScanner(
+		if err = newScanner(
+			&tabular.Scapegoat{},
 			&res.kind,
 			&res.id,
 			&role.Name,
 			&tabular.Scapegoat{},
 			&tabular.Scapegoat{},
 			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
 		).Scan(rows); err != nil {
 			return
 		}
 
 		role.Resource = res
+		if seen[role] {
+			continue
+		}
+
+		seen[role] = true
 		roles = append(roles, role)
 	}
 
+	err = rows.Err()
+	return
+}
+
+// FindPage lists a page of the Users and Teams that are granted any Role upon the given
+// Resource, or any of the Page's RoleNames, sorted by when they were granted it. Like
+// `Find`, it leaves out denials and assignments outside their bounds.
+func (repo *groupSQLRepository) FindPage(
+	ctx context.Context, resource Resource, page Page,
+) (members MemberPage, err error) {
+	var (
+		kind = resource.Kind()
+		id   = resource.Identifier()
+		now  = time.Now().UTC()
+		keys = []string{"`role_name`", "`principal_type`", "`user_id`"}
+	)
+
+	filter := "`tenant_id` = ? AND `resource_kind` = ? AND `resource_id` = ? AND `effect` = ? AND " + isActive
+	args := []interface{}{
+		string(TenantFromContext(ctx)),
+		string(kind),
+		string(id),
+		string(EffectAllow),
+		now,
+		now,
+	}
+
+	if len(page.RoleNames) > 0 {
+		filter += " AND `role_name` IN (" + placeholders(len(page.RoleNames)) + ")"
+		for _, name := range page.RoleNames {
+			args = append(args, string(name))
+		}
+	}
+
+	if err = repo.queryRow(
+		ctx, "SELECT COUNT(*) FROM `groups` WHERE "+filter, args...,
+	).Scan(&members.Total); err != nil {
+		return
+	}
+
+	if page.Cursor != "" {
+		var cursor Cursor
+		if cursor, err = ParseCursor(page.Cursor, len(keys)); err != nil {
+			return
+		}
+
+		fragment, cursorArgs := keyset(cursor, page.Order, keys...)
+		filter += " AND " + fragment
+		args = append(args, cursorArgs...)
+	}
+
+	limit := page.Size()
+
+	var rows *sql.Rows
+	if rows, err = repo.query(ctx, table.Selection(fmt.Sprintf(
+		"SELECT %%s FROM `groups` WHERE %s %s LIMIT %d",
+		filter,
+		orderBy(page.Order, keys...),
+		limit+1,
+	)), args...); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var m Member
+		if err = newScanner(
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&m.Role.Name,
+			&m.Principal.ID,
+			&m.Principal.Type,
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			timestamp{&m.CreatedAt},
+			&tabular.Scapegoat{},
+		).Scan(rows); err != nil {
+			return
+		}
+
+		if len(members.Members) == limit {
+			last := members.Members[limit-1]
+			members.Next = NewCursor(
+				last.CreatedAt,
+				string(last.Role.Name),
+				string(last.Principal.Type),
+				last.Principal.ID,
+			).String()
+
+			break
+		}
+
+		m.Role.Resource = resource
+		members.Members = append(members.Members, m)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// ResourcesPage lists a page of the Roles that a User holds upon Resources of a given
+// ResourceKind, or any of the Page's RoleNames, whether granted to her or to any Team she
+// transitively belongs to. Like `Resources`, it leaves out assignments outside their
+// bounds, and lists Roles granted with a Condition regardless of it.
+//
+// Unlike `FindPage`, the listing is sorted by ResourceID and then RoleName, ascending
+// when OldestFirst and descending when NewestFirst, rather than by creation times: a Role
+// held through several grants has no single time that survives revoking one of them, and
+// it would otherwise move between pages.
+func (repo *groupSQLRepository) ResourcesPage(
+	ctx context.Context, kind ResourceKind, user User, page Page,
+) (roles ResourcePage, err error) {
+	var (
+		principals string
+		args       []interface{}
+		keys       = []string{"`resource_id`", "`role_name`"}
+	)
+
+	if principals, args, err = repo.principalsOf(ctx, user); err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	args = append(args, string(TenantFromContext(ctx)), now, now, string(kind), string(EffectAllow))
+
+	filter := principals + " AND `groups`.`tenant_id` = ? AND " + isActive + " AND `groups`.`resource_kind` = ? AND `groups`.`effect` = ?"
+	if len(page.RoleNames) > 0 {
+		filter += " AND `groups`.`role_name` IN (" + placeholders(len(page.RoleNames)) + ")"
+		for _, name := range page.RoleNames {
+			args = append(args, string(name))
+		}
+	}
+
+	// A Role granted both to the User and to her Teams is listed once.
+	held := "(SELECT DISTINCT `groups`.`resource_id` AS `resource_id`, `groups`.`role_name` AS `role_name` FROM `groups` WHERE " + filter + ") AS `held`"
+
+	if err = repo.queryRow(
+		ctx, "SELECT COUNT(*) FROM "+held, args...,
+	).Scan(&roles.Total); err != nil {
+		return
+	}
+
+	query := "SELECT `resource_id`, `role_name` FROM " + held
+	if page.Cursor != "" {
+		var cursor Cursor
+		if cursor, err = ParseCursor(page.Cursor, len(keys)); err != nil {
+			return
+		}
+
+		fragment, cursorArgs := keysetByKey(cursor, page.Order, keys...)
+		query += " WHERE " + fragment
+		args = append(args, cursorArgs...)
+	}
+
+	limit := page.Size()
+
+	var rows *sql.Rows
+	if rows, err = repo.query(ctx, fmt.Sprintf(
+		"%s %s LIMIT %d", query, orderByKey(page.Order, keys...), limit+1,
+	), args...); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			role Role
+			res  = resourceImpl{kind: kind}
+		)
+
+		if err = newScanner(&res.id, &role.Name).Scan(rows); err != nil {
+			return
+		}
+
+		if len(roles.Roles) == limit {
+			prev := roles.Roles[limit-1]
+			roles.Next = NewCursor(
+				time.Time{},
+				string(prev.Resource.Identifier()),
+				string(prev.Name),
+			).String()
+
+			break
+		}
+
+		role.Resource = res
+		roles.Roles = append(roles.Roles, role)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// PurgeExpired deletes every assignment of the tenant whose expiry has passed, returning
+// the number of assignments deleted. Expired assignments are already ignored by every
+// other method, so purging is merely housekeeping, and can be run on a ticker with
+// `SweepExpired` upon a Context scoped to each tenant.
+func (repo *groupSQLRepository) PurgeExpired(ctx context.Context) (
+	n int64, err error,
+) {
+	var res sql.Result
+	if res, err = repo.exec(
+		ctx,
+		"DELETE FROM `groups` WHERE `tenant_id` = ? AND `expires_at` IS NOT NULL AND `expires_at` <= ?",
+		string(TenantFromContext(ctx)),
+		time.Now().UTC(),
+	); err != nil {
+		return
+	}
+
+	n, err = res.RowsAffected()
+	return
+}
+
+// isActive is an SQL fragment that excludes assignments outside their bounds. It takes
+// the current time, in UTC, as two parameters.
+const isActive = "(`groups`.`not_before` IS NULL OR `groups`.`not_before` <= ?) AND (`groups`.`expires_at` IS NULL OR `groups`.`expires_at` > ?)"
+
+// principalsOf resolves an SQL fragment matching the assignments of the given User and
+// of every Team she transitively belongs to, along with it's parameters. Teams are
+// resolved within the repository's transaction, if any, lest they wait upon a connection
+// that the transaction holds.
+func (repo *groupSQLRepository) principalsOf(ctx context.Context, user User) (
+	fragment string, args []interface{}, err error,
+) {
+	var teams []TeamID
+	if teams, err = Teams.Resolve(repo.txContext(ctx), user); err != nil {
+		return
+	}
+
+	fragment = "(`groups`.`principal_type` = ? AND `groups`.`user_id` = ?)"
+	args = append(args, string(UserPrincipal), user.GetID())
+	if len(teams) == 0 {
+		return
+	}
+
+	fragment = fmt.Sprintf(
+		"(%s OR (`groups`.`principal_type` = ? AND `groups`.`user_id` IN (%s)))",
+		fragment,
+		placeholders(len(teams)),
+	)
+
+	args = append(args, string(TeamPrincipal))
+	for _, team := range teams {
+		args = append(args, string(team))
+	}
+
+	return
+}
+
+// placeholders is a list of n parameters, for an `IN` clause.
+func placeholders(n int) string {
+	return strings.TrimRight(strings.Repeat("?, ", n), ", ")
+}
+
+// nullString maps an empty string to NULL.
+func nullString(s string) (ns sql.NullString) {
+	ns.String, ns.Valid = s, s != ""
+	return
+}
+
+// nullTime maps a zero time.Time to NULL. Times are bound in UTC, so that they compare
+// correctly in databases that store them as text.
+func nullTime(t time.Time) (nt sql.NullTime) {
+	nt.Time, nt.Valid = t.UTC(), !t.IsZero()
 	return
 }
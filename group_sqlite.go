@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// GroupSQLiteRepository implements GroupRepository in SQLite, with the same semantics as
+// GroupMySQLRepository. It suits CLIs, edge services and unit tests that want real
+// persistence without a database server. Bring your own driver, such as the pure-Go
+// modernc.org/sqlite, and prefer a single connection with `db.SetMaxOpenConns(1)` for
+// in-memory databases, as each connection would otherwise get it's own.
+type GroupSQLiteRepository struct {
+	groupSQLRepository
+}
+
+// NewGroupSQLiteRepositoryImpl is a constructor for GroupSQLiteRepository, which takes
+// the same TableOptions as NewGroupMySQLRepositoryImpl. It migrates the database to
+// SQLiteSchema, so when naming the table with TableOptions, construct it before a
+// TeamSQLiteRepository, which would otherwise create the table with our default names.
+// SQLite's schemas are attached databases, so TableSchema isn't supported.
+func NewGroupSQLiteRepositoryImpl(db *sql.DB, opts ...TableOption) (
+	repo GroupRepository, err error,
+) {
+	sqliteRepo := new(GroupSQLiteRepository)
+	sqliteRepo.db = db
+	sqliteRepo.dialect = sqliteDialect{}
+	sqliteRepo.groupTable = NewGroupTable(opts...)
+	repo.GroupRepositoryImpl = sqliteRepo
+	if err = sqliteRepo.groupTable.Validate(); err != nil {
+		return
+	}
+
+	if sqliteRepo.groupTable.Schema != "" {
+		err = fmt.Errorf("table schema %q unsupported by SQLite", sqliteRepo.groupTable.Schema)
+		return
+	}
+
+	err = SQLiteSchema.Migrate(context.Background(), db, opts...)
+	return
+}
+
+// TeamSQLiteRepository implements TeamRepository in SQLite.
+type TeamSQLiteRepository struct {
+	teamSQLRepository
+}
+
+// NewTeamSQLiteRepositoryImpl is a constructor for TeamSQLiteRepository. It migrates the
+// database to SQLiteSchema.
+func NewTeamSQLiteRepositoryImpl(db *sql.DB) (repo TeamRepository, err error) {
+	sqliteRepo := new(TeamSQLiteRepository)
+	sqliteRepo.db = db
+	sqliteRepo.dialect = sqliteDialect{}
+	repo.TeamRepositoryImpl = sqliteRepo
+	err = SQLiteSchema.Migrate(context.Background(), db)
+	return
+}
+
+// InvitationSQLiteRepository implements InvitationRepository in SQLite.
+type InvitationSQLiteRepository struct {
+	invitationSQLRepository
+}
+
+// NewInvitationSQLiteRepositoryImpl is a constructor for InvitationSQLiteRepository. It
+// migrates the database to SQLiteSchema.
+func NewInvitationSQLiteRepositoryImpl(db *sql.DB) (repo InvitationRepository, err error) {
+	sqliteRepo := new(InvitationSQLiteRepository)
+	sqliteRepo.db = db
+	sqliteRepo.dialect = sqliteDialect{}
+	repo.InvitationRepositoryImpl = sqliteRepo
+	err = SQLiteSchema.Migrate(context.Background(), db)
+	return
+}
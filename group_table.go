@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GroupTable names the table that Groups are persisted to, along with it's columns, for
+// schemas in which our defaults collide with tables of their own. The zero GroupTable
+// names the table `groups` in the database's default schema, and it's columns as listed
+// in MySQLSchema.
+type GroupTable struct {
+	// Schema qualifies the table's Name, when it isn't in the database's default schema.
+	Schema string
+
+	// Name of the table, which defaults to `groups`.
+	Name string
+
+	// Columns maps our column names to the table's own.
+	Columns map[string]string
+}
+
+// TableOption configures a GroupTable.
+type TableOption func(t *GroupTable)
+
+// TableName names the table that Groups are persisted to.
+func TableName(name string) TableOption {
+	return func(t *GroupTable) {
+		t.Name = name
+	}
+}
+
+// TableSchema qualifies the table that Groups are persisted to with a schema, which is a
+// database in MySQL.
+func TableSchema(schema string) TableOption {
+	return func(t *GroupTable) {
+		t.Schema = schema
+	}
+}
+
+// ColumnName names a column of the table that Groups are persisted to, such as
+// `ColumnName("user_id", "member_email")`.
+func ColumnName(column string, name string) TableOption {
+	return func(t *GroupTable) {
+		if t.Columns == nil {
+			t.Columns = make(map[string]string)
+		}
+
+		t.Columns[column] = name
+	}
+}
+
+// NewGroupTable applies TableOptions to a zero GroupTable.
+func NewGroupTable(opts ...TableOption) (t GroupTable) {
+	for _, opt := range opts {
+		opt(&t)
+	}
+
+	return
+}
+
+// Validate checks that the GroupTable only names columns we know of, and that none of
+// it's names need escaping.
+func (t GroupTable) Validate() (err error) {
+	names := []string{t.Schema, t.Name}
+	for column, name := range t.Columns {
+		if !t.isColumn(column) {
+			err = fmt.Errorf("unknown column %q of table %s", column, table.Name)
+			return
+		}
+
+		if name == "" {
+			err = fmt.Errorf("empty name for column %q of table %s", column, table.Name)
+			return
+		}
+
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		if strings.ContainsAny(name, "`\"") {
+			err = fmt.Errorf("invalid identifier %q", name)
+			return
+		}
+	}
+
+	return
+}
+
+// tableName is the table's unqualified name.
+func (t GroupTable) tableName() string {
+	if t.Name == "" {
+		return table.Name
+	}
+
+	return t.Name
+}
+
+// name of the table, qualified by it's schema if any, as a quoted identifier.
+func (t GroupTable) name() string {
+	name := t.tableName()
+	if t.Schema == "" {
+		return fmt.Sprintf("`%s`", name)
+	}
+
+	return fmt.Sprintf("`%s`.`%s`", t.Schema, name)
+}
+
+// column is the table's own name for one of our columns.
+func (t GroupTable) column(column string) string {
+	if name, ok := t.Columns[column]; ok {
+		return name
+	}
+
+	return column
+}
+
+// columns are the table's own names for all of our columns.
+func (t GroupTable) columns() (columns []string) {
+	for _, column := range table.Fields {
+		columns = append(columns, t.column(column))
+	}
+
+	return
+}
+
+func (t GroupTable) isColumn(column string) bool {
+	for _, field := range table.Fields {
+		if field == column {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t GroupTable) isDefault() bool {
+	return t.Schema == "" && (t.Name == "" || t.Name == table.Name) && len(t.Columns) == 0
+}
+
+// rename the identifiers of a query written against our default names, which are quoted
+// with backticks, to the table's own.
+func (t GroupTable) rename(query string) string {
+	if t.isDefault() {
+		return query
+	}
+
+	var (
+		b        strings.Builder
+		inString bool
+	)
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			inString = !inString
+			b.WriteByte(c)
+		case inString || c != '`':
+			b.WriteByte(c)
+		default:
+			end := strings.IndexByte(query[i+1:], '`')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+
+			ident := query[i+1 : i+1+end]
+			switch {
+			case ident == table.Name:
+				b.WriteString(t.name())
+			case ident == table.Name+"_pkey":
+				// PostgreSQL names a table's primary key after it.
+				fmt.Fprintf(&b, "`%s_pkey`", t.tableName())
+			default:
+				fmt.Fprintf(&b, "`%s`", t.column(ident))
+			}
+
+			i += end + 1
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,234 @@
+package grouptest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/authtest"
+)
+
+// BusFactory returns the InvalidationBus under test. It's called once per replica of a
+// test case, and the buses it returns must deliver to one another, such as by connecting
+// to the same Redis server.
+type BusFactory func(t *testing.T) auth.InvalidationBus
+
+// Latency bounds how long an InvalidationBus under test may take to deliver.
+var Latency = 2 * time.Second
+
+// RunBus runs the conformance suite for implementations of auth.InvalidationBus, which
+// checks that GroupCaches standing in for the replicas of a service, sharing an
+// in-memory GroupRepository, see each other's writes within Latency, and that lost
+// Invalidations leave no decision stale for longer than the TTL. Like Run, it configures
+// `auth.Teams` for it's duration, and shouldn't be called from parallel tests.
+func RunBus(t *testing.T, factory BusFactory) {
+	prev := auth.Teams
+	auth.WithTeamRepository(authtest.NewTeamMemoryRepositoryImpl())
+	t.Cleanup(func() {
+		auth.Teams = prev
+	})
+
+	for _, c := range busCases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			s := busSuite{
+				suite: suite{
+					T:    t,
+					ctx:  context.Background(),
+					repo: authtest.NewGroupMemoryRepositoryImpl(),
+					kind: auth.ResourceKind(fmt.Sprintf("grouptest_%d", time.Now().UnixNano())),
+				},
+				factory: factory,
+			}
+
+			c.run(s)
+		})
+	}
+}
+
+var busCases = []struct {
+	name string
+	run  func(s busSuite)
+}{
+	{"BusDeliversAdds", testBusDeliversAdds},
+	{"BusDeliversDeletes", testBusDeliversDeletes},
+	{"BusDeliversTeams", testBusDeliversTeams},
+	{"BusDeliversBulk", testBusDeliversBulk},
+	{"BusTenants", testBusTenants},
+	{"BusBoundedStaleness", testBusBoundedStaleness},
+}
+
+// busSuite is the state of a single test case of RunBus, whose repo is the
+// GroupRepository that every replica shares.
+type busSuite struct {
+	suite
+	factory BusFactory
+}
+
+// replica is a GroupCache of the shared GroupRepository, which publishes to and is
+// subscribed to a bus of it's own. Decisions are kept for an hour unless the
+// CacheOptions say otherwise, so that they're only refreshed by Invalidations.
+func (s busSuite) replica(opts ...auth.CacheOption) (repo auth.GroupRepository) {
+	s.Helper()
+	bus := s.factory(s.T)
+	repo = auth.NewGroupCacheImpl(s.repo, append([]auth.CacheOption{
+		auth.CacheTTL(time.Hour),
+		auth.NegativeCacheTTL(time.Hour),
+		auth.CacheBus(bus, func(err error) {
+			s.Errorf("Publish: %v", err)
+		}),
+	}, opts...)...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Cleanup(cancel)
+	s.must(bus.Subscribe(ctx, repo))
+	return
+}
+
+// check whether the User holds any of the Roles per the replica, in the Context.
+func (s busSuite) check(
+	ctx context.Context, replica auth.GroupRepository, user auth.User, roles ...auth.Role,
+) (ok bool) {
+	s.Helper()
+	ok, err := replica.IsUserInAny(ctx, user, roles)
+	s.must(err)
+	return
+}
+
+// eventually expects the replica to decide as wanted within the given time.
+func (s busSuite) eventually(
+	within time.Duration,
+	ctx context.Context,
+	replica auth.GroupRepository,
+	want bool,
+	user auth.User,
+	roles ...auth.Role,
+) {
+	s.Helper()
+	for deadline := time.Now().Add(within); ; time.Sleep(10 * time.Millisecond) {
+		if s.check(ctx, replica, user, roles...) == want {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			s.Fatalf("IsUserInAny(%s, %v) = %v after %s, want %v",
+				user.GetID(), roles, !want, within, want)
+		}
+	}
+}
+
+func testBusDeliversAdds(s busSuite) {
+	a, b := s.replica(), s.replica()
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	if s.check(s.ctx, b, alice, editor) {
+		s.Fatalf("IsUserInAny = true before Add")
+	}
+
+	s.must(a.Add(s.ctx, alice, editor))
+	s.eventually(Latency, s.ctx, b, true, alice, editor)
+
+	s.must(a.Deny(s.ctx, alice, editor))
+	s.eventually(Latency, s.ctx, b, false, alice, editor)
+}
+
+func testBusDeliversDeletes(s busSuite) {
+	a, b := s.replica(), s.replica()
+	alice, bob, editor := s.user("alice"), s.user("bob"), s.role("editor", "1")
+	s.must(a.Add(s.ctx, alice, editor))
+	s.must(a.Add(s.ctx, bob, editor))
+	s.eventually(Latency, s.ctx, b, true, alice, editor)
+	s.eventually(Latency, s.ctx, b, true, bob, editor)
+
+	s.must(a.Delete(s.ctx, alice, editor))
+	s.eventually(Latency, s.ctx, b, false, alice, editor)
+
+	s.must(a.Transfer(s.ctx, editor, bob, alice))
+	s.eventually(Latency, s.ctx, b, false, bob, editor)
+	s.eventually(Latency, s.ctx, b, true, alice, editor)
+
+	s.must(a.Free(s.ctx, s.resource("1")))
+	s.eventually(Latency, s.ctx, b, false, alice, editor)
+}
+
+func testBusDeliversTeams(s busSuite) {
+	a, b := s.replica(), s.replica()
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(auth.Teams.AddMember(s.ctx, s.team("t"), auth.PrincipalOf(alice)))
+	if s.check(s.ctx, b, alice, editor) {
+		s.Fatalf("IsUserInAny = true before AddTeam")
+	}
+
+	s.must(a.AddTeam(s.ctx, s.team("t"), editor))
+	s.eventually(Latency, s.ctx, b, true, alice, editor)
+
+	s.must(a.DeleteTeam(s.ctx, s.team("t"), editor))
+	s.eventually(Latency, s.ctx, b, false, alice, editor)
+
+	// Grants upon AllOf a kind evict the decisions upon every Resource of it.
+	s.must(a.AddTeam(s.ctx, s.team("t"), auth.NewRole("editor", auth.AllOf(s.kind))))
+	s.eventually(Latency, s.ctx, b, true, alice, editor)
+}
+
+func testBusDeliversBulk(s busSuite) {
+	a, b := s.replica(), s.replica()
+	alice, bob, editor := s.user("alice"), s.user("bob"), s.role("editor", "1")
+	s.must(auth.Teams.AddMember(s.ctx, s.team("t"), auth.PrincipalOf(bob)))
+	s.check(s.ctx, b, alice, editor)
+	s.check(s.ctx, b, bob, editor)
+
+	s.must(a.AddMany(s.ctx, []auth.Group{{
+		Role:  editor,
+		Users: []string{alice.GetID()},
+		Teams: []auth.TeamID{s.team("t")},
+	}}))
+
+	s.eventually(Latency, s.ctx, b, true, alice, editor)
+	s.eventually(Latency, s.ctx, b, true, bob, editor)
+
+	s.must(a.Replace(s.ctx, s.resource("1"), nil))
+	s.eventually(Latency, s.ctx, b, false, alice, editor)
+	s.eventually(Latency, s.ctx, b, false, bob, editor)
+}
+
+func testBusTenants(s busSuite) {
+	a, b := s.replica(), s.replica()
+	x, y := s.tenants()
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(a.Add(y.ctx, alice, editor))
+	s.eventually(Latency, y.ctx, b, true, alice, editor)
+
+	if s.check(x.ctx, b, alice, editor) {
+		s.Fatalf("IsUserInAny = true in another tenant")
+	}
+
+	s.must(a.Add(x.ctx, alice, editor))
+	s.eventually(Latency, x.ctx, b, true, alice, editor)
+
+	s.must(a.Delete(y.ctx, alice, editor))
+	s.eventually(Latency, y.ctx, b, false, alice, editor)
+	if !s.check(x.ctx, b, alice, editor) {
+		s.Fatalf("IsUserInAny = false after a Delete in another tenant")
+	}
+}
+
+// testBusBoundedStaleness writes through a replica that isn't on the bus, as though it's
+// Invalidations were lost, so that another only sees it's writes once it's decisions
+// outlive their TTL.
+func testBusBoundedStaleness(s busSuite) {
+	const ttl = 300 * time.Millisecond
+
+	a := auth.NewGroupCacheImpl(s.repo)
+	b := s.replica(auth.CacheTTL(ttl), auth.NegativeCacheTTL(ttl))
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(a.Add(s.ctx, alice, editor))
+	s.eventually(Latency, s.ctx, b, true, alice, editor)
+
+	s.must(a.Delete(s.ctx, alice, editor))
+	start := time.Now()
+	s.eventually(ttl+Latency, s.ctx, b, false, alice, editor)
+	if elapsed := time.Since(start); elapsed > ttl+100*time.Millisecond {
+		s.Fatalf("stale for %s, beyond the TTL of %s", elapsed, ttl)
+	}
+}
@@ -0,0 +1,1344 @@
+// Package grouptest is a conformance suite for implementations of
+// auth.GroupRepositoryImpl, which checks that they share the semantics of
+// GroupMySQLRepository. Run it from a test in the implementation's own package:
+//
+//	func TestGroupRepository(t *testing.T) {
+//		grouptest.Run(t, func(t *testing.T) auth.GroupRepository {
+//			return NewGroupRedisRepositoryImpl(newClient(t))
+//		})
+//	}
+//
+// Test cases of optional interfaces, such as auth.BulkRepositoryImpl, are skipped for
+// implementations that fail them with auth.ErrUnsupported. RunBus does the same for
+// implementations of auth.InvalidationBus.
+package grouptest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/authtest"
+)
+
+// Factory returns the GroupRepository under test. It's called once per test case, and
+// must return a repository that holds no assignments upon the suite's Resources. Every
+// test case uses Resources of it's own, so a factory that returns the same repository
+// each time will do, as long as it starts out empty.
+type Factory func(t *testing.T) auth.GroupRepository
+
+// Concurrency is the number of goroutines that the concurrent test cases run.
+var Concurrency = 16
+
+// Run the conformance suite against the GroupRepository returned by the Factory. Team
+// memberships are resolved through `auth.Teams`, which Run configures with an in-memory
+// TeamRepository for it's duration, so that implementations are tested in isolation. As
+// that configuration is global, Run shouldn't be called from parallel tests.
+func Run(t *testing.T, factory Factory) {
+	prev := auth.Teams
+	auth.WithTeamRepository(authtest.NewTeamMemoryRepositoryImpl())
+	t.Cleanup(func() {
+		auth.Teams = prev
+	})
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			s := suite{
+				T:    t,
+				ctx:  context.Background(),
+				repo: factory(t),
+				kind: auth.ResourceKind(fmt.Sprintf("grouptest_%d", time.Now().UnixNano())),
+			}
+
+			c.run(s)
+		})
+	}
+}
+
+var cases = []struct {
+	name string
+	run  func(s suite)
+}{
+	{"AddIsIdempotent", testAddIsIdempotent},
+	{"AddThenDelete", testAddThenDelete},
+	{"DeleteMissingIsNoop", testDeleteMissingIsNoop},
+	{"IsUserInAnyWithoutRoles", testIsUserInAnyWithoutRoles},
+	{"IsUserInAnyMatchesAnyRole", testIsUserInAnyMatchesAnyRole},
+	{"IsUserInAnyIsExact", testIsUserInAnyIsExact},
+	{"FreeRemovesAllRoles", testFreeRemovesAllRoles},
+	{"FindListsUsersAndTeams", testFindListsUsersAndTeams},
+	{"FindUnknownRole", testFindUnknownRole},
+	{"DenyOverridesGrant", testDenyOverridesGrant},
+	{"AddReplacesDenial", testAddReplacesDenial},
+	{"DenyOverridesWildcard", testDenyOverridesWildcard},
+	{"Denials", testDenials},
+	{"Wildcards", testWildcards},
+	{"Expiry", testExpiry},
+	{"NotBefore", testNotBefore},
+	{"AddReplacesBounds", testAddReplacesBounds},
+	{"PurgeExpired", testPurgeExpired},
+	{"Conditions", testConditions},
+	{"InvalidCondition", testInvalidCondition},
+	{"Teams", testTeams},
+	{"NestedTeams", testNestedTeams},
+	{"Assignments", testAssignments},
+	{"Resources", testResources},
+	{"FindPage", testFindPage},
+	{"FindPageFilters", testFindPageFilters},
+	{"FindPageNewestFirst", testFindPageNewestFirst},
+	{"FindPageConcurrentInserts", testFindPageConcurrentInserts},
+	{"ResourcesPage", testResourcesPage},
+	{"InvalidCursor", testInvalidCursor},
+	{"AddMany", testAddMany},
+	{"AddManyBatches", testAddManyBatches},
+	{"DeleteMany", testDeleteMany},
+	{"Replace", testReplace},
+	{"ReplaceForeignRole", testReplaceForeignRole},
+	{"RequiredRoles", testRequiredRoles},
+	{"RequiredRolesInBulk", testRequiredRolesInBulk},
+	{"Transfer", testTransfer},
+	{"ConcurrentLastMembers", testConcurrentLastMembers},
+	{"TenantReads", testTenantReads},
+	{"TenantWrites", testTenantWrites},
+	{"TenantTeams", testTenantTeams},
+	{"TenantPurge", testTenantPurge},
+	{"TenantMaster", testTenantMaster},
+	{"ConcurrentAdds", testConcurrentAdds},
+	{"ConcurrentChecks", testConcurrentChecks},
+}
+
+// suite is the state of a single test case.
+type suite struct {
+	*testing.T
+	ctx  context.Context
+	repo auth.GroupRepository
+	kind auth.ResourceKind
+}
+
+// resource is a Resource of the test case's own kind.
+type resource struct {
+	kind auth.ResourceKind
+	id   auth.ResourceID
+}
+
+func (res resource) Identifier() auth.ResourceID {
+	return res.id
+}
+
+func (res resource) Kind() auth.ResourceKind {
+	return res.kind
+}
+
+func (s suite) resource(id string) auth.Resource {
+	return resource{kind: s.kind, id: auth.ResourceID(id)}
+}
+
+func (s suite) role(name string, id string) auth.Role {
+	return auth.NewRole(auth.RoleName(name), s.resource(id))
+}
+
+func (s suite) user(id string) auth.User {
+	return authtest.NewUser(fmt.Sprintf("%s_%s", s.kind, id))
+}
+
+func (s suite) team(id string) auth.TeamID {
+	return auth.TeamID(fmt.Sprintf("%s_%s", s.kind, id))
+}
+
+// tenants returns a copy of the suite in each of two tenants of it's own.
+func (s suite) tenants() (a suite, b suite) {
+	a, b = s, s
+	a.ctx = auth.WithTenant(s.ctx, auth.TenantID(s.kind+"_a"))
+	b.ctx = auth.WithTenant(s.ctx, auth.TenantID(s.kind+"_b"))
+	return
+}
+
+func (s suite) must(err error) {
+	s.Helper()
+	s.supports(err)
+	if err != nil {
+		s.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// supports skips the test case if the error is that of an optional interface that the
+// implementation leaves out.
+func (s suite) supports(err error) {
+	s.Helper()
+	if errors.Is(err, auth.ErrUnsupported) {
+		s.Skipf("optional interface not implemented: %v", err)
+	}
+}
+
+// expect that the User holds any of the Roles, or not.
+func (s suite) expect(want bool, user auth.User, roles ...auth.Role) {
+	s.Helper()
+	got, err := s.repo.IsUserInAny(s.ctx, user, roles)
+	s.must(err)
+	if got != want {
+		s.Errorf("IsUserInAny(%s, %v) = %t, want %t", user.GetID(), roles, got, want)
+	}
+}
+
+// expectGroup checks the Users and Teams that Find lists for the Role, in any order.
+func (s suite) expectGroup(role auth.Role, users []auth.User, teams []auth.TeamID) {
+	s.Helper()
+	group, err := s.repo.Find(s.ctx, role)
+	s.must(err)
+
+	var wantUsers, gotUsers, wantTeams, gotTeams []string
+	for _, user := range users {
+		wantUsers = append(wantUsers, user.GetID())
+	}
+
+	for _, team := range teams {
+		wantTeams = append(wantTeams, string(team))
+	}
+
+	gotUsers = append(gotUsers, group.Users...)
+	for _, team := range group.Teams {
+		gotTeams = append(gotTeams, string(team))
+	}
+
+	sort.Strings(wantUsers)
+	sort.Strings(gotUsers)
+	sort.Strings(wantTeams)
+	sort.Strings(gotTeams)
+	if !reflect.DeepEqual(gotUsers, wantUsers) {
+		s.Errorf("Find(%v).Users = %v, want %v", role, gotUsers, wantUsers)
+	}
+
+	if !reflect.DeepEqual(gotTeams, wantTeams) {
+		s.Errorf("Find(%v).Teams = %v, want %v", role, gotTeams, wantTeams)
+	}
+
+	if group.Role.Name != role.Name ||
+		group.Role.Resource.Kind() != role.Resource.Kind() ||
+		group.Role.Resource.Identifier() != role.Resource.Identifier() {
+		s.Errorf("Find(%v).Role = %v", role, group.Role)
+	}
+}
+
+// expectDenied checks the Users that Denials lists for the Role, in any order.
+func (s suite) expectDenied(role auth.Role, users ...auth.User) {
+	s.Helper()
+	denials, err := s.repo.Denials(s.ctx, role.Resource)
+	s.must(err)
+
+	var want, got []string
+	for _, user := range users {
+		want = append(want, user.GetID())
+	}
+
+	for _, group := range denials {
+		if group.Role.Name == role.Name {
+			got = append(got, group.Users...)
+		}
+	}
+
+	sort.Strings(want)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		s.Errorf("Denials(%v) = %v, want %v", role, got, want)
+	}
+}
+
+// expectResources checks the Roles that Resources lists for the User, in any order.
+func (s suite) expectResources(user auth.User, want ...auth.Role) {
+	s.Helper()
+	roles, err := s.repo.Resources(s.ctx, s.kind, user)
+	s.must(err)
+
+	var gotKeys, wantKeys []string
+	for _, role := range roles {
+		gotKeys = append(gotKeys, roleKey(role))
+	}
+
+	for _, role := range want {
+		wantKeys = append(wantKeys, roleKey(role))
+	}
+
+	sort.Strings(gotKeys)
+	sort.Strings(wantKeys)
+	if !reflect.DeepEqual(gotKeys, wantKeys) {
+		s.Errorf("Resources(%s) = %v, want %v", user.GetID(), gotKeys, wantKeys)
+	}
+}
+
+// findPages pages through FindPage, calling `between` after each page, and checks that
+// the pages agree upon their Total and are sorted in the Page's Order.
+func (s suite) findPages(
+	resource auth.Resource, page auth.Page, between func(),
+) (members []auth.Member, total int) {
+	s.Helper()
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			s.Fatalf("FindPage(%v) never ended", resource)
+		}
+
+		got, err := s.repo.FindPage(s.ctx, resource, page)
+		s.must(err)
+		if pages == 0 {
+			total = got.Total
+		}
+
+		if len(got.Members) > page.Size() {
+			s.Errorf("FindPage(%v) = %d members, want at most %d", resource, len(got.Members), page.Size())
+		}
+
+		members = append(members, got.Members...)
+		if page.Cursor = got.Next; page.Cursor == "" {
+			break
+		}
+
+		if between != nil {
+			between()
+		}
+	}
+
+	for i := 1; i < len(members); i++ {
+		prev, next := members[i-1].CreatedAt, members[i].CreatedAt
+		if page.Order == auth.OldestFirst && next.Before(prev) ||
+			page.Order == auth.NewestFirst && next.After(prev) {
+			s.Errorf("FindPage(%v) isn't sorted at %d: %v then %v", resource, i, prev, next)
+		}
+	}
+
+	return
+}
+
+// expectMembers checks the Members that findPages lists for the Resource, in any order,
+// and that none is listed twice.
+func (s suite) expectMembers(members []auth.Member, want ...string) {
+	s.Helper()
+
+	var got []string
+	for _, m := range members {
+		got = append(got, fmt.Sprintf("%s %s:%s", m.Role.Name, m.Principal.Type, m.Principal.ID))
+	}
+
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		s.Errorf("FindPage = %v, want %v", got, want)
+	}
+}
+
+func (s suite) member(role string, user auth.User) string {
+	return fmt.Sprintf("%s %s:%s", role, auth.UserPrincipal, user.GetID())
+}
+
+// expectErr checks that the error is, or wraps, the wanted one.
+func (s suite) expectErr(err error, want error, op string) {
+	s.Helper()
+	s.supports(err)
+	if !errors.Is(err, want) {
+		s.Errorf("%s = %v, want %v", op, err, want)
+	}
+}
+
+func roleKey(role auth.Role) string {
+	return fmt.Sprintf(
+		"%s@%s:%s", role.Name, role.Resource.Kind(), role.Resource.Identifier(),
+	)
+}
+
+func testAddIsIdempotent(s suite) {
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(s.repo.Add(s.ctx, alice, editor))
+	s.must(s.repo.Add(s.ctx, alice, editor))
+	s.expect(true, alice, editor)
+	s.expectGroup(editor, []auth.User{alice}, nil)
+}
+
+func testAddThenDelete(s suite) {
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(s.repo.Add(s.ctx, alice, editor))
+	s.must(s.repo.Delete(s.ctx, alice, editor))
+	s.expect(false, alice, editor)
+	s.expectGroup(editor, nil, nil)
+}
+
+func testDeleteMissingIsNoop(s suite) {
+	alice, bob, editor := s.user("alice"), s.user("bob"), s.role("editor", "1")
+	s.must(s.repo.Add(s.ctx, alice, editor))
+	s.must(s.repo.Delete(s.ctx, bob, editor))
+	s.must(s.repo.Delete(s.ctx, alice, s.role("viewer", "1")))
+	s.expect(true, alice, editor)
+}
+
+func testIsUserInAnyWithoutRoles(s suite) {
+	alice := s.user("alice")
+	s.must(s.repo.Add(s.ctx, alice, s.role("editor", "1")))
+	s.expect(false, alice)
+}
+
+func testIsUserInAnyMatchesAnyRole(s suite) {
+	alice := s.user("alice")
+	s.must(s.repo.Add(s.ctx, alice, s.role("viewer", "2")))
+	s.expect(true, alice, s.role("editor", "1"), s.role("viewer", "2"))
+	s.expect(false, alice, s.role("editor", "1"), s.role("editor", "2"))
+}
+
+func testIsUserInAnyIsExact(s suite) {
+	alice, bob := s.user("alice"), s.user("bob")
+	s.must(s.repo.Add(s.ctx, alice, s.role("editor", "1")))
+	s.expect(false, bob, s.role("editor", "1"))
+	s.expect(false, alice, s.role("editor", "2"))
+	s.expect(false, alice, s.role("viewer", "1"))
+	s.expect(false, alice, auth.NewRole("editor", resource{kind: s.kind + "_other", id: "1"}))
+}
+
+func testFreeRemovesAllRoles(s suite) {
+	alice, bob := s.user("alice"), s.user("bob")
+	s.must(s.repo.Add(s.ctx, alice, s.role("editor", "1")))
+	s.must(s.repo.Add(s.ctx, bob, s.role("viewer", "1")))
+	s.must(s.repo.AddTeam(s.ctx, s.team("t"), s.role("viewer", "1")))
+	s.must(s.repo.Deny(s.ctx, bob, s.role("editor", "1")))
+	s.must(s.repo.Add(s.ctx, alice, s.role("editor", "2")))
+
+	s.must(s.repo.Free(s.ctx, s.resource("1")))
+	s.expect(false, alice, s.role("editor", "1"))
+	s.expect(false, bob, s.role("viewer", "1"))
+	s.expectGroup(s.role("viewer", "1"), nil, nil)
+	s.expect(true, alice, s.role("editor", "2"))
+
+	denials, err := s.repo.Denials(s.ctx, s.resource("1"))
+	s.must(err)
+	if len(denials) != 0 {
+		s.Errorf("Denials after Free = %v, want none", denials)
+	}
+}
+
+func testFindListsUsersAndTeams(s suite) {
+	alice, bob, carol := s.user("alice"), s.user("bob"), s.user("carol")
+	editor := s.role("editor", "1")
+	s.must(s.repo.Add(s.ctx, alice, editor))
+	s.must(s.repo.Add(s.ctx, bob, editor))
+	s.must(s.repo.AddTeam(s.ctx, s.team("t"), editor))
+	s.must(s.repo.Deny(s.ctx, carol, editor))
+	s.must(s.repo.Add(s.ctx, carol, s.role("viewer", "1")))
+	s.expectGroup(editor, []auth.User{alice, bob}, []auth.TeamID{s.team("t")})
+}
+
+func testFindUnknownRole(s suite) {
+	s.expectGroup(s.role("editor", "1"), nil, nil)
+}
+
+func testDenyOverridesGrant(s suite) {
+	alice, editor, viewer := s.user("alice"), s.role("editor", "1"), s.role("viewer", "1")
+	s.must(s.repo.Add(s.ctx, alice, viewer))
+	s.must(s.repo.AddTeam(s.ctx, s.team("t"), editor))
+	s.must(auth.Teams.AddMember(s.ctx, s.team("t"), auth.PrincipalOf(alice)))
+	s.expect(true, alice, editor)
+
+	s.must(s.repo.Deny(s.ctx, alice, editor))
+	s.expect(false, alice, editor)
+	s.expect(false, alice, editor, viewer)
+	s.expect(true, alice, viewer)
+
+	s.must(s.repo.Delete(s.ctx, alice, editor))
+	s.expect(true, alice, editor)
+}
+
+func testAddReplacesDenial(s suite) {
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(s.repo.Deny(s.ctx, alice, editor))
+	s.must(s.repo.Add(s.ctx, alice, editor))
+	s.expect(true, alice, editor)
+
+	s.must(s.repo.Deny(s.ctx, alice, editor))
+	s.expect(false, alice, editor)
+	s.expectGroup(editor, nil, nil)
+}
+
+func testDenyOverridesWildcard(s suite) {
+	alice := s.user("alice")
+	s.must(s.repo.Add(s.ctx, alice, auth.NewRole("editor", auth.AllOf(s.kind))))
+	s.must(s.repo.Deny(s.ctx, alice, s.role("editor", "1")))
+	s.expect(false, alice, s.role("editor", "1"))
+	s.expect(true, alice, s.role("editor", "2"))
+}
+
+func testDenials(s suite) {
+	alice, bob := s.user("alice"), s.user("bob")
+	s.must(s.repo.Deny(s.ctx, alice, s.role("editor", "1")))
+	s.must(s.repo.Deny(s.ctx, bob, s.role("editor", "1")))
+	s.must(s.repo.Deny(s.ctx, bob, s.role("admin", "1")))
+	s.must(s.repo.Deny(s.ctx, bob, s.role("admin", "2")))
+	s.must(s.repo.Add(s.ctx, alice, s.role("viewer", "1")))
+
+	denials, err := s.repo.Denials(s.ctx, s.resource("1"))
+	s.must(err)
+
+	got := make(map[auth.RoleName][]string)
+	for _, group := range denials {
+		if _, ok := got[group.Role.Name]; ok {
+			s.Errorf("Denials listed %q more than once", group.Role.Name)
+		}
+
+		users := append([]string(nil), group.Users...)
+		sort.Strings(users)
+		got[group.Role.Name] = users
+	}
+
+	want := map[auth.RoleName][]string{
+		"admin":  {bob.GetID()},
+		"editor": {alice.GetID(), bob.GetID()},
+	}
+
+	sort.Strings(want["editor"])
+	if !reflect.DeepEqual(got, want) {
+		s.Errorf("Denials = %v, want %v", got, want)
+	}
+}
+
+func testWildcards(s suite) {
+	alice, bob := s.user("alice"), s.user("bob")
+	all := auth.NewRole("editor", auth.AllOf(s.kind))
+	s.must(s.repo.Add(s.ctx, alice, all))
+	s.must(s.repo.Add(s.ctx, bob, s.role("editor", "1")))
+
+	s.expect(true, alice, s.role("editor", "1"))
+	s.expect(true, alice, s.role("editor", "2"))
+	s.expect(true, alice, all)
+	s.expect(false, alice, s.role("viewer", "1"))
+	s.expect(false, bob, all)
+	s.expect(false, alice, auth.NewRole("editor", resource{kind: s.kind + "_other", id: "1"}))
+	s.expectResources(alice, all)
+}
+
+func testExpiry(s suite) {
+	alice, bob, editor := s.user("alice"), s.user("bob"), s.role("editor", "1")
+	s.must(s.repo.Add(s.ctx, alice, editor, auth.ExpiresIn(-time.Minute)))
+	s.must(s.repo.Add(s.ctx, bob, editor, auth.ExpiresIn(time.Hour)))
+	s.expect(false, alice, editor)
+	s.expect(true, bob, editor)
+	s.expectGroup(editor, []auth.User{bob}, nil)
+	s.expectResources(alice)
+	s.expectResources(bob, editor)
+
+	carol := s.user("carol")
+	s.must(s.repo.Add(s.ctx, carol, auth.NewRole("editor", auth.AllOf(s.kind))))
+	s.must(s.repo.Deny(s.ctx, carol, editor, auth.ExpiresIn(-time.Minute)))
+	s.expect(true, carol, editor)
+	denials, err := s.repo.Denials(s.ctx, s.resource("1"))
+	s.must(err)
+	if len(denials) != 0 {
+		s.Errorf("Denials = %v, want none for an expired denial", denials)
+	}
+}
+
+func testNotBefore(s suite) {
+	alice, bob, editor := s.user("alice"), s.user("bob"), s.role("editor", "1")
+	s.must(s.repo.Add(s.ctx, alice, editor, auth.NotBefore(time.Now().Add(time.Hour))))
+	s.must(s.repo.Add(s.ctx, bob, editor, auth.NotBefore(time.Now().Add(-time.Minute))))
+	s.expect(false, alice, editor)
+	s.expect(true, bob, editor)
+	s.expectGroup(editor, []auth.User{bob}, nil)
+	s.expectResources(alice)
+}
+
+func testAddReplacesBounds(s suite) {
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(s.repo.Add(s.ctx, alice, editor, auth.ExpiresIn(-time.Minute)))
+	s.must(s.repo.Add(s.ctx, alice, editor))
+	s.expect(true, alice, editor)
+}
+
+func testPurgeExpired(s suite) {
+	alice, bob, editor := s.user("alice"), s.user("bob"), s.role("editor", "1")
+	s.must(s.repo.Add(s.ctx, alice, editor, auth.ExpiresIn(-time.Minute)))
+	s.must(s.repo.Add(s.ctx, bob, editor, auth.ExpiresIn(time.Hour)))
+
+	n, err := s.repo.PurgeExpired(s.ctx)
+	s.must(err)
+	if n < 1 {
+		s.Errorf("PurgeExpired = %d, want at least 1", n)
+	}
+
+	s.expect(true, bob, editor)
+
+	// Adding alice anew must not resurrect any of her purged bounds.
+	s.must(s.repo.Add(s.ctx, alice, editor))
+	s.expect(true, alice, editor)
+}
+
+func testConditions(s suite) {
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(s.repo.Add(s.ctx, alice, editor, auth.When(`request.ip == "10.0.0.1"`)))
+
+	office := auth.WithAttributes(s.ctx, auth.Attributes{"ip": "10.0.0.1"})
+	home := auth.WithAttributes(s.ctx, auth.Attributes{"ip": "192.168.0.1"})
+
+	ok, err := s.repo.IsUserInAny(office, alice, auth.Roles{editor})
+	s.must(err)
+	if !ok {
+		s.Errorf("IsUserInAny from the office = false, want true")
+	}
+
+	if ok, err = s.repo.IsUserInAny(home, alice, auth.Roles{editor}); err != nil || ok {
+		s.Errorf("IsUserInAny from home = %t, %v, want false", ok, err)
+	}
+
+	if ok, err = s.repo.IsUserInAny(s.ctx, alice, auth.Roles{editor}); ok ||
+		!errors.Is(err, auth.ErrConditionEvaluation) {
+		s.Errorf("IsUserInAny without attributes = %t, %v, want false, %v",
+			ok, err, auth.ErrConditionEvaluation)
+	}
+
+	s.expectResources(alice, editor)
+}
+
+func testInvalidCondition(s suite) {
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	if err := s.repo.Add(s.ctx, alice, editor, auth.When("request.ip ==")); !errors.Is(
+		err, auth.ErrInvalidCondition,
+	) {
+		s.Errorf("Add with an invalid condition = %v, want %v", err, auth.ErrInvalidCondition)
+	}
+
+	s.expect(false, alice, editor)
+}
+
+func testTeams(s suite) {
+	alice, bob, editor := s.user("alice"), s.user("bob"), s.role("editor", "1")
+	s.must(auth.Teams.AddMember(s.ctx, s.team("t"), auth.PrincipalOf(alice)))
+	s.must(s.repo.AddTeam(s.ctx, s.team("t"), editor))
+	s.must(s.repo.AddTeam(s.ctx, s.team("t"), editor))
+
+	s.expect(true, alice, editor)
+	s.expect(false, bob, editor)
+	s.expectResources(alice, editor)
+	s.expectGroup(editor, nil, []auth.TeamID{s.team("t")})
+
+	s.must(s.repo.DeleteTeam(s.ctx, s.team("t"), editor))
+	s.expect(false, alice, editor)
+	s.expectResources(alice)
+}
+
+func testNestedTeams(s suite) {
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(auth.Teams.AddMember(s.ctx, s.team("inner"), auth.PrincipalOf(alice)))
+	s.must(auth.Teams.AddMember(s.ctx, s.team("outer"), s.team("inner").Principal()))
+	s.must(auth.Teams.AddMember(s.ctx, s.team("inner"), s.team("outer").Principal()))
+	s.must(s.repo.AddTeam(s.ctx, s.team("outer"), editor))
+	s.expect(true, alice, editor)
+}
+
+func testAssignments(s suite) {
+	alice := s.user("alice")
+	s.must(s.repo.Add(s.ctx, alice, s.role("editor", "1")))
+	s.must(s.repo.Add(s.ctx, alice, auth.NewRole("editor", auth.AllOf(s.kind))))
+	s.must(s.repo.Deny(s.ctx, alice, s.role("viewer", "1")))
+	s.must(s.repo.Add(s.ctx, alice, s.role("editor", "2")))
+	s.must(s.repo.Add(s.ctx, alice, s.role("admin", "1"), auth.ExpiresIn(-time.Minute)))
+
+	assignments, err := s.repo.Assignments(s.ctx, alice, auth.Roles{
+		s.role("editor", "1"), s.role("viewer", "1"), s.role("admin", "1"),
+	})
+
+	s.must(err)
+
+	var got []string
+	for _, a := range assignments {
+		got = append(got, fmt.Sprintf("%s %s %s", a.Effect, a.Principal.ID, roleKey(a.Role)))
+	}
+
+	want := []string{
+		fmt.Sprintf("allow %s editor@%s:1", alice.GetID(), s.kind),
+		fmt.Sprintf("allow %s editor@%s:*", alice.GetID(), s.kind),
+		fmt.Sprintf("deny %s viewer@%s:1", alice.GetID(), s.kind),
+	}
+
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		s.Errorf("Assignments = %v, want %v", got, want)
+	}
+}
+
+func testResources(s suite) {
+	alice := s.user("alice")
+	s.must(s.repo.Add(s.ctx, alice, s.role("editor", "1")))
+	s.must(s.repo.Add(s.ctx, alice, s.role("viewer", "2")))
+	s.must(s.repo.Deny(s.ctx, alice, s.role("admin", "3")))
+	s.must(s.repo.Add(s.ctx, alice, auth.NewRole("editor", resource{kind: s.kind + "_other", id: "1"})))
+	s.must(s.repo.AddTeam(s.ctx, s.team("t"), s.role("editor", "1")))
+	s.must(auth.Teams.AddMember(s.ctx, s.team("t"), auth.PrincipalOf(alice)))
+	s.expectResources(alice, s.role("editor", "1"), s.role("viewer", "2"))
+	s.expectResources(s.user("bob"))
+}
+
+func testFindPage(s suite) {
+	var want []string
+	for i := 0; i < 5; i++ {
+		user := s.user(fmt.Sprintf("user%d", i))
+		s.must(s.repo.Add(s.ctx, user, s.role("editor", "1")))
+		want = append(want, s.member("editor", user))
+	}
+
+	s.must(s.repo.Add(s.ctx, s.user("viewer"), s.role("viewer", "1")))
+	s.must(s.repo.AddTeam(s.ctx, s.team("t"), s.role("editor", "1")))
+	s.must(s.repo.Deny(s.ctx, s.user("denied"), s.role("editor", "1")))
+	s.must(s.repo.Add(s.ctx, s.user("expired"), s.role("editor", "1"), auth.ExpiresIn(-time.Minute)))
+	s.must(s.repo.Add(s.ctx, s.user("other"), s.role("editor", "2")))
+	want = append(
+		want,
+		s.member("viewer", s.user("viewer")),
+		fmt.Sprintf("editor %s:%s", auth.TeamPrincipal, s.team("t")),
+	)
+
+	for _, limit := range []int{1, 2, 7, 0} {
+		members, total := s.findPages(s.resource("1"), auth.Page{Limit: limit}, nil)
+		s.expectMembers(members, want...)
+		if total != len(want) {
+			s.Errorf("FindPage(Limit: %d).Total = %d, want %d", limit, total, len(want))
+		}
+	}
+
+	got, err := s.repo.FindPage(s.ctx, s.resource("1"), auth.Page{Limit: len(want)})
+	s.must(err)
+	if got.Next != "" {
+		s.Errorf("FindPage of every Member has Next = %q, want none", got.Next)
+	}
+
+	for _, m := range got.Members {
+		if m.Role.Resource.Identifier() != "1" || m.CreatedAt.IsZero() {
+			s.Errorf("FindPage listed %+v", m)
+		}
+	}
+
+	members, total := s.findPages(s.resource("3"), auth.Page{}, nil)
+	if len(members) != 0 || total != 0 {
+		s.Errorf("FindPage of an unassigned Resource = %v, %d", members, total)
+	}
+}
+
+func testFindPageFilters(s suite) {
+	alice, bob, carol := s.user("alice"), s.user("bob"), s.user("carol")
+	s.must(s.repo.Add(s.ctx, alice, s.role("editor", "1")))
+	s.must(s.repo.Add(s.ctx, bob, s.role("viewer", "1")))
+	s.must(s.repo.Add(s.ctx, carol, s.role("admin", "1")))
+	s.must(s.repo.Add(s.ctx, carol, s.role("viewer", "1")))
+
+	members, total := s.findPages(s.resource("1"), auth.Page{
+		Limit:     1,
+		RoleNames: []auth.RoleName{"viewer", "admin"},
+	}, nil)
+
+	s.expectMembers(
+		members, s.member("viewer", bob), s.member("admin", carol), s.member("viewer", carol),
+	)
+
+	if total != 3 {
+		s.Errorf("FindPage(viewer, admin).Total = %d, want 3", total)
+	}
+
+	members, _ = s.findPages(s.resource("1"), auth.Page{
+		RoleNames: []auth.RoleName{"owner"},
+	}, nil)
+
+	s.expectMembers(members)
+}
+
+func testFindPageNewestFirst(s suite) {
+	var want []string
+	for i := 0; i < 4; i++ {
+		user := s.user(fmt.Sprintf("user%d", i))
+		s.must(s.repo.Add(s.ctx, user, s.role("editor", "1")))
+		want = append(want, s.member("editor", user))
+	}
+
+	members, total := s.findPages(s.resource("1"), auth.Page{
+		Limit: 3,
+		Order: auth.NewestFirst,
+	}, nil)
+
+	s.expectMembers(members, want...)
+	if total != len(want) {
+		s.Errorf("FindPage(NewestFirst).Total = %d, want %d", total, len(want))
+	}
+}
+
+func testFindPageConcurrentInserts(s suite) {
+	var want []string
+	for i := 0; i < 6; i++ {
+		user := s.user(fmt.Sprintf("user%d", i))
+		s.must(s.repo.Add(s.ctx, user, s.role("editor", "1")))
+		want = append(want, s.member("editor", user))
+	}
+
+	for _, order := range []auth.Order{auth.OldestFirst, auth.NewestFirst} {
+		var (
+			added = make(map[string]bool)
+			n     int
+		)
+
+		members, _ := s.findPages(s.resource("1"), auth.Page{Limit: 2, Order: order}, func() {
+			user := s.user(fmt.Sprintf("added%d_%d", order, n))
+			n++
+			s.must(s.repo.Add(s.ctx, user, s.role("editor", "1")))
+			s.must(s.repo.Add(s.ctx, s.user("user0"), s.role("editor", "1")))
+			added[s.member("editor", user)] = true
+		})
+
+		var (
+			existing []auth.Member
+			seen     = make(map[string]bool)
+		)
+
+		for _, m := range members {
+			key := fmt.Sprintf("%s %s:%s", m.Role.Name, m.Principal.Type, m.Principal.ID)
+			if seen[key] {
+				s.Errorf("FindPage(Order: %d) listed %s twice", order, key)
+			}
+
+			seen[key] = true
+			if !added[key] {
+				existing = append(existing, m)
+			}
+		}
+
+		s.expectMembers(existing, want...)
+		for key := range added {
+			want = append(want, key)
+		}
+	}
+}
+
+func testResourcesPage(s suite) {
+	alice := s.user("alice")
+	for i := 1; i <= 5; i++ {
+		s.must(s.repo.Add(s.ctx, alice, s.role("editor", fmt.Sprint(i))))
+	}
+
+	s.must(s.repo.Add(s.ctx, alice, s.role("viewer", "1")))
+	s.must(s.repo.Deny(s.ctx, alice, s.role("admin", "1")))
+	s.must(s.repo.AddTeam(s.ctx, s.team("t"), s.role("editor", "3")))
+	s.must(s.repo.AddTeam(s.ctx, s.team("t"), s.role("editor", "6")))
+	s.must(auth.Teams.AddMember(s.ctx, s.team("t"), auth.PrincipalOf(alice)))
+	s.must(s.repo.Add(s.ctx, alice, auth.NewRole("editor", resource{kind: s.kind + "_other", id: "1"})))
+
+	want := []string{roleKey(s.role("viewer", "1"))}
+	for i := 1; i <= 6; i++ {
+		want = append(want, roleKey(s.role("editor", fmt.Sprint(i))))
+	}
+
+	for _, page := range []auth.Page{
+		{Limit: 2},
+		{Limit: 3, Order: auth.NewestFirst},
+		{},
+	} {
+		var got []string
+		for pages := 0; ; pages++ {
+			if pages > 100 {
+				s.Fatalf("ResourcesPage never ended")
+			}
+
+			roles, err := s.repo.ResourcesPage(s.ctx, s.kind, alice, page)
+			s.must(err)
+			if roles.Total != len(want) {
+				s.Errorf("ResourcesPage(%+v).Total = %d, want %d", page, roles.Total, len(want))
+			}
+
+			for _, role := range roles.Roles {
+				got = append(got, roleKey(role))
+			}
+
+			if page.Cursor = roles.Next; page.Cursor == "" {
+				break
+			}
+		}
+
+		sort.Strings(got)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			s.Errorf("ResourcesPage(Limit: %d) = %v, want %v", page.Limit, got, want)
+		}
+	}
+
+	// Revoking alice's own grant upon a Resource that her Team holds the same Role upon
+	// leaves it where it was listed, rather than listing it again on a later page.
+	first, err := s.repo.ResourcesPage(s.ctx, s.kind, alice, auth.Page{Limit: 3})
+	s.must(err)
+	s.must(s.repo.Delete(s.ctx, alice, s.role("editor", "3")))
+
+	listed := make(map[string]int)
+	for _, role := range first.Roles {
+		listed[roleKey(role)]++
+	}
+
+	for page := (auth.Page{Limit: 3, Cursor: first.Next}); page.Cursor != ""; {
+		roles, err := s.repo.ResourcesPage(s.ctx, s.kind, alice, page)
+		s.must(err)
+		for _, role := range roles.Roles {
+			listed[roleKey(role)]++
+		}
+
+		page.Cursor = roles.Next
+	}
+
+	for _, key := range want {
+		if listed[key] != 1 {
+			s.Errorf("ResourcesPage listed %s %d times after a revocation", key, listed[key])
+		}
+	}
+
+	s.must(s.repo.Add(s.ctx, alice, s.role("editor", "3")))
+
+	roles, err := s.repo.ResourcesPage(s.ctx, s.kind, alice, auth.Page{
+		RoleNames: []auth.RoleName{"viewer"},
+	})
+
+	s.must(err)
+	if roles.Total != 1 || len(roles.Roles) != 1 ||
+		roleKey(roles.Roles[0]) != roleKey(s.role("viewer", "1")) {
+		s.Errorf("ResourcesPage(viewer) = %+v", roles)
+	}
+}
+
+func testInvalidCursor(s suite) {
+	s.must(s.repo.Add(s.ctx, s.user("alice"), s.role("editor", "1")))
+	for _, cursor := range []string{"not a cursor", auth.NewCursor(time.Now()).String()} {
+		_, err := s.repo.FindPage(s.ctx, s.resource("1"), auth.Page{Cursor: cursor})
+		s.supports(err)
+		if !errors.Is(err, auth.ErrInvalidCursor) {
+			s.Errorf("FindPage(Cursor: %q) = %v, want %v", cursor, err, auth.ErrInvalidCursor)
+		}
+	}
+}
+
+func testAddMany(s suite) {
+	alice, bob, editor, viewer := s.user("alice"), s.user("bob"), s.role("editor", "1"), s.role("viewer", "2")
+	s.must(s.repo.Deny(s.ctx, bob, viewer))
+	s.must(s.repo.AddMany(s.ctx, []auth.Group{
+		{Role: editor, Users: []string{alice.GetID(), bob.GetID(), alice.GetID()}},
+		{Role: viewer, Users: []string{bob.GetID()}, Teams: []auth.TeamID{s.team("t")}},
+	}))
+
+	s.expectGroup(editor, []auth.User{alice, bob}, nil)
+	s.expectGroup(viewer, []auth.User{bob}, []auth.TeamID{s.team("t")})
+	s.must(s.repo.AddMany(s.ctx, nil))
+
+	s.must(s.repo.AddMany(s.ctx, []auth.Group{
+		{Role: s.role("admin", "1"), Users: []string{alice.GetID()}},
+	}, auth.ExpiresIn(-time.Minute)))
+
+	s.expect(false, alice, s.role("admin", "1"))
+	if err := s.repo.AddMany(s.ctx, []auth.Group{
+		{Role: s.role("admin", "2"), Users: []string{alice.GetID()}},
+	}, auth.When("(")); !errors.Is(err, auth.ErrInvalidCondition) {
+		s.Errorf("AddMany with an invalid condition = %v, want %v", err, auth.ErrInvalidCondition)
+	}
+}
+
+func testAddManyBatches(s suite) {
+	var (
+		users []auth.User
+		ids   []string
+	)
+
+	for i := 0; i < 150; i++ {
+		users = append(users, s.user(fmt.Sprintf("user%d", i)))
+		ids = append(ids, users[i].GetID())
+	}
+
+	s.must(s.repo.AddMany(s.ctx, []auth.Group{{Role: s.role("editor", "1"), Users: ids}}))
+	s.expectGroup(s.role("editor", "1"), users, nil)
+
+	s.must(s.repo.DeleteMany(s.ctx, []auth.Group{{Role: s.role("editor", "1"), Users: ids[1:]}}))
+	s.expectGroup(s.role("editor", "1"), users[:1], nil)
+}
+
+func testDeleteMany(s suite) {
+	alice, bob, editor, viewer := s.user("alice"), s.user("bob"), s.role("editor", "1"), s.role("viewer", "1")
+	s.must(s.repo.Add(s.ctx, alice, editor))
+	s.must(s.repo.Add(s.ctx, bob, editor))
+	s.must(s.repo.Deny(s.ctx, alice, viewer))
+	s.must(s.repo.AddTeam(s.ctx, s.team("t"), viewer))
+	s.must(s.repo.DeleteMany(s.ctx, []auth.Group{
+		{Role: editor, Users: []string{alice.GetID(), s.user("nobody").GetID()}},
+		{Role: viewer, Users: []string{alice.GetID()}, Teams: []auth.TeamID{s.team("t")}},
+	}))
+
+	s.expectGroup(editor, []auth.User{bob}, nil)
+	s.expectGroup(viewer, nil, nil)
+
+	denials, err := s.repo.Denials(s.ctx, s.resource("1"))
+	s.must(err)
+	if len(denials) != 0 {
+		s.Errorf("Denials after DeleteMany = %v, want none", denials)
+	}
+}
+
+func testReplace(s suite) {
+	var (
+		alice, bob, carol, dave = s.user("alice"), s.user("bob"), s.user("carol"), s.user("dave")
+		editor, viewer          = s.role("editor", "1"), s.role("viewer", "1")
+	)
+
+	s.must(s.repo.Add(s.ctx, alice, editor))
+	s.must(s.repo.Add(s.ctx, bob, editor))
+	s.must(s.repo.Add(s.ctx, carol, viewer, auth.ExpiresIn(-time.Minute)))
+	s.must(s.repo.Deny(s.ctx, dave, viewer))
+	s.must(s.repo.AddTeam(s.ctx, s.team("t"), viewer))
+	s.must(s.repo.Add(s.ctx, alice, s.role("editor", "2")))
+
+	s.must(s.repo.Replace(s.ctx, s.resource("1"), []auth.Group{
+		{Role: editor, Users: []string{alice.GetID()}},
+		{Role: viewer, Users: []string{bob.GetID(), carol.GetID()}},
+	}))
+
+	s.expectGroup(editor, []auth.User{alice}, nil)
+	s.expectGroup(viewer, []auth.User{bob, carol}, nil)
+	s.expect(true, alice, s.role("editor", "2"))
+
+	// Dave's denial of a Role that isn't desired is left alone.
+	s.expect(false, dave, viewer)
+	s.expectDenied(viewer, dave)
+
+	// Once it's desired, it's granted instead.
+	s.must(s.repo.Replace(s.ctx, s.resource("1"), []auth.Group{
+		{Role: viewer, Users: []string{dave.GetID()}},
+	}))
+
+	s.expectGroup(editor, nil, nil)
+	s.expectGroup(viewer, []auth.User{dave}, nil)
+	s.expect(true, dave, viewer)
+	s.expectDenied(viewer)
+
+	s.must(s.repo.Replace(s.ctx, s.resource("1"), nil))
+	s.expectGroup(viewer, nil, nil)
+	s.expect(true, alice, s.role("editor", "2"))
+}
+
+func testReplaceForeignRole(s suite) {
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(s.repo.Add(s.ctx, alice, editor))
+	err := s.repo.Replace(s.ctx, s.resource("1"), []auth.Group{
+		{Role: s.role("editor", "2"), Users: []string{alice.GetID()}},
+	})
+
+	s.supports(err)
+	if !errors.Is(err, auth.ErrForeignRole) {
+		s.Errorf("Replace with a foreign Role = %v, want %v", err, auth.ErrForeignRole)
+	}
+
+	s.expect(true, alice, editor)
+	s.expect(false, alice, s.role("editor", "2"))
+}
+
+func testRequiredRoles(s suite) {
+	auth.Required.Require(s.kind, "owner")
+
+	var (
+		alice, bob, carol = s.user("alice"), s.user("bob"), s.user("carol")
+		owner, editor     = s.role("owner", "1"), s.role("editor", "1")
+	)
+
+	s.must(s.repo.Add(s.ctx, alice, owner))
+	s.must(s.repo.Add(s.ctx, bob, owner))
+	s.must(s.repo.Add(s.ctx, alice, editor))
+	s.must(s.repo.Delete(s.ctx, alice, owner))
+	s.expectErr(s.repo.Delete(s.ctx, bob, owner), auth.ErrLastMember, "Delete(last owner)")
+	s.expectErr(s.repo.Deny(s.ctx, bob, owner), auth.ErrLastMember, "Deny(last owner)")
+	s.expect(true, bob, owner)
+
+	s.must(s.repo.Delete(s.ctx, carol, owner))
+	s.must(s.repo.Delete(s.ctx, alice, editor))
+	s.must(s.repo.Deny(s.ctx, carol, owner))
+
+	s.must(s.repo.AddTeam(s.ctx, s.team("t"), owner))
+	s.must(s.repo.Delete(s.ctx, bob, owner))
+	s.expectErr(s.repo.DeleteTeam(s.ctx, s.team("t"), owner), auth.ErrLastMember, "DeleteTeam(last owner)")
+	s.expectGroup(owner, nil, []auth.TeamID{s.team("t")})
+
+	// Expired grants aren't Members, so they don't keep a required Role from emptying.
+	s.must(s.repo.Add(s.ctx, alice, s.role("owner", "2"), auth.ExpiresIn(-time.Minute)))
+	s.must(s.repo.Add(s.ctx, bob, s.role("owner", "2")))
+	s.expectErr(s.repo.Delete(s.ctx, bob, s.role("owner", "2")), auth.ErrLastMember, "Delete(last active owner)")
+	s.must(s.repo.Delete(s.ctx, alice, s.role("owner", "2")))
+
+	s.must(s.repo.Free(s.ctx, s.resource("1")))
+	s.expectGroup(owner, nil, nil)
+}
+
+func testRequiredRolesInBulk(s suite) {
+	auth.Required.Require(s.kind, "owner")
+
+	var (
+		alice, bob, carol = s.user("alice"), s.user("bob"), s.user("carol")
+		owner, editor     = s.role("owner", "1"), s.role("editor", "1")
+	)
+
+	s.must(s.repo.AddMany(s.ctx, []auth.Group{
+		{Role: owner, Users: []string{alice.GetID(), bob.GetID()}},
+		{Role: editor, Users: []string{carol.GetID()}},
+	}))
+
+	s.expectErr(s.repo.DeleteMany(s.ctx, []auth.Group{
+		{Role: editor, Users: []string{carol.GetID()}},
+		{Role: owner, Users: []string{alice.GetID(), bob.GetID()}},
+	}), auth.ErrLastMember, "DeleteMany(every owner)")
+
+	s.expectGroup(owner, []auth.User{alice, bob}, nil)
+	s.expectGroup(editor, []auth.User{carol}, nil)
+
+	s.expectErr(s.repo.Replace(s.ctx, s.resource("1"), []auth.Group{
+		{Role: editor, Users: []string{carol.GetID()}},
+	}), auth.ErrLastMember, "Replace(without owners)")
+
+	s.expectGroup(owner, []auth.User{alice, bob}, nil)
+
+	s.must(s.repo.Replace(s.ctx, s.resource("1"), []auth.Group{
+		{Role: owner, Users: []string{carol.GetID()}},
+	}))
+
+	s.expectGroup(owner, []auth.User{carol}, nil)
+	s.expectGroup(editor, nil, nil)
+}
+
+func testTransfer(s suite) {
+	auth.Required.Require(s.kind, "owner")
+
+	var (
+		alice, bob, carol = s.user("alice"), s.user("bob"), s.user("carol")
+		owner             = s.role("owner", "1")
+	)
+
+	s.must(s.repo.Add(s.ctx, alice, owner))
+	s.must(s.repo.Transfer(s.ctx, owner, alice, bob))
+	s.expectGroup(owner, []auth.User{bob}, nil)
+
+	s.expectErr(s.repo.Transfer(s.ctx, owner, alice, carol), auth.ErrNotMember, "Transfer(from a non-member)")
+	s.expectGroup(owner, []auth.User{bob}, nil)
+
+	s.must(s.repo.Transfer(s.ctx, owner, bob, bob))
+	s.expectGroup(owner, []auth.User{bob}, nil)
+
+	s.must(s.repo.Add(s.ctx, carol, owner))
+	s.must(s.repo.Transfer(s.ctx, owner, bob, carol))
+	s.expectGroup(owner, []auth.User{carol}, nil)
+
+	s.must(s.repo.Add(s.ctx, alice, s.role("editor", "1")))
+	s.must(s.repo.Transfer(s.ctx, s.role("editor", "1"), alice, bob))
+	s.expectGroup(s.role("editor", "1"), []auth.User{bob}, nil)
+}
+
+func testConcurrentLastMembers(s suite) {
+	auth.Required.Require(s.kind, "owner")
+
+	var (
+		wg     sync.WaitGroup
+		owner  = s.role("owner", "1")
+		owners = make([]auth.User, Concurrency)
+	)
+
+	for i := range owners {
+		owners[i] = s.user(fmt.Sprintf("owner%d", i))
+		s.must(s.repo.Add(s.ctx, owners[i], owner))
+	}
+
+	for i := range owners {
+		wg.Add(1)
+		go func(user auth.User) {
+			defer wg.Done()
+			if err := s.repo.Delete(s.ctx, user, owner); err != nil &&
+				!errors.Is(err, auth.ErrLastMember) {
+				s.Logf("concurrent Delete: %v", err)
+			}
+		}(owners[i])
+	}
+
+	wg.Wait()
+
+	group, err := s.repo.Find(s.ctx, owner)
+	s.must(err)
+	if len(group.Users) == 0 {
+		s.Errorf("concurrent Deletes emptied a required Role")
+	}
+}
+
+func testTenantReads(s suite) {
+	a, b := s.tenants()
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(a.repo.Add(a.ctx, alice, editor))
+	s.must(a.repo.Add(a.ctx, alice, auth.NewRole("owner", auth.PlatformResource)))
+	s.must(a.repo.Deny(a.ctx, s.user("bob"), editor))
+
+	a.expect(true, alice, editor)
+	b.expect(false, alice, editor)
+	b.expect(false, alice, auth.NewRole("owner", auth.PlatformResource))
+	b.expectGroup(editor, nil, nil)
+	b.expectResources(alice)
+
+	assignments, err := b.repo.Assignments(b.ctx, alice, auth.Roles{editor})
+	s.must(err)
+	if len(assignments) != 0 {
+		s.Errorf("Assignments in another tenant = %v, want none", assignments)
+	}
+
+	denials, err := b.repo.Denials(b.ctx, s.resource("1"))
+	s.must(err)
+	if len(denials) != 0 {
+		s.Errorf("Denials in another tenant = %v, want none", denials)
+	}
+}
+
+func testTenantWrites(s suite) {
+	a, b := s.tenants()
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(a.repo.Add(a.ctx, alice, editor))
+	s.must(b.repo.Add(b.ctx, alice, editor, auth.ExpiresIn(-time.Minute)))
+	a.expect(true, alice, editor)
+	b.expect(false, alice, editor)
+
+	s.must(b.repo.Delete(b.ctx, alice, editor))
+	s.must(b.repo.Deny(b.ctx, alice, editor))
+	a.expect(true, alice, editor)
+	b.expect(false, alice, editor)
+
+	s.must(b.repo.Free(b.ctx, s.resource("1")))
+	a.expect(true, alice, editor)
+	a.expectGroup(editor, []auth.User{alice}, nil)
+
+	s.must(a.repo.Free(a.ctx, s.resource("1")))
+	s.must(b.repo.Add(b.ctx, alice, editor))
+	a.expect(false, alice, editor)
+	b.expect(true, alice, editor)
+}
+
+func testTenantTeams(s suite) {
+	a, b := s.tenants()
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(auth.Teams.AddMember(a.ctx, s.team("t"), auth.PrincipalOf(alice)))
+	s.must(a.repo.AddTeam(a.ctx, s.team("t"), editor))
+	s.must(b.repo.AddTeam(b.ctx, s.team("t"), editor))
+	a.expect(true, alice, editor)
+	b.expect(false, alice, editor)
+	b.expectResources(alice)
+}
+
+func testTenantPurge(s suite) {
+	a, b := s.tenants()
+	alice, editor := s.user("alice"), s.role("editor", "1")
+	s.must(a.repo.Add(a.ctx, alice, editor, auth.ExpiresIn(-time.Minute)))
+
+	n, err := b.repo.PurgeExpired(b.ctx)
+	s.must(err)
+	if n != 0 {
+		s.Errorf("PurgeExpired in another tenant = %d, want 0", n)
+	}
+
+	if n, err = a.repo.PurgeExpired(a.ctx); err != nil || n != 1 {
+		s.Errorf("PurgeExpired = %d, %v, want 1", n, err)
+	}
+}
+
+func testTenantMaster(s suite) {
+	a, b := s.tenants()
+	master := authtest.User{ID: s.user("master").GetID(), Secret: "secret"}
+	auth.ConfigTenantMaster(auth.TenantFromContext(a.ctx), master.ID, master.Secret)
+
+	a.expect(true, master, s.role("editor", "1"))
+	b.expect(false, master, s.role("editor", "1"))
+	s.expect(false, master, s.role("editor", "1"))
+}
+
+func testConcurrentAdds(s suite) {
+	editor := s.role("editor", "1")
+
+	var (
+		wg    sync.WaitGroup
+		users = make([]auth.User, Concurrency)
+	)
+
+	for i := range users {
+		users[i] = s.user(fmt.Sprintf("user%d", i))
+	}
+
+	for i := range users {
+		wg.Add(1)
+		go func(user auth.User) {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				if err := s.repo.Add(s.ctx, user, editor); err != nil {
+					s.Errorf("concurrent Add: %v", err)
+				}
+			}
+		}(users[i])
+	}
+
+	wg.Wait()
+	s.expectGroup(editor, users, nil)
+
+	for i := range users {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				return
+			}
+
+			if err := s.repo.Delete(s.ctx, users[i], editor); err != nil {
+				s.Errorf("concurrent Delete: %v", err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	var remaining []auth.User
+	for i, user := range users {
+		if i%2 == 0 {
+			remaining = append(remaining, user)
+		}
+	}
+
+	s.expectGroup(editor, remaining, nil)
+}
+
+func testConcurrentChecks(s suite) {
+	alice, bob, editor := s.user("alice"), s.user("bob"), s.role("editor", "1")
+	s.must(s.repo.Add(s.ctx, alice, editor))
+
+	var (
+		wg     sync.WaitGroup
+		failed int32
+	)
+
+	for i := 0; i < Concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user, want := alice, true
+			if i%2 == 1 {
+				user, want = bob, false
+			}
+
+			ok, err := s.repo.IsUserInAny(s.ctx, user, auth.Roles{editor})
+			if err != nil || ok != want {
+				atomic.AddInt32(&failed, 1)
+			}
+
+			// Writes to other Resources must not disturb concurrent checks.
+			if err = s.repo.Add(s.ctx, user, s.role("viewer", fmt.Sprint(i))); err != nil {
+				atomic.AddInt32(&failed, 1)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	if failed > 0 {
+		s.Errorf("%d of %d concurrent checks failed", failed, Concurrency)
+	}
+}
@@ -2,9 +2,11 @@ package auth
 
 import (
 	"context"
+	"net"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 )
 
 // GRPCSession is an implementation of Session for gRPC, and checks for User and Secret
@@ -50,9 +52,36 @@ func (session GRPCSession) Auth() (ctx context.Context, err error) {
 		return
 	}
 
-	ctx, err = session.baseSession.auth(
-		userMD[0], secretMD[0],
-	)
+	var tenant TenantID
+	if tenantMD := md.Get("tenant"); len(tenantMD) > 0 {
+		tenant = TenantID(tenantMD[0])
+	}
+
+	if ctx, err = session.baseSession.auth(
+		tenant, userMD[0], secretMD[0],
+	); err != nil {
+		return
+	}
+
+	ctx = WithAttributes(ctx, grpcAttributes(session.ctx))
+	return
+}
+
+// grpcAttributes exposes a gRPC request to Conditions.
+func grpcAttributes(ctx context.Context) (attrs Attributes) {
+	attrs = make(Attributes)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			ip = p.Addr.String()
+		}
+
+		attrs["ip"] = ip
+	}
+
+	if method, ok := grpc.Method(ctx); ok {
+		attrs["method"] = method
+	}
 
 	return
 }
@@ -0,0 +1,23 @@
+package auth_test
+
+import (
+	"context"
+
+	"github.com/angadn/auth"
+)
+
+var ctx = context.Background()
+
+// testResource is a Resource for tests.
+type testResource struct {
+	kind auth.ResourceKind
+	id   auth.ResourceID
+}
+
+func (res testResource) Identifier() auth.ResourceID {
+	return res.id
+}
+
+func (res testResource) Kind() auth.ResourceKind {
+	return res.kind
+}
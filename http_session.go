@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"net"
 	"net/http"
 )
 
@@ -17,9 +18,11 @@ type HTTPSession struct {
 const (
 	headerUserID     = "X-Auth-User-ID"
 	headerUserSecret = "X-Auth-Secret"
+	headerTenantID   = "X-Auth-Tenant-ID"
 
 	queryUserID     = "authUserID"
 	queryUserSecret = "authSecret"
+	queryTenantID   = "authTenantID"
 )
 
 // NewHTTPSession is a constructor for HTTPSession.
@@ -49,11 +52,36 @@ func (session *HTTPSession) Auth() (ctx context.Context, err error) {
 		sec = session.req.URL.Query().Get(queryUserSecret)
 	}
 
-	ctx, err = session.auth(id, sec)
+	tenant := session.req.Header.Get(headerTenantID)
+
+	if tenant == "" {
+		tenant = session.req.URL.Query().Get(queryTenantID)
+	}
+
+	if ctx, err = session.auth(TenantID(tenant), id, sec); err == nil {
+		ctx = WithAttributes(ctx, requestAttributes(session.req))
+	}
+
 	session.err = err
 	return
 }
 
+// requestAttributes exposes an HTTP request to Conditions.
+func requestAttributes(req *http.Request) (attrs Attributes) {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+
+	attrs = Attributes{
+		"ip":     ip,
+		"method": req.Method,
+		"path":   req.URL.Path,
+	}
+
+	return
+}
+
 // Cancel checks if an error has occurred thus far and writes it to the HTTP response.
 func (session *HTTPSession) Cancel() {
 	session.cancelFunc()
@@ -0,0 +1,390 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/angadn/tabular"
+)
+
+// DefaultInvitationTTL is how long an Invitation stays pending unless configured
+// otherwise with ConfigInvitations.
+const DefaultInvitationTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrInvitationSecretNotSet when Invitations are sent or accepted before
+	// ConfigInvitations.
+	ErrInvitationSecretNotSet = fmt.Errorf("invitation secret not set")
+
+	// ErrInvalidInvitation when an Invitation's token is malformed, isn't signed by our
+	// secret, or is no longer pending as it was accepted, declined or revoked.
+	ErrInvalidInvitation = fmt.Errorf("invalid invitation")
+
+	// ErrInvitationExpired when an Invitation's token is past it's expiry.
+	ErrInvitationExpired = fmt.Errorf("invitation expired")
+
+	// ErrInvalidEmail when an Invitation is sent to something other than an email address.
+	ErrInvalidEmail = fmt.Errorf("invalid email")
+
+	// ErrUnknownUser when accepting an Invitation on behalf of a User that our Repository
+	// can't find.
+	ErrUnknownUser = fmt.Errorf("unknown user")
+
+	// ErrEmailMismatch when accepting an Invitation on behalf of a User whose email isn't
+	// the one it was sent to, or who has none unless ConfigBearerInvitations allows it.
+	ErrEmailMismatch = fmt.Errorf("invitation sent to another email")
+)
+
+var (
+	invitationSecret  []byte
+	invitationTTL     = DefaultInvitationTTL
+	bearerInvitations bool
+)
+
+// ConfigInvitations sets the secret that the tokens of Invitations are signed with, and
+// how long they stay pending, which defaults to DefaultInvitationTTL if not positive.
+// Changing the secret invalidates every pending Invitation's token.
+func ConfigInvitations(secret string, ttl time.Duration) {
+	invitationSecret = []byte(secret)
+	if invitationTTL = ttl; ttl <= 0 {
+		invitationTTL = DefaultInvitationTTL
+	}
+}
+
+// ConfigBearerInvitations lets Users that aren't EmailUsers accept any Invitation whose
+// token they hold, as bearers of it. Without it, only an EmailUser of the address that
+// an Invitation was sent to may accept it.
+func ConfigBearerInvitations(allow bool) {
+	bearerInvitations = allow
+}
+
+// InvitationID identifies an Invitation within it's tenant.
+type InvitationID string
+
+// Invitation is a Role offered to an email address that needn't belong to a User yet,
+// such as when sharing a Campaign with a colleague who's yet to sign up. It's granted
+// to whichever User accepts it's token before it expires.
+type Invitation struct {
+	ID        InvitationID
+	Email     string
+	Role      Role
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// invitationTable is a tabular representation of pending Invitations, and helps us
+// persist them in an SQL database.
+var invitationTable = tabular.New(
+	"invitations",
+
+	"tenant_id",
+	"invitation_id",
+	"email",
+	"resource_kind",
+	"resource_id",
+	"role_name",
+	"expires_at",
+	"created_at",
+)
+
+// Invitations exposes our internal InvitationRepository as a public API for our
+// business layer.
+var Invitations InvitationRepository
+
+// WithInvitationRepository configures the InvitationRepository implementation that
+// `auth` will refer.
+func WithInvitationRepository(r InvitationRepository) {
+	Invitations.InvitationRepositoryImpl = r
+}
+
+// InvitationRepositoryImpl defines an interface with which we can persist pending
+// Invitations. Every method reads and writes within the tenant that it's Context is
+// scoped to alone.
+type InvitationRepositoryImpl interface {
+	Put(ctx context.Context, invitation Invitation) (err error)
+	Take(ctx context.Context, id InvitationID) (
+		invitation Invitation, ok bool, err error,
+	)
+	Pending(ctx context.Context, resource Resource) (invitations []Invitation, err error)
+	Revoke(ctx context.Context, id InvitationID) (err error)
+	PurgeExpired(ctx context.Context) (n int64, err error)
+}
+
+// InvitationRepository persists our Invitations using an underlying
+// InvitationRepositoryImpl.
+type InvitationRepository struct {
+	InvitationRepositoryImpl
+}
+
+// invitationClaims are what an Invitation's token carries, signed by our secret.
+type invitationClaims struct {
+	ID        InvitationID `json:"i"`
+	Tenant    TenantID     `json:"n"`
+	Email     string       `json:"m"`
+	ExpiresAt int64        `json:"e"`
+}
+
+// Invite an email address to a Role, returning the Invitation along with it's token,
+// which is to be sent to the address and handed back to `Accept` or `Decline`. Only
+// the Invitation is persisted, and the token can't be recovered from it.
+func (repo InvitationRepository) Invite(ctx context.Context, email string, role Role) (
+	invitation Invitation, token string, err error,
+) {
+	if len(invitationSecret) == 0 {
+		err = ErrInvitationSecretNotSet
+		return
+	}
+
+	var address *mail.Address
+	if address, err = mail.ParseAddress(email); err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidEmail, err)
+		return
+	}
+
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		return
+	}
+
+	// The expiry is kept to the second, as that's what the token carries.
+	now := time.Now().UTC()
+	invitation = Invitation{
+		ID:        InvitationID(hex.EncodeToString(id)),
+		Email:     strings.ToLower(address.Address),
+		Role:      role,
+		ExpiresAt: now.Add(invitationTTL).Truncate(time.Second),
+		CreatedAt: now,
+	}
+
+	if err = repo.Put(ctx, invitation); err != nil {
+		return
+	}
+
+	token = signInvitation(invitationClaims{
+		ID:        invitation.ID,
+		Tenant:    TenantFromContext(ctx),
+		Email:     invitation.Email,
+		ExpiresAt: invitation.ExpiresAt.Unix(),
+	})
+
+	return
+}
+
+// Accept an Invitation on behalf of the User with the given ID, who must exist in our
+// Repository by now, granting her it's Role with `Groups.Add` and consuming it. She must
+// be an EmailUser of the address that the Invitation was sent to, failing with
+// ErrEmailMismatch, unless ConfigBearerInvitations allows Users without an email. The
+// Invitation is accepted within the tenant that it was sent in, and with an SQL
+// InvitationRepository, the grant commits along with consuming it as long as Groups
+// persist to the same *sql.DB. Otherwise, the Invitation is consumed first, and put back
+// should granting it's Role fail. Each Invitation can be accepted or declined only once.
+func (repo InvitationRepository) Accept(ctx context.Context, token string, id string) (
+	invitation Invitation, err error,
+) {
+	var claims invitationClaims
+	if ctx, claims, err = verifyInvitation(ctx, token); err != nil {
+		return
+	}
+
+	var user User
+	if user, err = findUser(ctx, id); err != nil {
+		return
+	}
+
+	if tenantUser, isTenantUser := user.(TenantUser); isTenantUser &&
+		tenantUser.GetTenantID() != claims.Tenant {
+		err = fmt.Errorf("%w: %s isn't of tenant %q", ErrUnknownUser, id, claims.Tenant)
+		return
+	}
+
+	if err = checkInvitee(user, claims); err != nil {
+		return
+	}
+
+	if _, inTx := TxFromContext(ctx); inTx ||
+		sameDatabase(repo.InvitationRepositoryImpl, Groups.GroupRepositoryImpl) {
+		err = repo.transact(ctx, func(ctx context.Context) (err error) {
+			if invitation, err = repo.take(ctx, claims.ID); err != nil {
+				return
+			}
+
+			err = Groups.Add(ctx, user, invitation.Role)
+			return
+		})
+
+		return
+	}
+
+	// Groups can't join a transaction of another database, lest they write to it
+	// instead, so the Invitation is put back should granting it's Role fail.
+	if invitation, err = repo.take(ctx, claims.ID); err != nil {
+		return
+	}
+
+	if err = Groups.Add(ctx, user, invitation.Role); err != nil {
+		if putErr := repo.Put(ctx, invitation); putErr != nil {
+			err = fmt.Errorf("%w, and putting %s back failed: %v", err, invitation.ID, putErr)
+		}
+	}
+
+	return
+}
+
+// checkInvitee checks that the User may accept the Invitation of the given claims: that
+// her email is the one it was sent to, or that she has none and ConfigBearerInvitations
+// allows her to accept it regardless.
+func checkInvitee(user User, claims invitationClaims) (err error) {
+	emailUser, ok := user.(EmailUser)
+	if !ok {
+		if !bearerInvitations {
+			err = fmt.Errorf("%w: %s has no email", ErrEmailMismatch, user.GetID())
+		}
+
+		return
+	}
+
+	if claims.Email == "" || !strings.EqualFold(emailUser.GetEmail(), claims.Email) {
+		err = fmt.Errorf("%w: %s isn't %s", ErrEmailMismatch, user.GetID(), claims.Email)
+	}
+
+	return
+}
+
+// Decline an Invitation, consuming it without granting it's Role.
+func (repo InvitationRepository) Decline(ctx context.Context, token string) (err error) {
+	var claims invitationClaims
+	if ctx, claims, err = verifyInvitation(ctx, token); err != nil {
+		return
+	}
+
+	_, err = repo.take(ctx, claims.ID)
+	return
+}
+
+// take consumes a pending Invitation, failing with ErrInvalidInvitation if there's none.
+func (repo InvitationRepository) take(ctx context.Context, id InvitationID) (
+	invitation Invitation, err error,
+) {
+	var ok bool
+	if invitation, ok, err = repo.Take(ctx, id); err != nil {
+		return
+	} else if !ok {
+		err = fmt.Errorf("%w: %s is no longer pending", ErrInvalidInvitation, id)
+	}
+
+	return
+}
+
+// invitationTransactor is implemented by InvitationRepositoryImpls that can run a
+// function within a transaction that the Context carries to it.
+type invitationTransactor interface {
+	transact(ctx context.Context, fn func(ctx context.Context) error) (err error)
+}
+
+// transact runs the function within a transaction if the InvitationRepositoryImpl
+// supports them, or else as it is.
+func (repo InvitationRepository) transact(
+	ctx context.Context, fn func(ctx context.Context) error,
+) (err error) {
+	if impl, ok := repo.InvitationRepositoryImpl.(invitationTransactor); ok {
+		err = impl.transact(ctx, fn)
+		return
+	}
+
+	err = fn(ctx)
+	return
+}
+
+// signInvitation encodes the claims as a token, signed by our secret.
+func signInvitation(claims invitationClaims) (token string) {
+	b, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	token = payload + "." + base64.RawURLEncoding.EncodeToString(invitationMAC(payload))
+	return
+}
+
+// verifyInvitation checks the token's signature and expiry, and scopes the Context to
+// the tenant that it was sent in. A Context that's already scoped to another tenant
+// fails with ErrInvalidInvitation.
+func verifyInvitation(ctx context.Context, token string) (
+	scoped context.Context, claims invitationClaims, err error,
+) {
+	if len(invitationSecret) == 0 {
+		err = ErrInvitationSecretNotSet
+		return
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		err = fmt.Errorf("%w: malformed token", ErrInvalidInvitation)
+		return
+	}
+
+	var sig, b []byte
+	if sig, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidInvitation, err)
+		return
+	}
+
+	if !hmac.Equal(sig, invitationMAC(parts[0])) {
+		err = fmt.Errorf("%w: bad signature", ErrInvalidInvitation)
+		return
+	}
+
+	if b, err = base64.RawURLEncoding.DecodeString(parts[0]); err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidInvitation, err)
+		return
+	}
+
+	if err = json.Unmarshal(b, &claims); err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidInvitation, err)
+		return
+	}
+
+	if !time.Now().Before(time.Unix(claims.ExpiresAt, 0)) {
+		err = fmt.Errorf("%w: %s", ErrInvitationExpired, claims.ID)
+		return
+	}
+
+	if tenant := TenantFromContext(ctx); tenant != "" && tenant != claims.Tenant {
+		err = fmt.Errorf("%w: sent in another tenant", ErrInvalidInvitation)
+		return
+	}
+
+	scoped = WithTenant(ctx, claims.Tenant)
+	return
+}
+
+// invitationMAC is the signature of a token's payload by our secret.
+func invitationMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, invitationSecret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// findUser finds the User with the given ID in our Repository, failing with
+// ErrUnknownUser if she isn't found.
+func findUser(ctx context.Context, id string) (user User, err error) {
+	if !isRepoSet {
+		err = fmt.Errorf("%w: %s", ErrUnknownUser, id)
+		return
+	}
+
+	var ok bool
+	if user, ok, err = repo.FindAuthUser(ctx, id); err != nil {
+		return
+	} else if !ok {
+		err = fmt.Errorf("%w: %s", ErrUnknownUser, id)
+	}
+
+	return
+}
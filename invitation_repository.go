@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/angadn/tabular"
+)
+
+// invitationSQLRepository implements InvitationRepository in SQL, per it's dialect.
+type invitationSQLRepository struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// InvitationMySQLRepository implements InvitationRepository in MySQL.
+type InvitationMySQLRepository struct {
+	invitationSQLRepository
+}
+
+// NewInvitationMySQLRepositoryImpl is a constructor for InvitationMySQLRepository.
+func NewInvitationMySQLRepositoryImpl(db *sql.DB) (repo InvitationRepository, err error) {
+	mysqlRepo := new(InvitationMySQLRepository)
+	mysqlRepo.db = db
+	mysqlRepo.dialect = mysqlDialect{}
+	repo.InvitationRepositoryImpl = mysqlRepo
+	err = mysqlRepo.db.Ping()
+	return
+}
+
+func (repo *invitationSQLRepository) exec(
+	ctx context.Context, query string, args ...interface{},
+) (sql.Result, error) {
+	return connOf(ctx, repo.db).ExecContext(ctx, repo.dialect.rebind(query), args...)
+}
+
+func (repo *invitationSQLRepository) query(
+	ctx context.Context, query string, args ...interface{},
+) (*sql.Rows, error) {
+	return connOf(ctx, repo.db).QueryContext(ctx, repo.dialect.rebind(query), args...)
+}
+
+// transact runs the function within the transaction that the Context carries, or else
+// within one of it's own that it commits unless the function fails, carrying it to the
+// function's Context so that Groups persisting to the same database join it.
+func (repo *invitationSQLRepository) transact(
+	ctx context.Context, fn func(ctx context.Context) error,
+) (err error) {
+	if _, ok := TxFromContext(ctx); ok {
+		err = fn(ctx)
+		return
+	}
+
+	var tx *sql.Tx
+	if tx, err = repo.db.BeginTx(ctx, nil); err != nil {
+		return
+	}
+
+	ctx = WithTx(ctx, tx)
+	if err = fn(ctx); err != nil {
+		Rollback(ctx)
+		return
+	}
+
+	err = Commit(ctx)
+	return
+}
+
+// Put persists a pending Invitation.
+func (repo *invitationSQLRepository) Put(ctx context.Context, invitation Invitation) (
+	err error,
+) {
+	_, err = repo.exec(ctx, invitationTable.Insertion("%s"),
+		string(TenantFromContext(ctx)),
+		string(invitation.ID),
+		invitation.Email,
+		string(invitation.Role.Resource.Kind()),
+		string(invitation.Role.Resource.Identifier()),
+		string(invitation.Role.Name),
+		invitation.ExpiresAt.UTC(),
+		invitation.CreatedAt.UTC(),
+	)
+
+	return
+}
+
+// Take deletes a pending Invitation and returns it, if it's still pending. Of concurrent
+// calls to Take the same Invitation, only one finds it.
+func (repo *invitationSQLRepository) Take(ctx context.Context, id InvitationID) (
+	invitation Invitation, ok bool, err error,
+) {
+	var invitations []Invitation
+	if invitations, err = repo.list(
+		ctx,
+		"`invitations`.`invitation_id` = ?"+repo.dialect.forUpdate(),
+		string(id),
+	); err != nil || len(invitations) == 0 {
+		return
+	}
+
+	var res sql.Result
+	if res, err = repo.exec(
+		ctx,
+		"DELETE FROM `invitations` WHERE `tenant_id` = ? AND `invitation_id` = ?",
+		string(TenantFromContext(ctx)),
+		string(id),
+	); err != nil {
+		return
+	}
+
+	var n int64
+	if n, err = res.RowsAffected(); err != nil {
+		return
+	}
+
+	invitation, ok = invitations[0], n > 0
+	return
+}
+
+// Pending lists the Invitations to Roles upon a Resource that are yet to be accepted,
+// declined, revoked or expire, oldest first.
+func (repo *invitationSQLRepository) Pending(ctx context.Context, resource Resource) (
+	invitations []Invitation, err error,
+) {
+	invitations, err = repo.list(
+		ctx,
+		"`invitations`.`resource_kind` = ? AND `invitations`.`resource_id` = ? AND `invitations`.`expires_at` > ? ORDER BY `invitations`.`created_at`, `invitations`.`invitation_id`",
+		string(resource.Kind()),
+		string(resource.Identifier()),
+		time.Now().UTC(),
+	)
+
+	return
+}
+
+// Revoke a pending Invitation, so that it's token can no longer be accepted. Revoke is
+// an idempotent action.
+func (repo *invitationSQLRepository) Revoke(ctx context.Context, id InvitationID) (
+	err error,
+) {
+	_, err = repo.exec(
+		ctx,
+		"DELETE FROM `invitations` WHERE `tenant_id` = ? AND `invitation_id` = ?",
+		string(TenantFromContext(ctx)),
+		string(id),
+	)
+
+	return
+}
+
+// PurgeExpired deletes the Invitations that have expired, returning how many were.
+func (repo *invitationSQLRepository) PurgeExpired(ctx context.Context) (
+	n int64, err error,
+) {
+	var res sql.Result
+	if res, err = repo.exec(
+		ctx,
+		"DELETE FROM `invitations` WHERE `tenant_id` = ? AND `expires_at` <= ?",
+		string(TenantFromContext(ctx)),
+		time.Now().UTC(),
+	); err != nil {
+		return
+	}
+
+	n, err = res.RowsAffected()
+	return
+}
+
+// list the Invitations of the Context's tenant that match an SQL fragment, which may
+// be followed by an ORDER BY clause.
+func (repo *invitationSQLRepository) list(
+	ctx context.Context, fragment string, args ...interface{},
+) (invitations []Invitation, err error) {
+	var rows *sql.Rows
+	if rows, err = repo.query(ctx, invitationTable.Selection(
+		"SELECT %s FROM `invitations` WHERE `invitations`.`tenant_id` = ? AND "+fragment,
+	), append([]interface{}{string(TenantFromContext(ctx))}, args...)...); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			invitation Invitation
+			resource   resourceImpl
+		)
+
+		if err = newScanner(
+			&tabular.Scapegoat{},
+			&invitation.ID,
+			&invitation.Email,
+			&resource.kind,
+			&resource.id,
+			&invitation.Role.Name,
+			timestamp{&invitation.ExpiresAt},
+			timestamp{&invitation.CreatedAt},
+		).Scan(rows); err != nil {
+			return
+		}
+
+		invitation.Role.Resource = resource
+		invitations = append(invitations, invitation)
+	}
+
+	err = rows.Err()
+	return
+}
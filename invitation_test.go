@@ -0,0 +1,142 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/authtest"
+)
+
+// bearer is a User without an email.
+type bearer struct {
+	auth.RBACUser
+	id string
+}
+
+func (user bearer) GetID() string {
+	return user.id
+}
+
+func TestInvitationAcceptEmail(t *testing.T) {
+	auth.ConfigInvitations("secret", time.Hour)
+
+	var (
+		bob     = authtest.User{ID: "bob", Email: "Bob@example.com", IsVerified: true}
+		mallory = authtest.User{ID: "mallory", Email: "mallory@example.com", IsVerified: true}
+		carol   = bearer{id: "carol"}
+		editor  = auth.NewRole("editor", testResource{"campaign", "1"})
+		fixture = authtest.Install(bob, mallory, carol)
+	)
+
+	_, token, err := auth.Invitations.Invite(ctx, "bob@example.com", editor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{mallory.ID, carol.id} {
+		if _, err = auth.Invitations.Accept(ctx, token, id); !errors.Is(
+			err, auth.ErrEmailMismatch,
+		) {
+			t.Errorf("Accept(%s) = %v, want %v", id, err, auth.ErrEmailMismatch)
+		}
+	}
+
+	if _, err = auth.Invitations.Accept(ctx, token, bob.ID); err != nil {
+		t.Fatalf("Accept(bob) = %v", err)
+	}
+
+	ok, err := fixture.Groups.IsUserInAny(ctx, bob, auth.Roles{editor})
+	if err != nil || !ok {
+		t.Errorf("expected bob to be granted %s, got %v, %v", editor.Name, ok, err)
+	}
+
+	auth.ConfigBearerInvitations(true)
+	defer auth.ConfigBearerInvitations(false)
+
+	if _, token, err = auth.Invitations.Invite(ctx, "carol@example.com", editor); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = auth.Invitations.Accept(ctx, token, mallory.ID); !errors.Is(
+		err, auth.ErrEmailMismatch,
+	) {
+		t.Errorf("Accept(mallory) = %v, want %v", err, auth.ErrEmailMismatch)
+	}
+
+	if _, err = auth.Invitations.Accept(ctx, token, carol.id); err != nil {
+		t.Errorf("Accept(carol) as a bearer = %v", err)
+	}
+}
+
+// failingGroups is a GroupRepositoryImpl that fails to Add.
+type failingGroups struct {
+	auth.GroupRepositoryImpl
+}
+
+var errFailingAdd = errors.New("failing Add")
+
+func (failingGroups) Add(
+	ctx context.Context, user auth.User, role auth.Role, opts ...auth.GrantOption,
+) error {
+	return errFailingAdd
+}
+
+func TestInvitationAcceptAcrossDatabases(t *testing.T) {
+	auth.ConfigInvitations("secret", time.Hour)
+
+	var (
+		bob      = authtest.User{ID: "bob", Email: "bob@example.com", IsVerified: true}
+		campaign = testResource{"campaign", "1"}
+		editor   = auth.NewRole("editor", campaign)
+		invDB    = openSQLite(t)
+	)
+
+	authtest.Install(bob)
+	invitations, err := auth.NewInvitationSQLiteRepositoryImpl(invDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := auth.NewGroupSQLiteRepositoryImpl(openSQLite(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth.WithInvitationRepository(invitations)
+	auth.WithGroupRepository(auth.GroupRepository{GroupRepositoryImpl: failingGroups{groups}})
+
+	_, token, err := auth.Invitations.Invite(ctx, bob.Email, editor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed grant puts the Invitation back, so that it can be accepted again.
+	if _, err = auth.Invitations.Accept(ctx, token, bob.ID); !errors.Is(err, errFailingAdd) {
+		t.Fatalf("Accept = %v, want %v", err, errFailingAdd)
+	}
+
+	pending, err := auth.Invitations.Pending(ctx, campaign)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("expected the Invitation to be pending again, got %v, %v", pending, err)
+	}
+
+	auth.WithGroupRepository(groups)
+	if _, err = auth.Invitations.Accept(ctx, token, bob.ID); err != nil {
+		t.Fatalf("Accept = %v", err)
+	}
+
+	if ok, err := groups.IsUserInAny(ctx, bob, auth.Roles{editor}); err != nil || !ok {
+		t.Errorf("expected bob to be granted %s by Groups, got %v, %v", editor.Name, ok, err)
+	}
+
+	// Nothing was written through the transaction of the invitations' database.
+	var n int
+	if err = invDB.QueryRowContext(
+		ctx, "SELECT COUNT(*) FROM `groups`",
+	).Scan(&n); err != nil || n != 0 {
+		t.Errorf("expected no grants in the invitations' database, got %d, %v", n, err)
+	}
+}
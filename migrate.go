@@ -0,0 +1,770 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/angadn/tabular"
+)
+
+// Migration is a versioned change to our tables. Migrations are applied in order of their
+// Versions, and each is applied once, as recorded in the `auth_migrations` table.
+type Migration struct {
+	Version     int
+	Description string
+	Statements  []string
+
+	// Table that the Migration changes. The Statements of Migrations to the `groups`
+	// table are subject to the TableOptions they're applied with.
+	Table string
+}
+
+// Schema holds the Migrations of our tables in an SQL database, along with what it takes
+// to check a database for drift from them.
+type Schema struct {
+	Migrations []Migration
+
+	dialect dialect
+
+	// indexesQuery lists the names of the indexes upon a table, in a schema unless it's
+	// empty.
+	indexesQuery func(schema string, table string) (query string, args []interface{})
+
+	// primaryKey is the name of the index of a table's primary key, which is the unique
+	// key that `Add` and `AddMember` depend upon to be idempotent.
+	primaryKey func(table string) string
+
+	// indexes expected upon each of our tables, other than their primary keys.
+	indexes map[string][]string
+}
+
+// ErrSchemaDrift when a database's tables don't match the Schema's Migrations.
+var ErrSchemaDrift = fmt.Errorf("schema drift")
+
+// migrationsTable records the Versions of the Migrations applied to a database.
+var migrationsTable = tabular.New(
+	"auth_migrations",
+
+	"version",
+	"description",
+	"applied_at",
+)
+
+const createMigrationsTable = "CREATE TABLE IF NOT EXISTS `auth_migrations` (" +
+	"`version` INT NOT NULL, " +
+	"`description` VARCHAR(255) NOT NULL, " +
+	"`applied_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+	"PRIMARY KEY (`version`)" +
+	")"
+
+// MySQLSchema is our Schema in MySQL, as persisted to by GroupMySQLRepository,
+// TeamMySQLRepository, InvitationMySQLRepository and the rebac package's
+// MySQLTupleStore.
+var MySQLSchema = Schema{
+	Migrations: []Migration{
+		{
+			Version:     1,
+			Description: "create groups",
+			Table:       "groups",
+			Statements: []string{
+				"CREATE TABLE IF NOT EXISTS `groups` (" +
+					"`resource_kind` VARCHAR(64) NOT NULL, " +
+					"`resource_id` VARCHAR(191) NOT NULL, " +
+					"`role_name` VARCHAR(64) NOT NULL, " +
+					"`user_id` VARCHAR(191) NOT NULL, " +
+					"`principal_type` VARCHAR(16) NOT NULL DEFAULT 'user', " +
+					"`effect` VARCHAR(16) NOT NULL DEFAULT 'allow', " +
+					"`not_before` DATETIME(6) NULL, " +
+					"`expires_at` DATETIME(6) NULL, " +
+					"`condition` TEXT NULL, " +
+					"`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+					"`updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+					"PRIMARY KEY (`resource_kind`, `resource_id`, `role_name`, `user_id`, `principal_type`), " +
+					"KEY `groups_user_kind` (`user_id`, `resource_kind`), " +
+					"KEY `groups_expires_at` (`expires_at`)" +
+					") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+			},
+		},
+		{
+			Version:     2,
+			Description: "create team_members",
+			Table:       "team_members",
+			Statements: []string{
+				"CREATE TABLE IF NOT EXISTS `team_members` (" +
+					"`team_id` VARCHAR(191) NOT NULL, " +
+					"`member_type` VARCHAR(16) NOT NULL, " +
+					"`member_id` VARCHAR(191) NOT NULL, " +
+					"`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+					"`updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+					"PRIMARY KEY (`team_id`, `member_type`, `member_id`), " +
+					"KEY `team_members_member` (`member_type`, `member_id`)" +
+					") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+			},
+		},
+		{
+			Version:     3,
+			Description: "add tenant_id to groups",
+			Table:       "groups",
+			Statements: []string{
+				"ALTER TABLE `groups` " +
+					"ADD COLUMN `tenant_id` VARCHAR(64) NOT NULL DEFAULT '' FIRST, " +
+					"DROP PRIMARY KEY, " +
+					"ADD PRIMARY KEY (`tenant_id`, `resource_kind`, `resource_id`, `role_name`, `user_id`, `principal_type`)",
+			},
+		},
+		{
+			Version:     4,
+			Description: "add tenant_id to team_members",
+			Table:       "team_members",
+			Statements: []string{
+				"ALTER TABLE `team_members` " +
+					"ADD COLUMN `tenant_id` VARCHAR(64) NOT NULL DEFAULT '' FIRST, " +
+					"DROP PRIMARY KEY, " +
+					"ADD PRIMARY KEY (`tenant_id`, `team_id`, `member_type`, `member_id`)",
+			},
+		},
+		{
+			Version:     5,
+			Description: "index groups by created_at",
+			Table:       "groups",
+			Statements: []string{
+				"CREATE INDEX `groups_created_at` ON `groups` (`tenant_id`, `resource_kind`, `resource_id`, `created_at`)",
+			},
+		},
+		{
+			Version:     6,
+			Description: "create invitations",
+			Table:       "invitations",
+			Statements: []string{
+				"CREATE TABLE IF NOT EXISTS `invitations` (" +
+					"`tenant_id` VARCHAR(64) NOT NULL DEFAULT '', " +
+					"`invitation_id` VARCHAR(64) NOT NULL, " +
+					"`email` VARCHAR(191) NOT NULL, " +
+					"`resource_kind` VARCHAR(64) NOT NULL, " +
+					"`resource_id` VARCHAR(191) NOT NULL, " +
+					"`role_name` VARCHAR(64) NOT NULL, " +
+					"`expires_at` DATETIME(6) NOT NULL, " +
+					"`created_at` DATETIME(6) NOT NULL, " +
+					"PRIMARY KEY (`tenant_id`, `invitation_id`), " +
+					"KEY `invitations_resource` (`tenant_id`, `resource_kind`, `resource_id`), " +
+					"KEY `invitations_expires_at` (`expires_at`)" +
+					") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+			},
+		},
+		{
+			// Tuples of the rebac package's MySQLTupleStore, whose primary key makes
+			// it's Writes idempotent, and serves reads by object. Reads of a relation
+			// across objects are served by `relation_tuples_relation`.
+			Version:     7,
+			Description: "create relation_tuples",
+			Table:       "relation_tuples",
+			Statements: []string{
+				"CREATE TABLE IF NOT EXISTS `relation_tuples` (" +
+					"`object_kind` VARCHAR(64) NOT NULL, " +
+					"`object_id` VARCHAR(191) NOT NULL, " +
+					"`relation` VARCHAR(64) NOT NULL, " +
+					"`subject_kind` VARCHAR(64) NOT NULL, " +
+					"`subject_id` VARCHAR(191) NOT NULL, " +
+					"`subject_relation` VARCHAR(64) NOT NULL DEFAULT '', " +
+					"`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+					"PRIMARY KEY (`object_kind`, `object_id`, `relation`, `subject_kind`, `subject_id`, `subject_relation`), " +
+					"KEY `relation_tuples_relation` (`object_kind`, `relation`)" +
+					") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+			},
+		},
+		{
+			Version:     8,
+			Description: "index groups by tenant_id",
+			Table:       "groups",
+			Statements: []string{
+				"ALTER TABLE `groups` " +
+					"DROP INDEX `groups_user_kind`, " +
+					"DROP INDEX `groups_expires_at`, " +
+					"ADD INDEX `groups_tenant_user_kind` (`tenant_id`, `user_id`, `resource_kind`), " +
+					"ADD INDEX `groups_tenant_expires_at` (`tenant_id`, `expires_at`)",
+			},
+		},
+	},
+	dialect: mysqlDialect{},
+	indexesQuery: func(schema string, table string) (string, []interface{}) {
+		return "SELECT DISTINCT `INDEX_NAME` FROM `information_schema`.`STATISTICS` WHERE `TABLE_SCHEMA` = COALESCE(NULLIF(?, ''), DATABASE()) AND `TABLE_NAME` = ?",
+			[]interface{}{schema, table}
+	},
+	primaryKey: func(table string) string {
+		return "PRIMARY"
+	},
+	indexes: map[string][]string{
+		"groups": {
+			"groups_tenant_user_kind", "groups_tenant_expires_at", "groups_created_at",
+		},
+		"team_members": {"team_members_member"},
+		"invitations":  {"invitations_resource", "invitations_expires_at"},
+	},
+}
+
+// portableMigrations are the Migrations that PostgresSchema and SQLiteSchema share.
+var portableMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "create groups",
+		Table:       "groups",
+		Statements: []string{
+			"CREATE TABLE IF NOT EXISTS `groups` (" +
+				"`resource_kind` VARCHAR(64) NOT NULL, " +
+				"`resource_id` VARCHAR(191) NOT NULL, " +
+				"`role_name` VARCHAR(64) NOT NULL, " +
+				"`user_id` VARCHAR(191) NOT NULL, " +
+				"`principal_type` VARCHAR(16) NOT NULL DEFAULT 'user', " +
+				"`effect` VARCHAR(16) NOT NULL DEFAULT 'allow', " +
+				"`not_before` TIMESTAMP NULL, " +
+				"`expires_at` TIMESTAMP NULL, " +
+				"`condition` TEXT NULL, " +
+				"`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+				"`updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+				"PRIMARY KEY (`resource_kind`, `resource_id`, `role_name`, `user_id`, `principal_type`)" +
+				")",
+			"CREATE INDEX IF NOT EXISTS `groups_user_kind` ON `groups` (`user_id`, `resource_kind`)",
+			"CREATE INDEX IF NOT EXISTS `groups_expires_at` ON `groups` (`expires_at`)",
+		},
+	},
+	{
+		Version:     2,
+		Description: "create team_members",
+		Table:       "team_members",
+		Statements: []string{
+			"CREATE TABLE IF NOT EXISTS `team_members` (" +
+				"`team_id` VARCHAR(191) NOT NULL, " +
+				"`member_type` VARCHAR(16) NOT NULL, " +
+				"`member_id` VARCHAR(191) NOT NULL, " +
+				"`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+				"`updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+				"PRIMARY KEY (`team_id`, `member_type`, `member_id`)" +
+				")",
+			"CREATE INDEX IF NOT EXISTS `team_members_member` ON `team_members` (`member_type`, `member_id`)",
+		},
+	},
+}
+
+// invitationsMigration is the Migration that creates `invitations`, which PostgresSchema
+// and SQLiteSchema share.
+var invitationsMigration = Migration{
+	Version:     6,
+	Description: "create invitations",
+	Table:       "invitations",
+	Statements: []string{
+		"CREATE TABLE IF NOT EXISTS `invitations` (" +
+			"`tenant_id` VARCHAR(64) NOT NULL DEFAULT '', " +
+			"`invitation_id` VARCHAR(64) NOT NULL, " +
+			"`email` VARCHAR(191) NOT NULL, " +
+			"`resource_kind` VARCHAR(64) NOT NULL, " +
+			"`resource_id` VARCHAR(191) NOT NULL, " +
+			"`role_name` VARCHAR(64) NOT NULL, " +
+			"`expires_at` TIMESTAMP NOT NULL, " +
+			"`created_at` TIMESTAMP NOT NULL, " +
+			"PRIMARY KEY (`tenant_id`, `invitation_id`)" +
+			")",
+		"CREATE INDEX IF NOT EXISTS `invitations_resource` ON `invitations` (`tenant_id`, `resource_kind`, `resource_id`)",
+		"CREATE INDEX IF NOT EXISTS `invitations_expires_at` ON `invitations` (`expires_at`)",
+	},
+}
+
+// tenantIndexesMigration is the Migration that leads the indexes upon `groups` with
+// `tenant_id`, as every query filters on it, which PostgresSchema and SQLiteSchema share.
+var tenantIndexesMigration = Migration{
+	Version:     8,
+	Description: "index groups by tenant_id",
+	Table:       "groups",
+	Statements: []string{
+		"DROP INDEX IF EXISTS `groups_user_kind`",
+		"DROP INDEX IF EXISTS `groups_expires_at`",
+		"CREATE INDEX IF NOT EXISTS `groups_tenant_user_kind` ON `groups` (`tenant_id`, `user_id`, `resource_kind`)",
+		"CREATE INDEX IF NOT EXISTS `groups_tenant_expires_at` ON `groups` (`tenant_id`, `expires_at`)",
+	},
+}
+
+// PostgresSchema is our Schema in PostgreSQL, as persisted to by GroupPostgresRepository,
+// TeamPostgresRepository and InvitationPostgresRepository.
+var PostgresSchema = Schema{
+	Migrations: append(append([]Migration{}, portableMigrations...),
+		Migration{
+			Version:     3,
+			Description: "add tenant_id to groups",
+			Table:       "groups",
+			Statements: []string{
+				"ALTER TABLE `groups` ADD COLUMN `tenant_id` VARCHAR(64) NOT NULL DEFAULT ''",
+				"ALTER TABLE `groups` DROP CONSTRAINT `groups_pkey`",
+				"ALTER TABLE `groups` ADD PRIMARY KEY (`tenant_id`, `resource_kind`, `resource_id`, `role_name`, `user_id`, `principal_type`)",
+			},
+		},
+		Migration{
+			Version:     4,
+			Description: "add tenant_id to team_members",
+			Table:       "team_members",
+			Statements: []string{
+				"ALTER TABLE `team_members` ADD COLUMN `tenant_id` VARCHAR(64) NOT NULL DEFAULT ''",
+				"ALTER TABLE `team_members` DROP CONSTRAINT `team_members_pkey`",
+				"ALTER TABLE `team_members` ADD PRIMARY KEY (`tenant_id`, `team_id`, `member_type`, `member_id`)",
+			},
+		},
+		Migration{
+			Version:     5,
+			Description: "index groups by created_at",
+			Table:       "groups",
+			Statements: []string{
+				"CREATE INDEX IF NOT EXISTS `groups_created_at` ON `groups` (`tenant_id`, `resource_kind`, `resource_id`, `created_at`)",
+			},
+		},
+		invitationsMigration,
+		tenantIndexesMigration,
+	),
+	dialect: postgresDialect{},
+	indexesQuery: func(schema string, table string) (string, []interface{}) {
+		return "SELECT `indexname` FROM `pg_indexes` WHERE `schemaname` = COALESCE(NULLIF(?, ''), current_schema()) AND `tablename` = ?",
+			[]interface{}{schema, table}
+	},
+	primaryKey: func(table string) string {
+		return table + "_pkey"
+	},
+	indexes: map[string][]string{
+		"groups": {
+			"groups_tenant_user_kind", "groups_tenant_expires_at", "groups_created_at",
+		},
+		"team_members": {"team_members_member"},
+		"invitations":  {"invitations_resource", "invitations_expires_at"},
+	},
+}
+
+// SQLiteSchema is our Schema in SQLite, as persisted to by GroupSQLiteRepository,
+// TeamSQLiteRepository and InvitationSQLiteRepository, which apply it on their own.
+var SQLiteSchema = Schema{
+	// SQLite can't alter a table's primary key, so tables are rebuilt instead.
+	Migrations: append(append([]Migration{}, portableMigrations...),
+		Migration{
+			Version:     3,
+			Description: "add tenant_id to groups",
+			Table:       "groups",
+			Statements: []string{
+				"ALTER TABLE `groups` RENAME TO `groups_migrating`",
+				"CREATE TABLE `groups` (" +
+					"`tenant_id` VARCHAR(64) NOT NULL DEFAULT '', " +
+					"`resource_kind` VARCHAR(64) NOT NULL, " +
+					"`resource_id` VARCHAR(191) NOT NULL, " +
+					"`role_name` VARCHAR(64) NOT NULL, " +
+					"`user_id` VARCHAR(191) NOT NULL, " +
+					"`principal_type` VARCHAR(16) NOT NULL DEFAULT 'user', " +
+					"`effect` VARCHAR(16) NOT NULL DEFAULT 'allow', " +
+					"`not_before` TIMESTAMP NULL, " +
+					"`expires_at` TIMESTAMP NULL, " +
+					"`condition` TEXT NULL, " +
+					"`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+					"`updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+					"PRIMARY KEY (`tenant_id`, `resource_kind`, `resource_id`, `role_name`, `user_id`, `principal_type`)" +
+					")",
+				"INSERT INTO `groups` (`resource_kind`, `resource_id`, `role_name`, `user_id`, `principal_type`, `effect`, `not_before`, `expires_at`, `condition`, `created_at`, `updated_at`) SELECT `resource_kind`, `resource_id`, `role_name`, `user_id`, `principal_type`, `effect`, `not_before`, `expires_at`, `condition`, `created_at`, `updated_at` FROM `groups_migrating`",
+				"DROP TABLE `groups_migrating`",
+				"CREATE INDEX IF NOT EXISTS `groups_user_kind` ON `groups` (`user_id`, `resource_kind`)",
+				"CREATE INDEX IF NOT EXISTS `groups_expires_at` ON `groups` (`expires_at`)",
+			},
+		},
+		Migration{
+			Version:     4,
+			Description: "add tenant_id to team_members",
+			Table:       "team_members",
+			Statements: []string{
+				"ALTER TABLE `team_members` RENAME TO `team_members_migrating`",
+				"CREATE TABLE `team_members` (" +
+					"`tenant_id` VARCHAR(64) NOT NULL DEFAULT '', " +
+					"`team_id` VARCHAR(191) NOT NULL, " +
+					"`member_type` VARCHAR(16) NOT NULL, " +
+					"`member_id` VARCHAR(191) NOT NULL, " +
+					"`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+					"`updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+					"PRIMARY KEY (`tenant_id`, `team_id`, `member_type`, `member_id`)" +
+					")",
+				"INSERT INTO `team_members` (`team_id`, `member_type`, `member_id`, `created_at`, `updated_at`) SELECT `team_id`, `member_type`, `member_id`, `created_at`, `updated_at` FROM `team_members_migrating`",
+				"DROP TABLE `team_members_migrating`",
+				"CREATE INDEX IF NOT EXISTS `team_members_member` ON `team_members` (`member_type`, `member_id`)",
+			},
+		},
+		Migration{
+			Version:     5,
+			Description: "index groups by created_at",
+			Table:       "groups",
+			Statements: []string{
+				"CREATE INDEX IF NOT EXISTS `groups_created_at` ON `groups` (`tenant_id`, `resource_kind`, `resource_id`, `created_at`)",
+			},
+		},
+		invitationsMigration,
+		tenantIndexesMigration,
+	),
+	dialect: sqliteDialect{},
+	indexesQuery: func(schema string, table string) (string, []interface{}) {
+		master := "`sqlite_master`"
+		if schema != "" {
+			master = fmt.Sprintf("`%s`.`sqlite_master`", schema)
+		}
+
+		return "SELECT `name` FROM " + master + " WHERE `type` = 'index' AND `tbl_name` = ?",
+			[]interface{}{table}
+	},
+	primaryKey: func(table string) string {
+		return "sqlite_autoindex_" + table + "_1"
+	},
+	indexes: map[string][]string{
+		"groups": {
+			"groups_tenant_user_kind", "groups_tenant_expires_at", "groups_created_at",
+		},
+		"team_members": {"team_members_member"},
+		"invitations":  {"invitations_resource", "invitations_expires_at"},
+	},
+}
+
+// Migrate applies the Migrations of MySQLSchema that the database is yet to have.
+func Migrate(ctx context.Context, db *sql.DB, opts ...TableOption) (err error) {
+	err = MySQLSchema.Migrate(ctx, db, opts...)
+	return
+}
+
+// Drift lists how the database differs from MySQLSchema. See Schema.Drift.
+func Drift(ctx context.Context, db *sql.DB, opts ...TableOption) (
+	drift []string, err error,
+) {
+	drift, err = MySQLSchema.Drift(ctx, db, opts...)
+	return
+}
+
+// Migrate applies the Migrations that the database is yet to have, in order of their
+// Versions. Each Migration is applied in a transaction of it's own, along with it's
+// record in `auth_migrations`, though databases such as MySQL commit DDL statements
+// implicitly. Tables are only created when they don't already exist, so that a
+// hand-written schema may be adopted, after which `Drift` should be checked. TableOptions
+// name the `groups` table and it's columns, as they do for the GroupRepository.
+func (schema Schema) Migrate(
+	ctx context.Context, db *sql.DB, opts ...TableOption,
+) (err error) {
+	groupTable := NewGroupTable(opts...)
+	if err = groupTable.Validate(); err != nil {
+		return
+	}
+
+	var applied map[int]bool
+	if applied, err = schema.applied(ctx, db, true); err != nil {
+		return
+	}
+
+	for _, m := range schema.sorted() {
+		if applied[m.Version] {
+			continue
+		}
+
+		if err = schema.apply(ctx, db, m, groupTable); err != nil {
+			err = fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+			return
+		}
+	}
+
+	return
+}
+
+// apply a single Migration, recording it in `auth_migrations`.
+func (schema Schema) apply(
+	ctx context.Context, db *sql.DB, m Migration, groupTable GroupTable,
+) (err error) {
+	var tx *sql.Tx
+	if tx, err = db.BeginTx(ctx, nil); err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, stmt := range m.Statements {
+		if m.Table == table.Name {
+			stmt = groupTable.rename(stmt)
+		}
+
+		if _, err = tx.ExecContext(ctx, schema.dialect.rebind(stmt)); err != nil {
+			return
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, schema.dialect.rebind(migrationsTable.Insertion(
+		"%s", "applied_at", "CURRENT_TIMESTAMP",
+	)), m.Version, m.Description); err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// Version is the highest Version of the Migrations applied to the database, or 0 if none
+// were.
+func (schema Schema) Version(ctx context.Context, db *sql.DB) (version int, err error) {
+	var applied map[int]bool
+	if applied, err = schema.applied(ctx, db, false); err != nil {
+		return
+	}
+
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+
+	return
+}
+
+// Drift lists how the database differs from the Schema: Migrations that are yet to be
+// applied or that the Schema doesn't know of, and missing or unexpected columns and
+// missing indexes upon our tables. It is empty when there's no drift, and is suitable
+// for failing a health check or a deployment with ErrSchemaDrift. TableOptions name the
+// `groups` table and it's columns, as they do for Migrate.
+func (schema Schema) Drift(ctx context.Context, db *sql.DB, opts ...TableOption) (
+	drift []string, err error,
+) {
+	groupTable := NewGroupTable(opts...)
+	if err = groupTable.Validate(); err != nil {
+		return
+	}
+
+	var applied map[int]bool
+	if applied, err = schema.applied(ctx, db, false); err != nil {
+		return
+	}
+
+	known := make(map[int]bool)
+	for _, m := range schema.sorted() {
+		known[m.Version] = true
+		if !applied[m.Version] {
+			drift = append(drift, fmt.Sprintf(
+				"migration %d (%s) is not applied", m.Version, m.Description,
+			))
+		}
+	}
+
+	var unknown []int
+	for v := range applied {
+		if !known[v] {
+			unknown = append(unknown, v)
+		}
+	}
+
+	sort.Ints(unknown)
+	for _, v := range unknown {
+		drift = append(drift, fmt.Sprintf("migration %d is applied but unknown", v))
+	}
+
+	for _, t := range []struct {
+		schema, name string
+		columns      []string
+		indexes      []string
+	}{
+		{
+			groupTable.Schema,
+			groupTable.Name,
+			groupTable.columns(),
+			schema.indexes[table.Name],
+		},
+		{"", teamTable.Name, teamTable.Fields, schema.indexes[teamTable.Name]},
+		{
+			"",
+			invitationTable.Name,
+			invitationTable.Fields,
+			schema.indexes[invitationTable.Name],
+		},
+	} {
+		if t.name == "" {
+			t.name = table.Name
+		}
+
+		var tableDrift []string
+		if tableDrift, err = schema.tableDrift(
+			ctx, db, t.schema, t.name, t.columns, t.indexes,
+		); err != nil {
+			return
+		}
+
+		drift = append(drift, tableDrift...)
+	}
+
+	return
+}
+
+// Check fails with ErrSchemaDrift, describing the drift, if there's any.
+func (schema Schema) Check(ctx context.Context, db *sql.DB, opts ...TableOption) (
+	err error,
+) {
+	var drift []string
+	if drift, err = schema.Drift(ctx, db, opts...); err != nil || len(drift) == 0 {
+		return
+	}
+
+	err = fmt.Errorf("%w: %s", ErrSchemaDrift, strings.Join(drift, "; "))
+	return
+}
+
+// tableDrift lists the missing or unexpected columns, and missing indexes upon a table.
+func (schema Schema) tableDrift(
+	ctx context.Context,
+	db *sql.DB,
+	tableSchema string,
+	name string,
+	columns []string,
+	indexes []string,
+) (drift []string, err error) {
+	qualified := fmt.Sprintf("`%s`", name)
+	if tableSchema != "" {
+		qualified = fmt.Sprintf("`%s`.`%s`", tableSchema, name)
+	}
+
+	var rows *sql.Rows
+	if rows, err = db.QueryContext(ctx, schema.dialect.rebind(
+		fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", qualified),
+	)); err != nil {
+		drift = append(drift, fmt.Sprintf("table %s can't be read: %v", name, err))
+		err = nil
+		return
+	}
+
+	var present []string
+	present, err = rows.Columns()
+	rows.Close()
+	if err != nil {
+		return
+	}
+
+	missing, unexpected := difference(columns, present)
+	for _, column := range missing {
+		drift = append(drift, fmt.Sprintf("table %s is missing column %s", name, column))
+	}
+
+	for _, column := range unexpected {
+		drift = append(drift, fmt.Sprintf("table %s has unexpected column %s", name, column))
+	}
+
+	if present, err = schema.indexesOf(ctx, db, tableSchema, name); err != nil {
+		return
+	}
+
+	// Indexes of your own are harmless, so only missing ones are drift.
+	missing, _ = difference(append([]string{schema.primaryKey(name)}, indexes...), present)
+	for _, index := range missing {
+		drift = append(drift, fmt.Sprintf("table %s is missing index %s", name, index))
+	}
+
+	return
+}
+
+// indexesOf lists the names of the indexes upon a table.
+func (schema Schema) indexesOf(
+	ctx context.Context, db *sql.DB, tableSchema string, name string,
+) (indexes []string, err error) {
+	query, args := schema.indexesQuery(tableSchema, name)
+
+	var rows *sql.Rows
+	if rows, err = db.QueryContext(ctx, schema.dialect.rebind(query), args...); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var index string
+		if err = rows.Scan(&index); err != nil {
+			return
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// applied lists the Versions of the Migrations applied to the database, creating the
+// `auth_migrations` table first if asked to.
+func (schema Schema) applied(ctx context.Context, db *sql.DB, create bool) (
+	applied map[int]bool, err error,
+) {
+	applied = make(map[int]bool)
+	if create {
+		if _, err = db.ExecContext(
+			ctx, schema.dialect.rebind(createMigrationsTable),
+		); err != nil {
+			return
+		}
+	}
+
+	var rows *sql.Rows
+	if rows, err = db.QueryContext(ctx, schema.dialect.rebind(
+		"SELECT `version` FROM `auth_migrations`",
+	)); err != nil {
+		if !create {
+			// A database that was never migrated has no `auth_migrations` table.
+			err = nil
+		}
+
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var v int
+		if err = rows.Scan(&v); err != nil {
+			return
+		}
+
+		applied[v] = true
+	}
+
+	err = rows.Err()
+	return
+}
+
+// sorted lists the Schema's Migrations in order of their Versions.
+func (schema Schema) sorted() (migrations []Migration) {
+	migrations = append(migrations, schema.Migrations...)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return
+}
+
+// difference lists the names that are expected but missing, and those that are present
+// but unexpected.
+func difference(expected []string, present []string) (
+	missing []string, unexpected []string,
+) {
+	isExpected := make(map[string]bool)
+	for _, name := range expected {
+		isExpected[name] = true
+	}
+
+	isPresent := make(map[string]bool)
+	for _, name := range present {
+		isPresent[name] = true
+	}
+
+	for _, name := range expected {
+		if !isPresent[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	for _, name := range present {
+		if !isExpected[name] {
+			unexpected = append(unexpected, name)
+		}
+	}
+
+	return
+}
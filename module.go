@@ -9,10 +9,30 @@ import (
 var Module = fx.Options(
 	fx.Provide(
 		NewGroupMySQLRepositoryImpl,
+		NewTeamMySQLRepositoryImpl,
+		NewInvitationMySQLRepositoryImpl,
 	),
 	fx.Invoke(
 		WithRepository,
 		WithGroupRepository,
+		WithTeamRepository,
+		WithInvitationRepository,
+	),
+)
+
+// PostgresModule is an fx.Options like Module, but persisting our Groups, Teams and
+// Invitations in PostgreSQL.
+var PostgresModule = fx.Options(
+	fx.Provide(
+		NewGroupPostgresRepositoryImpl,
+		NewTeamPostgresRepositoryImpl,
+		NewInvitationPostgresRepositoryImpl,
+	),
+	fx.Invoke(
+		WithRepository,
+		WithGroupRepository,
+		WithTeamRepository,
+		WithInvitationRepository,
 	),
 )
 
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLastMember when removing a Member would empty the Group of a required Role.
+	ErrLastMember = fmt.Errorf("last member of a required role")
+
+	// ErrNotMember when a User doesn't hold the Role that she's to transfer.
+	ErrNotMember = fmt.Errorf("not a member")
+)
+
+// RequiredRoles are the RoleNames of each ResourceKind whose Groups mustn't be emptied,
+// such as the Owners of a Campaign, who'd otherwise be left without anyone to manage
+// it. Once a required Role is granted upon a Resource, deleting or denying it's last
+// Member fails with ErrLastMember, and ownership is handed over with `Transfer` instead.
+// Freeing the Resource at the end of it's lifecycle isn't guarded.
+type RequiredRoles struct {
+	mu    sync.RWMutex
+	names map[ResourceKind]map[RoleName]bool
+}
+
+// NewRequiredRoles is a constructor for RequiredRoles.
+func NewRequiredRoles() (required *RequiredRoles) {
+	required = new(RequiredRoles)
+	required.names = make(map[ResourceKind]map[RoleName]bool)
+	return
+}
+
+// Required are the RequiredRoles that our GroupRepositories guard. None are required
+// unless opted into.
+var Required = NewRequiredRoles()
+
+// Require the given RoleNames upon Resources of the given ResourceKind.
+func (required *RequiredRoles) Require(kind ResourceKind, names ...RoleName) {
+	required.mu.Lock()
+	defer required.mu.Unlock()
+
+	if required.names[kind] == nil {
+		required.names[kind] = make(map[RoleName]bool)
+	}
+
+	for _, name := range names {
+		required.names[kind][name] = true
+	}
+}
+
+// IsRequired checks whether the RoleName is required upon Resources of the given
+// ResourceKind.
+func (required *RequiredRoles) IsRequired(kind ResourceKind, name RoleName) (ok bool) {
+	required.mu.RLock()
+	defer required.mu.RUnlock()
+
+	ok = required.names[kind][name]
+	return
+}
+
+// Guard checks whether removing the given Members from their Roles would empty the Group
+// of a required Role, failing with ErrLastMember if so. The holders of a Role are the
+// Principals with active grants of it, which are listed for required Roles alone.
+func (required *RequiredRoles) Guard(
+	removals []Member, holders func(role Role) ([]Principal, error),
+) (err error) {
+	removed := make(map[MemberKey]bool)
+	var roles Roles
+	for _, m := range removals {
+		if !required.IsRequired(m.Role.Resource.Kind(), m.Role.Name) {
+			continue
+		}
+
+		role := Member{Role: m.Role}.Key()
+		if !removed[role] {
+			roles = append(roles, m.Role)
+		}
+
+		removed[role] = true
+		removed[m.Key()] = true
+	}
+
+	for _, role := range roles {
+		var principals []Principal
+		if principals, err = holders(role); err != nil {
+			return
+		}
+
+		var remaining int
+		for _, principal := range principals {
+			if !removed[Member{Role: role, Principal: principal}.Key()] {
+				remaining++
+			}
+		}
+
+		if len(principals) > 0 && remaining == 0 {
+			err = fmt.Errorf(
+				"%w: %s upon %s:%s",
+				ErrLastMember,
+				role.Name,
+				role.Resource.Kind(),
+				role.Resource.Identifier(),
+			)
+
+			return
+		}
+	}
+
+	return
+}
+
+// TransferRepositoryImpl is implemented by GroupRepositoryImpls that can transfer a Role
+// from one User to another in a single transaction.
+type TransferRepositoryImpl interface {
+	Transfer(ctx context.Context, role Role, from User, to User) (err error)
+}
+
+// Transfer a Role from one User to another within a single transaction, failing with
+// ErrUnsupported unless it's GroupRepositoryImpl is a TransferRepositoryImpl.
+func (repo GroupRepository) Transfer(ctx context.Context, role Role, from User, to User) (
+	err error,
+) {
+	impl, ok := repo.GroupRepositoryImpl.(TransferRepositoryImpl)
+	if !ok {
+		err = fmt.Errorf("%w: Transfer", ErrUnsupported)
+		return
+	}
+
+	err = impl.Transfer(ctx, role, from, to)
+	return
+}
+
+// Transfer a Role from one User to another within a single transaction, granting it to
+// the latter before deleting it from the former, so that a required Role is never left
+// empty. The former must hold an active grant of the Role herself, or Transfer fails
+// with ErrNotMember.
+func (repo *groupSQLRepository) Transfer(
+	ctx context.Context, role Role, from User, to User,
+) (err error) {
+	err = repo.transact(ctx, func(txRepo *groupSQLRepository) (err error) {
+		var principals []Principal
+		if principals, err = txRepo.holders(ctx, role); err != nil {
+			return
+		}
+
+		var held bool
+		for _, principal := range principals {
+			if held = principal == PrincipalOf(from); held {
+				break
+			}
+		}
+
+		if !held {
+			err = fmt.Errorf("%w: %s of %s", ErrNotMember, from.GetID(), role.Name)
+			return
+		}
+
+		if from.GetID() == to.GetID() {
+			return
+		}
+
+		if err = txRepo.assignMany(
+			ctx, []Member{{Role: role, Principal: PrincipalOf(to)}}, EffectAllow, Grant{},
+		); err != nil {
+			return
+		}
+
+		err = txRepo.remove(ctx, []Member{{Role: role, Principal: PrincipalOf(from)}})
+		return
+	})
+
+	return
+}
+
+// remove deletes the assignments of Members to their Roles, guarding required Roles
+// within a transaction.
+func (repo *groupSQLRepository) remove(ctx context.Context, members []Member) (
+	err error,
+) {
+	var guarded bool
+	for _, m := range members {
+		if guarded = Required.IsRequired(m.Role.Resource.Kind(), m.Role.Name); guarded {
+			break
+		}
+	}
+
+	if !guarded {
+		err = repo.unassignMany(ctx, members)
+		return
+	}
+
+	err = repo.transact(ctx, func(txRepo *groupSQLRepository) (err error) {
+		if err = txRepo.guard(ctx, members); err != nil {
+			return
+		}
+
+		err = txRepo.unassignMany(ctx, members)
+		return
+	})
+
+	return
+}
+
+// guard checks that removing the Members wouldn't empty a required Role. It locks the
+// grants of the Roles it checks, so it must be called within a transaction.
+func (repo *groupSQLRepository) guard(ctx context.Context, members []Member) (
+	err error,
+) {
+	err = Required.Guard(members, func(role Role) ([]Principal, error) {
+		return repo.holders(ctx, role)
+	})
+
+	return
+}
+
+// holders lists the Principals with active grants of the Role, locking them until the
+// end of the transaction.
+func (repo *groupSQLRepository) holders(ctx context.Context, role Role) (
+	principals []Principal, err error,
+) {
+	now := time.Now().UTC()
+
+	var rows *sql.Rows
+	if rows, err = repo.query(
+		ctx,
+		"SELECT `groups`.`user_id`, `groups`.`principal_type` FROM `groups` WHERE `groups`.`tenant_id` = ? AND `groups`.`resource_kind` = ? AND `groups`.`resource_id` = ? AND `groups`.`role_name` = ? AND `groups`.`effect` = ? AND "+isActive+repo.dialect.forUpdate(),
+		string(TenantFromContext(ctx)),
+		string(role.Resource.Kind()),
+		string(role.Resource.Identifier()),
+		string(role.Name),
+		string(EffectAllow),
+		now,
+		now,
+	); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var principal Principal
+		if err = newScanner(&principal.ID, &principal.Type).Scan(rows); err != nil {
+			return
+		}
+
+		principals = append(principals, principal)
+	}
+
+	err = rows.Err()
+	return
+}
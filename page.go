@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPageLimit is the number of entries in a page whose Limit isn't set.
+	DefaultPageLimit = 50
+
+	// MaxPageLimit is the most entries that a single page holds.
+	MaxPageLimit = 1000
+)
+
+// Order in which a listing is sorted by the time it's entries were created. Entries
+// created at the same time are sorted by their keys, so that every listing is in a
+// total, stable order.
+type Order int
+
+const (
+	// OldestFirst sorts a listing by ascending creation times.
+	OldestFirst = Order(iota)
+
+	// NewestFirst sorts a listing by descending creation times.
+	NewestFirst
+)
+
+// Page requests one page of a listing, such as that of `FindPage`. Pages are keyed by
+// Cursors rather than offsets, so that paging through a listing lists every entry that
+// exists throughout exactly once, however many entries are added or removed meanwhile.
+// Entries added meanwhile may or may not be listed.
+type Page struct {
+	// Limit on the number of entries in the page, which defaults to DefaultPageLimit and
+	// is at most MaxPageLimit.
+	Limit int
+
+	// Cursor that the page begins after, as returned in `Next` by the previous page of
+	// the same listing. The first page has none.
+	Cursor string
+
+	// RoleNames filters the listing by any of the given names, unless it's empty.
+	RoleNames []RoleName
+
+	// Order of the listing, which must stay the same from one page to the next.
+	Order Order
+}
+
+// Size is the number of entries that the Page holds at most: it's Limit, defaulted and
+// bounded.
+func (page Page) Size() (limit int) {
+	switch limit = page.Limit; {
+	case limit <= 0:
+		limit = DefaultPageLimit
+	case limit > MaxPageLimit:
+		limit = MaxPageLimit
+	}
+
+	return
+}
+
+// HasRoleName checks whether the Page's RoleNames filter includes the given name.
+func (page Page) HasRoleName(name RoleName) (ok bool) {
+	if ok = len(page.RoleNames) == 0; ok {
+		return
+	}
+
+	for _, n := range page.RoleNames {
+		if ok = n == name; ok {
+			return
+		}
+	}
+
+	return
+}
+
+// PageRepositoryImpl is implemented by GroupRepositoryImpls that can list Groups in
+// pages.
+type PageRepositoryImpl interface {
+	FindPage(ctx context.Context, resource Resource, page Page) (
+		members MemberPage, err error,
+	)
+	ResourcesPage(ctx context.Context, kind ResourceKind, user User, page Page) (
+		roles ResourcePage, err error,
+	)
+}
+
+// FindPage lists a page of the Users and Teams that are granted any Role upon the given
+// Resource, failing with ErrUnsupported unless it's GroupRepositoryImpl is a
+// PageRepositoryImpl.
+func (repo GroupRepository) FindPage(ctx context.Context, resource Resource, page Page) (
+	members MemberPage, err error,
+) {
+	impl, ok := repo.GroupRepositoryImpl.(PageRepositoryImpl)
+	if !ok {
+		err = fmt.Errorf("%w: FindPage", ErrUnsupported)
+		return
+	}
+
+	members, err = impl.FindPage(ctx, resource, page)
+	return
+}
+
+// ResourcesPage lists a page of the Roles that a User holds upon Resources of a given
+// ResourceKind, failing with ErrUnsupported unless it's GroupRepositoryImpl is a
+// PageRepositoryImpl.
+func (repo GroupRepository) ResourcesPage(
+	ctx context.Context, kind ResourceKind, user User, page Page,
+) (roles ResourcePage, err error) {
+	impl, ok := repo.GroupRepositoryImpl.(PageRepositoryImpl)
+	if !ok {
+		err = fmt.Errorf("%w: ResourcesPage", ErrUnsupported)
+		return
+	}
+
+	roles, err = impl.ResourcesPage(ctx, kind, user, page)
+	return
+}
+
+// Member is a User or Team that was granted a Role, as listed by `FindPage`.
+type Member struct {
+	Role      Role
+	Principal Principal
+	CreatedAt time.Time
+}
+
+// MemberPage is a page of the Members upon a Resource.
+type MemberPage struct {
+	Members []Member
+
+	// Total number of Members in the listing across all of it's pages.
+	Total int
+
+	// Next is the Cursor of the next page, or empty on the last page.
+	Next string
+}
+
+// ResourcePage is a page of the Roles that a User holds upon Resources of a kind.
+type ResourcePage struct {
+	Roles Roles
+
+	// Total number of Roles in the listing across all of it's pages.
+	Total int
+
+	// Next is the Cursor of the next page, or empty on the last page.
+	Next string
+}
+
+// ErrInvalidCursor when a Page's Cursor wasn't returned by the same listing.
+var ErrInvalidCursor = fmt.Errorf("invalid cursor")
+
+// Cursor is the position of an entry in a listing, which the next page begins after.
+// Implementations of GroupRepositoryImpl encode the keys of their entries in it, and
+// hand it out as an opaque string.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	Key       []string  `json:"k"`
+}
+
+// NewCursor is a constructor for Cursor.
+func NewCursor(createdAt time.Time, key ...string) (cursor Cursor) {
+	cursor.CreatedAt = createdAt.UTC()
+	cursor.Key = key
+	return
+}
+
+// ParseCursor parses a Cursor with a Key of the given length from it's string, failing
+// with ErrInvalidCursor.
+func ParseCursor(s string, keys int) (cursor Cursor, err error) {
+	var b []byte
+	if b, err = base64.RawURLEncoding.DecodeString(s); err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+		return
+	}
+
+	if err = json.Unmarshal(b, &cursor); err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+		return
+	}
+
+	if len(cursor.Key) != keys {
+		err = fmt.Errorf("%w: %d keys, want %d", ErrInvalidCursor, len(cursor.Key), keys)
+	}
+
+	return
+}
+
+func (cursor Cursor) String() string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Compare the positions of two Cursors in OldestFirst order, returning -1, 0 or +1.
+func (cursor Cursor) Compare(other Cursor) (c int) {
+	switch {
+	case cursor.CreatedAt.Before(other.CreatedAt):
+		return -1
+	case cursor.CreatedAt.After(other.CreatedAt):
+		return 1
+	}
+
+	for i := 0; i < len(cursor.Key) && i < len(other.Key); i++ {
+		if c = strings.Compare(cursor.Key[i], other.Key[i]); c != 0 {
+			return
+		}
+	}
+
+	c = len(cursor.Key) - len(other.Key)
+	switch {
+	case c < 0:
+		c = -1
+	case c > 0:
+		c = 1
+	}
+
+	return
+}
+
+// Precedes checks whether the Cursor comes before the other in the given Order.
+func (cursor Cursor) Precedes(other Cursor, order Order) (ok bool) {
+	if order == NewestFirst {
+		ok = cursor.Compare(other) > 0
+		return
+	}
+
+	ok = cursor.Compare(other) < 0
+	return
+}
+
+// keyset is an SQL fragment that matches the entries after a Cursor in the given Order,
+// along with it's parameters. The columns are those of the Cursor's Key, in order.
+func keyset(cursor Cursor, order Order, columns ...string) (
+	fragment string, args []interface{},
+) {
+	values := []interface{}{cursor.CreatedAt}
+	for _, key := range cursor.Key {
+		values = append(values, key)
+	}
+
+	fragment, args = after(order, append([]string{"`created_at`"}, columns...), values)
+	return
+}
+
+// keysetByKey is like keyset, but matches on the Cursor's Key alone, for listings that
+// are sorted by their keys rather than by creation times.
+func keysetByKey(cursor Cursor, order Order, columns ...string) (
+	fragment string, args []interface{},
+) {
+	var values []interface{}
+	for _, key := range cursor.Key {
+		values = append(values, key)
+	}
+
+	fragment, args = after(order, columns, values)
+	return
+}
+
+// after is an SQL fragment that matches the rows whose columns sort after the given
+// values in the given Order.
+func after(order Order, cols []string, values []interface{}) (
+	fragment string, args []interface{},
+) {
+	op := ">"
+	if order == NewestFirst {
+		op = "<"
+	}
+
+	last := len(cols) - 1
+	fragment = fmt.Sprintf("%s %s ?", cols[last], op)
+	args = []interface{}{values[last]}
+	for i := last - 1; i >= 0; i-- {
+		fragment = fmt.Sprintf("(%s %s ? OR (%s = ? AND %s))", cols[i], op, cols[i], fragment)
+		args = append([]interface{}{values[i], values[i]}, args...)
+	}
+
+	return
+}
+
+// orderBy is an SQL fragment that sorts a listing by `created_at` and then the given
+// columns, in the given Order.
+func orderBy(order Order, columns ...string) string {
+	return orderByKey(order, append([]string{"`created_at`"}, columns...)...)
+}
+
+// orderByKey is an SQL fragment that sorts a listing by the given columns alone, in the
+// given Order.
+func orderByKey(order Order, columns ...string) string {
+	dir := " ASC"
+	if order == NewestFirst {
+		dir = " DESC"
+	}
+
+	return "ORDER BY " + strings.Join(columns, dir+", ") + dir
+}
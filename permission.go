@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Action is a string-based key for something a User may do upon a Resource, such as
+// "campaign.send". Actions are mapped onto Roles in code and are never persisted.
+type Action string
+
+// Permission is a single entry of the PermissionRegistry: the RoleNames that grant an
+// Action upon Resources of a ResourceKind.
+type Permission struct {
+	Kind   ResourceKind
+	Action Action
+	Roles  []RoleName
+}
+
+// PermissionRegistry maps each (ResourceKind, Action) pair to the set of RoleNames that
+// grant it. This keeps the labelling of Actions in our business-logic, where it can be
+// changed freely, rather than in our persisted Groups.
+type PermissionRegistry struct {
+	mu    sync.RWMutex
+	perms map[ResourceKind]map[Action][]RoleName
+}
+
+// NewPermissionRegistry is a constructor for PermissionRegistry.
+func NewPermissionRegistry() (registry *PermissionRegistry) {
+	registry = new(PermissionRegistry)
+	registry.perms = make(map[ResourceKind]map[Action][]RoleName)
+	return
+}
+
+// Permissions is the PermissionRegistry consulted by `Can`.
+var Permissions = NewPermissionRegistry()
+
+// Allow declares that the given RoleNames grant the Action upon Resources of the given
+// ResourceKind. Allow is additive and ignores RoleNames that were already declared.
+func (registry *PermissionRegistry) Allow(
+	kind ResourceKind, action Action, names ...RoleName,
+) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.perms[kind]; !ok {
+		registry.perms[kind] = make(map[Action][]RoleName)
+	}
+
+	existing := registry.perms[kind][action]
+	for _, name := range names {
+		var dup bool
+		for _, e := range existing {
+			if dup = e == name; dup {
+				break
+			}
+		}
+
+		if !dup {
+			existing = append(existing, name)
+		}
+	}
+
+	registry.perms[kind][action] = existing
+}
+
+// RolesFor lists the RoleNames that grant the Action upon Resources of the given
+// ResourceKind.
+func (registry *PermissionRegistry) RolesFor(kind ResourceKind, action Action) (
+	names []RoleName,
+) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	names = append(names, registry.perms[kind][action]...)
+	return
+}
+
+// Matrix lists every declared Permission, sorted by ResourceKind and then Action, so it
+// can be reviewed or exported.
+func (registry *PermissionRegistry) Matrix() (matrix []Permission) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	for kind, actions := range registry.perms {
+		for action, names := range actions {
+			matrix = append(matrix, Permission{
+				Kind:   kind,
+				Action: action,
+				Roles:  append([]RoleName{}, names...),
+			})
+		}
+	}
+
+	sort.Slice(matrix, func(i, j int) bool {
+		if matrix[i].Kind != matrix[j].Kind {
+			return matrix[i].Kind < matrix[j].Kind
+		}
+
+		return matrix[i].Action < matrix[j].Action
+	})
+
+	return
+}
+
+// WriteTo dumps the Matrix as a human-readable table, for review.
+func (registry *PermissionRegistry) WriteTo(w io.Writer) (n int64, err error) {
+	var buf strings.Builder
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tACTION\tROLES")
+	for _, perm := range registry.Matrix() {
+		names := make([]string, len(perm.Roles))
+		for i, name := range perm.Roles {
+			names[i] = string(name)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", perm.Kind, perm.Action, strings.Join(names, ", "))
+	}
+
+	if err = tw.Flush(); err != nil {
+		return
+	}
+
+	var written int
+	written, err = io.WriteString(w, buf.String())
+	n = int64(written)
+	return
+}
+
+// Can checks whether the User in the current Context may perform the Action upon the
+// given Resource, per the Permissions registry. The Resource's ancestors are consulted
+// just like `Groups.Check`. Actions that no Role grants are refused, unless the
+// configured PolicyEngine allows them.
+func Can(ctx context.Context, action Action, resource Resource) (ok bool, err error) {
+	var user User
+	if user, err = FromContext(ctx); err != nil {
+		return
+	}
+
+	ok, err = Groups.UserCan(ctx, user, action, resource)
+	return
+}
+
+// UserCan checks whether the given User may perform the Action upon the given Resource,
+// per the Permissions registry.
+func (repo GroupRepository) UserCan(
+	ctx context.Context, user User, action Action, resource Resource,
+) (ok bool, err error) {
+	names := Permissions.RolesFor(resource.Kind(), action)
+
+	ok, err = repo.checkAny(ctx, user, action, names, resource)
+	return
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+)
+
+// PolicyRequest is what a PolicyEngine decides upon: whether a User may perform an
+// Action upon a Resource, or hold any of the given Roles.
+type PolicyRequest struct {
+	User     User
+	Action   Action
+	Resource Resource
+	Roles    Roles
+
+	// Member is the outcome of checking the User's Groups alone.
+	Member bool
+}
+
+// Decision of a PolicyEngine. An empty Effect means the engine has no opinion, and the
+// outcome of checking the User's Groups stands.
+type Decision struct {
+	Effect  Effect
+	Reasons []string
+}
+
+// PolicyEngine is consulted by `Can`, `Groups.Check` and the `Groups.IsInAny`-style
+// checks after the User's Groups have been checked, and may override their outcome.
+type PolicyEngine interface {
+	Decide(ctx context.Context, req PolicyRequest) (decision Decision, err error)
+}
+
+var policyEngine PolicyEngine
+
+// WithPolicyEngine configures the PolicyEngine that `auth` will consult.
+func WithPolicyEngine(engine PolicyEngine) {
+	policyEngine = engine
+}
+
+// consult the configured PolicyEngine, if any, on the outcome of checking a User's
+// Groups. The Master is never subject to policies, nor is the master of a tenant within
+// it.
+func consult(ctx context.Context, req PolicyRequest) (ok bool, err error) {
+	ok, _, err = consultDecision(ctx, req)
+	return
+}
+
+// consultDecision is consult, along with the Decision that the PolicyEngine made.
+func consultDecision(ctx context.Context, req PolicyRequest) (
+	ok bool, decision Decision, err error,
+) {
+	if ok = req.Member; policyEngine == nil || IsMasterIn(ctx, req.User) {
+		return
+	}
+
+	if decision, err = policyEngine.Decide(ctx, req); err != nil {
+		ok = false
+		return
+	}
+
+	switch decision.Effect {
+	case EffectAllow:
+		ok = true
+	case EffectDeny:
+		ok = false
+	}
+
+	return
+}
+
+// rolesRequest is a convenience-constructor for the PolicyRequest of a check upon Roles,
+// whose Resource is that of the first, and conventionally most specific, Role.
+func rolesRequest(user User, roles Roles, member bool) (req PolicyRequest) {
+	req.User = user
+	req.Roles = roles
+	req.Member = member
+	if len(roles) > 0 {
+		req.Resource = roles[0].Resource
+	}
+
+	return
+}
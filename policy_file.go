@@ -0,0 +1,292 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// PolicyRule is a declarative rule of a Policy. A rule applies to a PolicyRequest when
+// every one of it's non-empty matchers does, and it's Effect is then part of the
+// Decision. Denials override allowances.
+type PolicyRule struct {
+	// ID names the rule in the Reasons of a Decision.
+	ID string `json:"id" yaml:"id"`
+
+	// Effect is either "allow" or "deny".
+	Effect Effect `json:"effect" yaml:"effect"`
+
+	// Actions the rule applies to. Role checks are made on behalf of no Action, and are
+	// matched by rules without Actions.
+	Actions []Action `json:"actions,omitempty" yaml:"actions,omitempty"`
+
+	// Kinds of the Resources the rule applies to.
+	Kinds []ResourceKind `json:"kinds,omitempty" yaml:"kinds,omitempty"`
+
+	// Roles that the User must hold upon the Resource, or any of it's ancestors, for the
+	// rule to apply.
+	Roles []RoleName `json:"roles,omitempty" yaml:"roles,omitempty"`
+
+	// Condition over `request.*`, `user.*` and `resource.*` that must hold for the rule
+	// to apply, along with `action` and `member`. See ParseCondition.
+	Condition string `json:"condition,omitempty" yaml:"condition,omitempty"`
+
+	// Reason is reported in the Decision when the rule applies.
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// Policy is a set of PolicyRules, as loaded from a file.
+type Policy struct {
+	Rules []PolicyRule `json:"rules" yaml:"rules"`
+}
+
+// ErrInvalidPolicy when a Policy can't be loaded.
+var ErrInvalidPolicy = fmt.Errorf("invalid policy")
+
+// Validate checks that every rule of the Policy has a valid Effect and Condition.
+func (policy Policy) Validate() (err error) {
+	for i, rule := range policy.Rules {
+		if rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+			err = fmt.Errorf(
+				"%w: rule %d (%q) has effect %q", ErrInvalidPolicy, i, rule.ID, rule.Effect,
+			)
+
+			return
+		}
+
+		if rule.Condition == "" {
+			continue
+		}
+
+		if _, err = parseConditionCached(rule.Condition); err != nil {
+			err = fmt.Errorf("%w: rule %d (%q): %s", ErrInvalidPolicy, i, rule.ID, err)
+			return
+		}
+	}
+
+	return
+}
+
+// Decide upon a PolicyRequest per the Policy's rules.
+func (policy Policy) Decide(ctx context.Context, req PolicyRequest) (
+	decision Decision, err error,
+) {
+	var allows, denials []string
+	for _, rule := range policy.Rules {
+		var ok bool
+		if ok, err = rule.appliesTo(ctx, req); err != nil {
+			return
+		} else if !ok {
+			continue
+		}
+
+		reason := rule.ID
+		if rule.Reason != "" {
+			reason = fmt.Sprintf("%s: %s", rule.ID, rule.Reason)
+		}
+
+		if rule.Effect == EffectDeny {
+			denials = append(denials, reason)
+		} else {
+			allows = append(allows, reason)
+		}
+	}
+
+	switch {
+	case len(denials) > 0:
+		decision.Effect, decision.Reasons = EffectDeny, denials
+	case len(allows) > 0:
+		decision.Effect, decision.Reasons = EffectAllow, allows
+	}
+
+	return
+}
+
+func (rule PolicyRule) appliesTo(ctx context.Context, req PolicyRequest) (
+	ok bool, err error,
+) {
+	if len(rule.Actions) > 0 && !containsAction(rule.Actions, req.Action) {
+		return
+	}
+
+	if len(rule.Kinds) > 0 && (req.Resource == nil ||
+		!containsKind(rule.Kinds, req.Resource.Kind())) {
+		return
+	}
+
+	if len(rule.Roles) > 0 {
+		if req.Resource == nil {
+			return
+		}
+
+		if ok, err = Groups.holdsAny(ctx, req.User, rule.Roles, req.Resource); err != nil ||
+			!ok {
+			return
+		}
+	}
+
+	if rule.Condition != "" {
+		var cond Condition
+		if cond, err = parseConditionCached(rule.Condition); err != nil {
+			return
+		}
+
+		resource := req.Resource
+		if resource == nil {
+			resource = PlatformResource
+		}
+
+		attrs := ConditionAttributes(ctx, req.User, resource)
+		attrs["action"] = string(req.Action)
+		attrs["member"] = req.Member
+		if ok, err = cond.Eval(attrs); err != nil || !ok {
+			return
+		}
+	}
+
+	ok = true
+	return
+}
+
+func containsAction(actions []Action, action Action) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsKind(kinds []ResourceKind, kind ResourceKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadPolicy reads a Policy from a JSON or YAML file, by it's extension. Unknown fields
+// fail with ErrInvalidPolicy in either, as a misspelt matcher would otherwise be ignored
+// and widen it's rule to everything.
+func LoadPolicy(path string) (policy Policy, err error) {
+	var data []byte
+	if data, err = ioutil.ReadFile(path); err != nil {
+		return
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &policy)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&policy)
+	default:
+		err = fmt.Errorf("%w: %q is neither JSON nor YAML", ErrInvalidPolicy, path)
+		return
+	}
+
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidPolicy, err)
+		return
+	}
+
+	err = policy.Validate()
+	return
+}
+
+// FilePolicyEngine implements PolicyEngine with a Policy loaded from a file, which it
+// reloads whenever the file changes while `Watch` runs.
+type FilePolicyEngine struct {
+	path string
+
+	mu      sync.RWMutex
+	policy  Policy
+	modTime time.Time
+}
+
+// NewFilePolicyEngine is a constructor for FilePolicyEngine. It fails if the file can't
+// be loaded.
+func NewFilePolicyEngine(path string) (engine *FilePolicyEngine, err error) {
+	engine = new(FilePolicyEngine)
+	engine.path = path
+	if _, err = engine.Reload(); err != nil {
+		engine = nil
+	}
+
+	return
+}
+
+// Decide implements PolicyEngine with the last successfully loaded Policy.
+func (engine *FilePolicyEngine) Decide(ctx context.Context, req PolicyRequest) (
+	decision Decision, err error,
+) {
+	engine.mu.RLock()
+	policy := engine.policy
+	engine.mu.RUnlock()
+
+	decision, err = policy.Decide(ctx, req)
+	return
+}
+
+// Reload the Policy if the file was modified since it was last loaded. A Policy that
+// fails to load is reported, and the previous one stays in effect.
+func (engine *FilePolicyEngine) Reload() (reloaded bool, err error) {
+	var info os.FileInfo
+	if info, err = os.Stat(engine.path); err != nil {
+		return
+	}
+
+	engine.mu.RLock()
+	unchanged := info.ModTime().Equal(engine.modTime)
+	engine.mu.RUnlock()
+
+	if unchanged {
+		return
+	}
+
+	var policy Policy
+	if policy, err = LoadPolicy(engine.path); err != nil {
+		return
+	}
+
+	engine.mu.Lock()
+	engine.policy, engine.modTime = policy, info.ModTime()
+	engine.mu.Unlock()
+
+	reloaded = true
+	return
+}
+
+// Watch calls `Reload` on every tick of the given interval until the Context is done,
+// reporting any errors to `onError` if it isn't nil. It blocks, and is intended to be
+// run in it's own goroutine.
+func (engine *FilePolicyEngine) Watch(
+	ctx context.Context, interval time.Duration, onError func(err error),
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := engine.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
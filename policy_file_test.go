@@ -0,0 +1,198 @@
+package auth_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/authtest"
+)
+
+// writePolicy writes a Policy file by the given name into a temporary directory.
+func writePolicy(t *testing.T, name string, data string) (path string) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+func TestLoadPolicy(t *testing.T) {
+	want := auth.Policy{Rules: []auth.PolicyRule{{
+		ID:        "office-hours",
+		Effect:    auth.EffectDeny,
+		Kinds:     []auth.ResourceKind{"campaign"},
+		Condition: `hour(request.time) < 9`,
+	}, {
+		ID:      "editors",
+		Effect:  auth.EffectAllow,
+		Actions: []auth.Action{"publish"},
+		Roles:   []auth.RoleName{"editor"},
+	}}}
+
+	yamlPath := writePolicy(t, "policy.yaml", `
+rules:
+  - id: office-hours
+    effect: deny
+    kinds: [campaign]
+    condition: hour(request.time) < 9
+  - id: editors
+    effect: allow
+    actions: [publish]
+    roles: [editor]
+`)
+
+	jsonPath := writePolicy(t, "policy.json", `{"rules": [
+	{"id": "office-hours", "effect": "deny", "kinds": ["campaign"],
+		"condition": "hour(request.time) < 9"},
+	{"id": "editors", "effect": "allow", "actions": ["publish"], "roles": ["editor"]}
+]}`)
+
+	for _, path := range []string{yamlPath, jsonPath} {
+		if got, err := auth.LoadPolicy(path); err != nil {
+			t.Errorf("LoadPolicy(%s): %v", filepath.Base(path), err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("LoadPolicy(%s) = %+v, want %+v", filepath.Base(path), got, want)
+		}
+	}
+}
+
+func TestLoadPolicyErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"effect.yaml":    "rules:\n  - id: x\n    effect: maybe\n",
+		"condition.yaml": "rules:\n  - id: x\n    effect: allow\n    condition: 'a =='\n",
+		"unknown.yaml":   "rules:\n  - id: x\n    effect: allow\n    role: [editor]\n",
+		"effect.json":    `{"rules": [{"id": "x", "effect": "maybe"}]}`,
+		"unknown.json":   `{"rules": [{"id": "x", "effect": "allow", "role": ["editor"]}]}`,
+		"malformed.json": `{"rules": [`,
+		"policy.toml":    `rules = []`,
+	} {
+		_, err := auth.LoadPolicy(writePolicy(t, name, data))
+		if !errors.Is(err, auth.ErrInvalidPolicy) {
+			t.Errorf("LoadPolicy(%s) = %v, want ErrInvalidPolicy", name, err)
+		}
+	}
+}
+
+func TestPolicyDecide(t *testing.T) {
+	campaign := testResource{"campaign", "1"}
+	fixture := authtest.Install()
+	alice, bob := authtest.NewUser("alice"), authtest.NewUser("bob")
+	if err := fixture.Groups.Add(ctx, alice, auth.NewRole("editor", campaign)); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := auth.Policy{Rules: []auth.PolicyRule{{
+		ID:      "editors-publish",
+		Effect:  auth.EffectAllow,
+		Actions: []auth.Action{"publish"},
+		Roles:   []auth.RoleName{"editor"},
+	}, {
+		ID:        "frozen",
+		Effect:    auth.EffectDeny,
+		Kinds:     []auth.ResourceKind{"campaign"},
+		Condition: `request.frozen == true`,
+		Reason:    "campaigns are frozen",
+	}}}
+
+	for _, c := range []struct {
+		name   string
+		frozen bool
+		user   auth.User
+		action auth.Action
+		want   auth.Decision
+	}{
+		{"editor", false, alice, "publish", auth.Decision{
+			Effect: auth.EffectAllow, Reasons: []string{"editors-publish"},
+		}},
+		{"non-editor", false, bob, "publish", auth.Decision{}},
+		{"other action", false, alice, "delete", auth.Decision{}},
+		{"denial overrides", true, alice, "publish", auth.Decision{
+			Effect: auth.EffectDeny, Reasons: []string{"frozen: campaigns are frozen"},
+		}},
+	} {
+		reqCtx := auth.WithAttributes(ctx, auth.Attributes{"frozen": c.frozen})
+		got, err := policy.Decide(reqCtx, auth.PolicyRequest{
+			User: c.user, Action: c.action, Resource: campaign,
+		})
+
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: Decide = %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestFilePolicyEngineReload(t *testing.T) {
+	path := writePolicy(t, "policy.yaml", "rules:\n  - id: all\n    effect: allow\n")
+	engine, err := auth.NewFilePolicyEngine(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decide := func() auth.Effect {
+		t.Helper()
+		decision, err := engine.Decide(ctx, auth.PolicyRequest{
+			User: authtest.NewUser("alice"), Resource: testResource{"campaign", "1"},
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return decision.Effect
+	}
+
+	if got := decide(); got != auth.EffectAllow {
+		t.Fatalf("Decide = %q, want allow", got)
+	}
+
+	if reloaded, err := engine.Reload(); err != nil || reloaded {
+		t.Errorf("Reload = %v, %v of an unchanged file", reloaded, err)
+	}
+
+	// A Policy that fails to load leaves the previous one in effect.
+	later := time.Now().Add(time.Minute)
+	if err = ioutil.WriteFile(path, []byte("rules: [{effect: nope}]"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = engine.Reload(); !errors.Is(err, auth.ErrInvalidPolicy) {
+		t.Errorf("Reload = %v, want ErrInvalidPolicy", err)
+	}
+
+	if got := decide(); got != auth.EffectAllow {
+		t.Errorf("Decide = %q after a failed Reload, want allow", got)
+	}
+
+	later = later.Add(time.Minute)
+	if err = ioutil.WriteFile(
+		path, []byte("rules:\n  - id: none\n    effect: deny\n"), 0600,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded, err := engine.Reload(); err != nil || !reloaded {
+		t.Errorf("Reload = %v, %v of a changed file", reloaded, err)
+	}
+
+	if got := decide(); got != auth.EffectDeny {
+		t.Errorf("Decide = %q after Reload, want deny", got)
+	}
+}
@@ -1,36 +1,62 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 )
 
+// ErrNoTenant is returned by `Query.AsSQL` when tenants are in use but the Query names
+// none, lest it's fragment match the default tenant's Groups instead.
+var ErrNoTenant = fmt.Errorf("query names no tenant while tenants are in use")
+
 // Query returns a fragment that can be used to authenticate resource access.
 type Query struct {
 	UserEmail string
 	Kind      ResourceKind
 	RoleName  RoleName
+
+	// Tenant that the fragment is scoped to. WithContext sets it per `TenantFromContext`.
+	Tenant TenantID
+
+	// Table that Groups are persisted to, when it's named differently from our defaults.
+	Table GroupTable
 }
 
-// NewQuery is a constructor for Query.
+// NewQuery is a constructor for Query. It takes the same TableOptions as the
+// GroupRepository does, so that it's fragment refers the same table.
 func NewQuery(
-	userEmail string, kind ResourceKind, roleName RoleName,
+	userEmail string, kind ResourceKind, roleName RoleName, opts ...TableOption,
 ) (query Query) {
 	query = Query{
 		UserEmail: userEmail,
 		Kind:      kind,
 		RoleName:  roleName,
+		Table:     NewGroupTable(opts...),
 	}
 
 	return
 }
 
-// AsSQL return an SQL fragment that can be used to authenticate resources.
+// WithContext returns a copy of the Query scoped to the tenant of the given Context.
+func (query Query) WithContext(ctx context.Context) (scoped Query) {
+	scoped = query
+	scoped.Tenant = TenantFromContext(ctx)
+	return
+}
+
+// AsSQL return an SQL fragment that can be used to authenticate resources. It fails with
+// ErrNoTenant for the default tenant once `TenantsInUse`.
 func (query Query) AsSQL() (sql string, params []interface{}, err error) {
 	if query.UserEmail == "" {
 		err = fmt.Errorf("invalid user email")
 		return
 	}
 
+	if query.Tenant == "" && TenantsInUse() {
+		err = ErrNoTenant
+		return
+	}
+
 	if query.Kind == "" {
 		err = fmt.Errorf("invalid resource kind")
 		return
@@ -41,11 +67,19 @@ func (query Query) AsSQL() (sql string, params []interface{}, err error) {
 		return
 	}
 
-	sql = "(`groups`.`user_id` = ? AND `groups`.`resource_kind` = ? AND `groups`.`role_name` = ?)"
+	if err = query.Table.Validate(); err != nil {
+		return
+	}
+
+	sql = query.Table.rename(
+		"(`groups`.`user_id` = ? AND `groups`.`resource_kind` = ? AND `groups`.`role_name` = ? AND `groups`.`tenant_id` = ?)",
+	)
+
 	params = []interface{}{
 		query.UserEmail,
 		query.Kind,
 		query.RoleName,
+		query.Tenant,
 	}
 
 	return
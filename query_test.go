@@ -0,0 +1,39 @@
+package auth_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/angadn/auth"
+)
+
+func TestQueryTenant(t *testing.T) {
+	auth.ForgetTenantsInUse(t)
+
+	query := auth.NewQuery("her@acme.com", "doc", "editor")
+	if _, _, err := query.AsSQL(); err != nil {
+		t.Fatalf("expected the default tenant while none is in use, got %v", err)
+	}
+
+	scoped := query.WithContext(auth.WithTenant(ctx, "acme"))
+	if scoped.Tenant != "acme" || query.Tenant != "" {
+		t.Fatalf("expected a copy scoped to the Context's tenant, got %q", scoped.Tenant)
+	}
+
+	_, params, err := scoped.AsSQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tenant := params[len(params)-1]; tenant != auth.TenantID("acme") {
+		t.Errorf("expected the fragment to be scoped to acme, got %v", tenant)
+	}
+
+	if !auth.TenantsInUse() {
+		t.Fatal("expected tenants to be in use once one is named")
+	}
+
+	if _, _, err = query.WithContext(ctx).AsSQL(); !errors.Is(err, auth.ErrNoTenant) {
+		t.Errorf("expected ErrNoTenant, got %v", err)
+	}
+}
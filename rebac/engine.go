@@ -0,0 +1,453 @@
+package rebac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/angadn/auth"
+)
+
+// MaxDepth bounds how deeply Check and Expand follow usersets and rewrites.
+var MaxDepth = 32
+
+// ErrMaxDepth when evaluating a relation recurses deeper than MaxDepth. Cycles among
+// Tuples or Namespaces don't count towards it, as relations that are reached again while
+// they're still being evaluated are cut short.
+var ErrMaxDepth = fmt.Errorf("relation evaluation exceeded maximum depth")
+
+// Engine evaluates relations upon Objects per it's Namespaces, over the Tuples in it's
+// TupleStore.
+type Engine struct {
+	store TupleStore
+
+	mu         sync.RWMutex
+	namespaces map[auth.ResourceKind]Namespace
+}
+
+// NewEngine is a constructor for Engine.
+func NewEngine(store TupleStore, namespaces ...Namespace) (engine *Engine) {
+	engine = new(Engine)
+	engine.store = store
+	engine.namespaces = make(map[auth.ResourceKind]Namespace)
+	for _, ns := range namespaces {
+		engine.namespaces[ns.Kind] = ns
+	}
+
+	return
+}
+
+// WithNamespace adds or replaces the Namespace for it's ResourceKind.
+func (engine *Engine) WithNamespace(ns Namespace) {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+
+	engine.namespaces[ns.Kind] = ns
+}
+
+// Store returns the underlying TupleStore, for writing and deleting Tuples.
+func (engine *Engine) Store() TupleStore {
+	return engine.store
+}
+
+// rewrite looks up the Rewrite for a relation upon Objects of a ResourceKind.
+func (engine *Engine) rewrite(kind auth.ResourceKind, relation auth.RoleName) Rewrite {
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+
+	return engine.namespaces[kind].rewrite(relation)
+}
+
+// Check whether the Subject holds the relation upon the given Resource. Each relation
+// upon an Object is evaluated at most once per Check, and one that's reached again while
+// it's still being evaluated, as with cyclic usersets, doesn't hold along that path.
+func (engine *Engine) Check(
+	ctx context.Context,
+	resource auth.Resource,
+	relation auth.RoleName,
+	subject Subject,
+) (ok bool, err error) {
+	ok, _, err = engine.checker(subject).check(ctx, ObjectOf(resource), relation, 0)
+	return
+}
+
+// checkKey identifies the evaluation of a relation upon an Object within a checker.
+type checkKey struct {
+	object   Object
+	relation auth.RoleName
+}
+
+// noCycle is the `low` of evaluations that reached no relation still being evaluated.
+const noCycle = int(^uint(0) >> 1)
+
+// checker evaluates relations on behalf of a single Subject, memoizing the outcomes.
+type checker struct {
+	engine  *Engine
+	subject Subject
+
+	// visiting maps the relations being evaluated to the depths they're evaluated at.
+	visiting map[checkKey]int
+	memo     map[checkKey]bool
+}
+
+func (engine *Engine) checker(subject Subject) (c *checker) {
+	c = &checker{engine: engine, subject: subject}
+	c.visiting = make(map[checkKey]int)
+	c.memo = make(map[checkKey]bool)
+	return
+}
+
+// check evaluates the relation upon the Object. `low` is the shallowest depth of the
+// relations still being evaluated that it reached, whose outcomes are yet to be known,
+// so it's only memoized when there are none above it.
+func (c *checker) check(
+	ctx context.Context, object Object, relation auth.RoleName, depth int,
+) (ok bool, low int, err error) {
+	low = noCycle
+	if depth > MaxDepth {
+		err = ErrMaxDepth
+		return
+	}
+
+	subject := c.subject
+	if subject.IsUserset() && subject.Object == object && subject.Relation == relation {
+		ok = true
+		return
+	}
+
+	var (
+		k                  = checkKey{object, relation}
+		memoized, visiting bool
+		at                 int
+	)
+
+	if ok, memoized = c.memo[k]; memoized {
+		return
+	}
+
+	if at, visiting = c.visiting[k]; visiting {
+		low = at
+		return
+	}
+
+	c.visiting[k] = depth
+	ok, low, err = c.eval(
+		ctx, object, relation, c.engine.rewrite(object.Namespace, relation), depth,
+	)
+
+	delete(c.visiting, k)
+	if err == nil && low >= depth {
+		c.memo[k] = ok
+		low = noCycle
+	}
+
+	return
+}
+
+func (c *checker) eval(
+	ctx context.Context,
+	object Object,
+	relation auth.RoleName,
+	rw Rewrite,
+	depth int,
+) (ok bool, low int, err error) {
+	low = noCycle
+
+	// follow evaluates another relation, keeping the shallowest `low` of them all.
+	follow := func(ok bool, l int, err error) (bool, error) {
+		if l < low {
+			low = l
+		}
+
+		return ok, err
+	}
+
+	switch rw := rw.(type) {
+	case This:
+		var tuples []Tuple
+		if tuples, err = c.engine.store.Read(ctx, Filter{
+			Namespace: object.Namespace, ID: object.ID, Relation: relation,
+		}); err != nil {
+			return
+		}
+
+		for _, t := range tuples {
+			if ok = t.Subject == c.subject; ok {
+				return
+			}
+
+			if !t.Subject.IsUserset() {
+				continue
+			}
+
+			if ok, err = follow(c.check(
+				ctx, t.Subject.Object, t.Subject.Relation, depth+1,
+			)); err != nil || ok {
+				return
+			}
+		}
+
+	case ComputedUserset:
+		ok, err = follow(c.check(ctx, object, rw.Relation, depth+1))
+
+	case TupleToUserset:
+		var tuples []Tuple
+		if tuples, err = c.engine.store.Read(ctx, Filter{
+			Namespace: object.Namespace, ID: object.ID, Relation: rw.Tupleset,
+		}); err != nil {
+			return
+		}
+
+		for _, t := range tuples {
+			if ok, err = follow(c.check(
+				ctx, t.Subject.Object, rw.Computed, depth+1,
+			)); err != nil || ok {
+				return
+			}
+		}
+
+	case Union:
+		for _, child := range rw {
+			if ok, err = follow(c.eval(
+				ctx, object, relation, child, depth+1,
+			)); err != nil || ok {
+				return
+			}
+		}
+
+	case Intersection:
+		for _, child := range rw {
+			if ok, err = follow(c.eval(
+				ctx, object, relation, child, depth+1,
+			)); err != nil || !ok {
+				return
+			}
+		}
+
+		ok = len(rw) > 0
+
+	case Exclusion:
+		if ok, err = follow(c.eval(
+			ctx, object, relation, rw.Base, depth+1,
+		)); err != nil || !ok {
+			return
+		}
+
+		var excluded bool
+		if excluded, err = follow(c.eval(
+			ctx, object, relation, rw.Subtract, depth+1,
+		)); err != nil {
+			return
+		}
+
+		ok = !excluded
+
+	default:
+		err = fmt.Errorf("unknown rewrite %T", rw)
+	}
+
+	return
+}
+
+// NodeKind describes how a Node's Children combine.
+type NodeKind string
+
+const (
+	// LeafNode lists Subjects directly.
+	LeafNode = NodeKind("leaf")
+
+	// UnionNode is satisfied by any of it's Children.
+	UnionNode = NodeKind("union")
+
+	// IntersectionNode is satisfied by all of it's Children.
+	IntersectionNode = NodeKind("intersection")
+
+	// ExclusionNode is satisfied by it's first Child, but not it's second.
+	ExclusionNode = NodeKind("exclusion")
+)
+
+// Node is a tree of the Subjects holding a relation upon an Object, as returned by
+// Expand.
+type Node struct {
+	Kind     NodeKind
+	Object   Object
+	Relation auth.RoleName
+	Subjects []Subject
+	Children []*Node
+}
+
+// Expand the relation upon the given Resource into a tree of every Subject holding it,
+// following usersets and rewrites. A relation that's reached again while it's still being
+// expanded, as with cyclic usersets, is left as an empty LeafNode.
+func (engine *Engine) Expand(
+	ctx context.Context, resource auth.Resource, relation auth.RoleName,
+) (node *Node, err error) {
+	node, err = engine.expand(
+		ctx, ObjectOf(resource), relation, 0, make(map[checkKey]bool),
+	)
+
+	return
+}
+
+// expand the relation upon the Object, where `path` holds the relations being expanded.
+func (engine *Engine) expand(
+	ctx context.Context,
+	object Object,
+	relation auth.RoleName,
+	depth int,
+	path map[checkKey]bool,
+) (node *Node, err error) {
+	if depth > MaxDepth {
+		err = ErrMaxDepth
+		return
+	}
+
+	k := checkKey{object, relation}
+	if path[k] {
+		node = &Node{Kind: LeafNode, Object: object, Relation: relation}
+		return
+	}
+
+	path[k] = true
+	defer delete(path, k)
+
+	node, err = engine.expandRewrite(
+		ctx, object, relation, engine.rewrite(object.Namespace, relation), depth, path,
+	)
+
+	return
+}
+
+func (engine *Engine) expandRewrite(
+	ctx context.Context,
+	object Object,
+	relation auth.RoleName,
+	rw Rewrite,
+	depth int,
+	path map[checkKey]bool,
+) (node *Node, err error) {
+	node = &Node{Object: object, Relation: relation}
+
+	var children []Rewrite
+	switch rw := rw.(type) {
+	case This:
+		node.Kind = LeafNode
+
+		var tuples []Tuple
+		if tuples, err = engine.store.Read(ctx, Filter{
+			Namespace: object.Namespace, ID: object.ID, Relation: relation,
+		}); err != nil {
+			return
+		}
+
+		sort.Slice(tuples, func(i, j int) bool {
+			return tuples[i].String() < tuples[j].String()
+		})
+
+		for _, t := range tuples {
+			node.Subjects = append(node.Subjects, t.Subject)
+			if !t.Subject.IsUserset() {
+				continue
+			}
+
+			var child *Node
+			if child, err = engine.expand(
+				ctx, t.Subject.Object, t.Subject.Relation, depth+1, path,
+			); err != nil {
+				return
+			}
+
+			node.Children = append(node.Children, child)
+		}
+
+		return
+
+	case ComputedUserset:
+		node, err = engine.expand(ctx, object, rw.Relation, depth+1, path)
+		return
+
+	case TupleToUserset:
+		node.Kind = UnionNode
+
+		var tuples []Tuple
+		if tuples, err = engine.store.Read(ctx, Filter{
+			Namespace: object.Namespace, ID: object.ID, Relation: rw.Tupleset,
+		}); err != nil {
+			return
+		}
+
+		for _, t := range tuples {
+			var child *Node
+			if child, err = engine.expand(
+				ctx, t.Subject.Object, rw.Computed, depth+1, path,
+			); err != nil {
+				return
+			}
+
+			node.Children = append(node.Children, child)
+		}
+
+		return
+
+	case Union:
+		node.Kind, children = UnionNode, rw
+
+	case Intersection:
+		node.Kind, children = IntersectionNode, rw
+
+	case Exclusion:
+		node.Kind, children = ExclusionNode, []Rewrite{rw.Base, rw.Subtract}
+
+	default:
+		err = fmt.Errorf("unknown rewrite %T", rw)
+		return
+	}
+
+	for _, c := range children {
+		var child *Node
+		if child, err = engine.expandRewrite(
+			ctx, object, relation, c, depth+1, path,
+		); err != nil {
+			return
+		}
+
+		node.Children = append(node.Children, child)
+	}
+
+	return
+}
+
+// ListObjects lists the IDs of Objects of the given ResourceKind upon which the Subject
+// holds the relation. Only Objects with at least one Tuple of their own are considered.
+func (engine *Engine) ListObjects(
+	ctx context.Context,
+	kind auth.ResourceKind,
+	relation auth.RoleName,
+	subject Subject,
+) (ids []auth.ResourceID, err error) {
+	var tuples []Tuple
+	if tuples, err = engine.store.Read(ctx, Filter{Namespace: kind}); err != nil {
+		return
+	}
+
+	c := engine.checker(subject)
+	seen := make(map[auth.ResourceID]bool)
+	for _, t := range tuples {
+		if seen[t.Object.ID] {
+			continue
+		}
+
+		seen[t.Object.ID] = true
+
+		var ok bool
+		if ok, _, err = c.check(ctx, t.Object, relation, 0); err != nil {
+			return
+		} else if ok {
+			ids = append(ids, t.Object.ID)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return
+}
@@ -0,0 +1,273 @@
+package rebac_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/rebac"
+)
+
+var ctx = context.Background()
+
+// newEngine returns an Engine over an in-memory TupleStore of the given Tuples, with
+// Campaigns whose editors are viewers too, as are the viewers of their parent Accounts,
+// unless they're blocked.
+func newEngine(t *testing.T, tuples ...string) (engine *rebac.Engine) {
+	t.Helper()
+	engine = newEngineOver(t, rebac.NewMemoryTupleStore(), tuples...)
+	return
+}
+
+// newEngineOver returns an Engine like newEngine's, over the given TupleStore.
+func newEngineOver(
+	t *testing.T, store rebac.TupleStore, tuples ...string,
+) (engine *rebac.Engine) {
+	t.Helper()
+	engine = rebac.NewEngine(store, rebac.Namespace{
+		Kind: "campaign",
+		Relations: map[auth.RoleName]rebac.Rewrite{
+			"editor": rebac.Union{
+				rebac.This{},
+				rebac.ComputedUserset{Relation: "owner"},
+			},
+			"viewer": rebac.Exclusion{
+				Base: rebac.Union{
+					rebac.This{},
+					rebac.ComputedUserset{Relation: "editor"},
+					rebac.TupleToUserset{Tupleset: "parent", Computed: "viewer"},
+				},
+				Subtract: rebac.ComputedUserset{Relation: "blocked"},
+			},
+			"publisher": rebac.Intersection{
+				rebac.ComputedUserset{Relation: "editor"},
+				rebac.ComputedUserset{Relation: "approved"},
+			},
+		},
+	})
+
+	write(t, engine, tuples...)
+	return
+}
+
+func write(t *testing.T, engine *rebac.Engine, tuples ...string) {
+	t.Helper()
+	for _, s := range tuples {
+		tuple, err := rebac.ParseTuple(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = engine.Store().Write(ctx, tuple); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func check(t *testing.T, engine *rebac.Engine, object, relation, subject string) bool {
+	t.Helper()
+	tuple, err := rebac.ParseTuple(object + "#" + relation + "@" + subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := engine.Check(ctx, tuple.Object, tuple.Relation, tuple.Subject)
+	if err != nil {
+		t.Fatalf("Check(%s): %v", tuple, err)
+	}
+
+	return ok
+}
+
+func TestCheck(t *testing.T) {
+	engine := newEngine(t,
+		"campaign:1#owner@user:alice",
+		"campaign:1#editor@user:bob",
+		"campaign:1#parent@account:7",
+		"account:7#viewer@team:ops#member",
+		"team:ops#member@user:carol",
+		"campaign:1#blocked@user:dave",
+		"team:ops#member@user:dave",
+		"campaign:1#approved@user:bob",
+	)
+
+	for _, c := range []struct {
+		relation, subject string
+		want              bool
+	}{
+		{"owner", "user:alice", true},
+		{"owner", "user:bob", false},
+		{"editor", "user:alice", true},
+		{"editor", "user:bob", true},
+		{"editor", "user:carol", false},
+		{"viewer", "user:alice", true},
+		{"viewer", "user:carol", true},
+		{"viewer", "user:dave", false},
+		{"viewer", "user:erin", false},
+		{"viewer", "team:ops#member", true},
+		{"publisher", "user:bob", true},
+		{"publisher", "user:alice", false},
+	} {
+		if got := check(t, engine, "campaign:1", c.relation, c.subject); got != c.want {
+			t.Errorf("Check(%s, %s) = %v, want %v", c.relation, c.subject, got, c.want)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	engine := newEngine(t,
+		"campaign:1#owner@user:alice",
+		"campaign:1#editor@user:bob",
+		"campaign:1#editor@team:ops#member",
+		"team:ops#member@user:carol",
+	)
+
+	node, err := engine.Expand(ctx, rebac.Object{Namespace: "campaign", ID: "1"}, "editor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node.Kind != rebac.UnionNode || len(node.Children) != 2 {
+		t.Fatalf("Expand = %+v, want a union of two", node)
+	}
+
+	this, owner := node.Children[0], node.Children[1]
+	var subjects []string
+	for _, s := range this.Subjects {
+		subjects = append(subjects, s.String())
+	}
+
+	if want := []string{"team:ops#member", "user:bob"}; !reflect.DeepEqual(subjects, want) {
+		t.Errorf("editors = %v, want %v", subjects, want)
+	}
+
+	if len(this.Children) != 1 || len(this.Children[0].Subjects) != 1 ||
+		this.Children[0].Subjects[0].String() != "user:carol" {
+		t.Errorf("team:ops#member = %+v, want user:carol", this.Children)
+	}
+
+	if owner.Kind != rebac.LeafNode || len(owner.Subjects) != 1 ||
+		owner.Subjects[0].String() != "user:alice" {
+		t.Errorf("owners = %+v, want user:alice", owner)
+	}
+}
+
+func TestListObjects(t *testing.T) {
+	engine := newEngine(t,
+		"campaign:1#owner@user:alice",
+		"campaign:2#viewer@user:alice",
+		"campaign:3#viewer@user:bob",
+	)
+
+	ids, err := engine.ListObjects(ctx, "campaign", "viewer", rebac.Subject{
+		Object: rebac.Object{Namespace: rebac.UserKind, ID: "alice"},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []auth.ResourceID{"1", "2"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ListObjects = %v, want %v", ids, want)
+	}
+}
+
+func TestCheckCycles(t *testing.T) {
+	engine := newEngine(t,
+		"group:a#member@group:b#member",
+		"group:b#member@group:a#member",
+		"group:b#member@user:alice",
+	)
+
+	for _, c := range []struct {
+		object, subject string
+		want            bool
+	}{
+		{"group:a", "user:alice", true},
+		{"group:b", "user:alice", true},
+		{"group:a", "user:bob", false},
+		{"group:b", "user:bob", false},
+	} {
+		if got := check(t, engine, c.object, "member", c.subject); got != c.want {
+			t.Errorf("Check(%s, %s) = %v, want %v", c.object, c.subject, got, c.want)
+		}
+	}
+
+	// Cyclic rewrites are cut short just the same.
+	engine.WithNamespace(rebac.Namespace{
+		Kind: "doc",
+		Relations: map[auth.RoleName]rebac.Rewrite{
+			"a": rebac.Union{rebac.This{}, rebac.ComputedUserset{Relation: "b"}},
+			"b": rebac.Union{rebac.This{}, rebac.ComputedUserset{Relation: "a"}},
+		},
+	})
+
+	write(t, engine, "doc:1#b@user:alice")
+	if !check(t, engine, "doc:1", "a", "user:alice") {
+		t.Errorf("Check(doc:1#a@user:alice) = false")
+	}
+
+	if check(t, engine, "doc:1", "a", "user:bob") {
+		t.Errorf("Check(doc:1#a@user:bob) = true")
+	}
+
+	node, err := engine.Expand(ctx, rebac.Object{Namespace: "group", ID: "a"}, "member")
+	if err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+
+	if len(node.Children) != 1 || len(node.Children[0].Children) != 1 {
+		t.Errorf("Expand = %+v, want group:a within group:b", node)
+	}
+}
+
+// countingStore counts the reads of a TupleStore.
+type countingStore struct {
+	rebac.TupleStore
+	reads int
+}
+
+func (store *countingStore) Read(ctx context.Context, filter rebac.Filter) (
+	[]rebac.Tuple, error,
+) {
+	store.reads++
+	return store.TupleStore.Read(ctx, filter)
+}
+
+func TestCheckMemoizes(t *testing.T) {
+	// Every level holds both groups of the next, so that there are 2^n paths to the last.
+	const levels = 16
+
+	store := &countingStore{TupleStore: rebac.NewMemoryTupleStore()}
+	engine := rebac.NewEngine(store)
+	for i := 0; i < levels; i++ {
+		for _, parent := range []string{"a", "b"} {
+			for _, child := range []string{"a", "b"} {
+				tuple, err := rebac.ParseTuple(fmt.Sprintf(
+					"group:%s%d#member@group:%s%d#member", parent, i, child, i+1,
+				))
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err = store.Write(ctx, tuple); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+
+	ok, err := engine.Check(ctx, rebac.Object{Namespace: "group", ID: "a0"}, "member",
+		rebac.Subject{Object: rebac.Object{Namespace: rebac.UserKind, ID: "alice"}})
+
+	if err != nil || ok {
+		t.Fatalf("Check = %v, %v, want false", ok, err)
+	}
+
+	if store.reads > 2*(levels+1) {
+		t.Errorf("read %d times, want each group read once", store.reads)
+	}
+}
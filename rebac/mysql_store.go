@@ -0,0 +1,153 @@
+package rebac
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/angadn/tabular"
+)
+
+// table is a tabular representation of Tuples, and helps us persist them in an SQL
+// database. Subjects that aren't usersets are stored with an empty `subject_relation`.
+var table = tabular.New(
+	"relation_tuples",
+
+	"object_kind",
+	"object_id",
+	"relation",
+	"subject_kind",
+	"subject_id",
+	"subject_relation",
+	"created_at",
+)
+
+// MySQLTupleStore implements TupleStore in MySQL, in the `relation_tuples` table that
+// auth.Migrate creates. It's primary key spans every column of a Tuple, which is what
+// keeps Write idempotent.
+type MySQLTupleStore struct {
+	db *sql.DB
+}
+
+// NewMySQLTupleStore is a constructor for MySQLTupleStore.
+func NewMySQLTupleStore(db *sql.DB) (store TupleStore, err error) {
+	mysqlStore := new(MySQLTupleStore)
+	mysqlStore.db = db
+	store = mysqlStore
+	err = mysqlStore.db.Ping()
+	return
+}
+
+// Write persists the given Tuples with a single statement.
+func (store *MySQLTupleStore) Write(ctx context.Context, tuples ...Tuple) (
+	err error,
+) {
+	if len(tuples) == 0 {
+		return
+	}
+
+	var args []interface{}
+	for _, t := range tuples {
+		args = append(args, t.args()...)
+	}
+
+	_, err = store.db.ExecContext(ctx, table.BatchInsertion(
+		"%s ON DUPLICATE KEY UPDATE `created_at` = `created_at`",
+		len(tuples),
+		"created_at", "NOW()",
+	), args...)
+
+	return
+}
+
+// Delete removes the given Tuples with a single statement.
+func (store *MySQLTupleStore) Delete(ctx context.Context, tuples ...Tuple) (
+	err error,
+) {
+	if len(tuples) == 0 {
+		return
+	}
+
+	var args []interface{}
+	for _, t := range tuples {
+		args = append(args, t.args()...)
+	}
+
+	_, err = store.db.ExecContext(ctx, fmt.Sprintf(
+		"DELETE FROM `relation_tuples` WHERE %s",
+		strings.TrimRight(strings.Repeat(
+			"(`object_kind` = ? AND `object_id` = ? AND `relation` = ? AND `subject_kind` = ? AND `subject_id` = ? AND `subject_relation` = ?) OR", len(tuples),
+		), " OR"),
+	), args...)
+
+	return
+}
+
+// Read lists the Tuples selected by the Filter.
+func (store *MySQLTupleStore) Read(ctx context.Context, filter Filter) (
+	tuples []Tuple, err error,
+) {
+	var (
+		conds = []string{"1 = 1"}
+		args  []interface{}
+	)
+
+	if filter.Namespace != "" {
+		conds = append(conds, "`relation_tuples`.`object_kind` = ?")
+		args = append(args, string(filter.Namespace))
+	}
+
+	if filter.ID != "" {
+		conds = append(conds, "`relation_tuples`.`object_id` = ?")
+		args = append(args, string(filter.ID))
+	}
+
+	if filter.Relation != "" {
+		conds = append(conds, "`relation_tuples`.`relation` = ?")
+		args = append(args, string(filter.Relation))
+	}
+
+	var rows *sql.Rows
+	if rows, err = store.db.QueryContext(ctx, table.Selection(
+		"SELECT %s FROM `relation_tuples` WHERE "+strings.Join(conds, " AND "),
+	), args...); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	// None of our columns are NULL, so we scan them directly rather than through a
+	// tabular.Scanner, which database/sql no longer allows to scan a row twice.
+	for rows.Next() {
+		var t Tuple
+		if err = rows.Scan(
+			&t.Object.Namespace,
+			&t.Object.ID,
+			&t.Relation,
+			&t.Subject.Object.Namespace,
+			&t.Subject.Object.ID,
+			&t.Subject.Relation,
+			&tabular.Scapegoat{},
+		); err != nil {
+			return
+		}
+
+		tuples = append(tuples, t)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// args lists the Tuple's columns as SQL parameters, in the order of our table.
+func (tuple Tuple) args() []interface{} {
+	return []interface{}{
+		string(tuple.Object.Namespace),
+		string(tuple.Object.ID),
+		string(tuple.Relation),
+		string(tuple.Subject.Object.Namespace),
+		string(tuple.Subject.Object.ID),
+		string(tuple.Subject.Relation),
+	}
+}
@@ -0,0 +1,114 @@
+package rebac_test
+
+import (
+	"database/sql"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/rebac"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// openMySQL opens the MySQL database of AUTH_TEST_MYSQL_DSN, migrated and without any
+// Tuples, and skips the test unless it's set.
+func openMySQL(t *testing.T) (db *sql.DB) {
+	t.Helper()
+
+	dsn := os.Getenv("AUTH_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("AUTH_TEST_MYSQL_DSN is not set")
+	}
+
+	var err error
+	if db, err = sql.Open("mysql", dsn); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	if err = auth.Migrate(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = db.ExecContext(ctx, "DELETE FROM `relation_tuples`"); err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+func TestMySQLTupleStore(t *testing.T) {
+	store, err := rebac.NewMySQLTupleStore(openMySQL(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tuples := []string{
+		"campaign:1#editor@user:bob",
+		"campaign:1#editor@team:ops#member",
+		"team:ops#member@user:carol",
+	}
+
+	// Writing the same Tuples again, and twice within a Write, keeps a single row each.
+	engine := newEngineOver(t, store, tuples...)
+	write(t, engine, tuples...)
+	write(t, engine, tuples[0], tuples[0])
+
+	read := func(filter rebac.Filter) (got []string) {
+		t.Helper()
+		found, err := store.Read(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tuple := range found {
+			got = append(got, tuple.String())
+		}
+
+		sort.Strings(got)
+		return
+	}
+
+	want := []string{"campaign:1#editor@team:ops#member", "campaign:1#editor@user:bob"}
+	if got := read(rebac.Filter{
+		Namespace: "campaign", ID: "1", Relation: "editor",
+	}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Read(campaign:1#editor) = %v, want %v", got, want)
+	}
+
+	node, err := engine.Expand(ctx, rebac.Object{Namespace: "campaign", ID: "1"}, "editor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(node.Children) == 0 {
+		t.Fatalf("Expand(campaign:1#editor) = %+v, want a union", node)
+	}
+
+	if subjects := node.Children[0].Subjects; len(subjects) != 2 {
+		t.Errorf("Expand(campaign:1#editor) = %v, want each editor once", subjects)
+	}
+
+	if !check(t, engine, "campaign:1", "viewer", "user:carol") {
+		t.Error("carol is a viewer by way of team:ops")
+	}
+
+	bob, _ := rebac.ParseTuple(tuples[0])
+	if err = store.Delete(ctx, bob, bob); err != nil {
+		t.Fatal(err)
+	}
+
+	want = []string{"campaign:1#editor@team:ops#member"}
+	if got := read(rebac.Filter{Namespace: "campaign"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Read(campaign) = %v, want %v", got, want)
+	}
+
+	if check(t, engine, "campaign:1", "editor", "user:bob") {
+		t.Error("bob's Tuple was deleted")
+	}
+}
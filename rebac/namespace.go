@@ -0,0 +1,65 @@
+package rebac
+
+import (
+	"github.com/angadn/auth"
+)
+
+// Rewrite defines how a relation is computed, in terms of the Tuples stored for it and
+// of other relations. It is one of This, ComputedUserset, TupleToUserset, Union,
+// Intersection or Exclusion.
+type Rewrite interface {
+	isRewrite()
+}
+
+// This is satisfied by Subjects of the Tuples stored for the relation itself.
+type This struct{}
+
+// ComputedUserset is satisfied by Subjects holding another relation upon the same
+// Object, such as an "editor" being computed from "owner".
+type ComputedUserset struct {
+	Relation auth.RoleName
+}
+
+// TupleToUserset follows the Tuples stored for the Tupleset relation upon an Object,
+// and is satisfied by Subjects holding the Computed relation upon the Objects they point
+// to. For example, a Campaign's "viewer" can be computed from the "viewer" of the
+// Account that it's "parent" Tuple points to.
+type TupleToUserset struct {
+	Tupleset auth.RoleName
+	Computed auth.RoleName
+}
+
+// Union is satisfied when any of it's children are.
+type Union []Rewrite
+
+// Intersection is satisfied when all of it's children are.
+type Intersection []Rewrite
+
+// Exclusion is satisfied when Base is, but Subtract isn't.
+type Exclusion struct {
+	Base     Rewrite
+	Subtract Rewrite
+}
+
+func (This) isRewrite()            {}
+func (ComputedUserset) isRewrite() {}
+func (TupleToUserset) isRewrite()  {}
+func (Union) isRewrite()           {}
+func (Intersection) isRewrite()    {}
+func (Exclusion) isRewrite()       {}
+
+// Namespace configures the relations upon Objects of a ResourceKind. A relation missing
+// from Relations, or mapped to nil, is satisfied by it's stored Tuples alone.
+type Namespace struct {
+	Kind      auth.ResourceKind
+	Relations map[auth.RoleName]Rewrite
+}
+
+// rewrite looks up the Rewrite for a relation, defaulting to This.
+func (ns Namespace) rewrite(relation auth.RoleName) (rw Rewrite) {
+	if rw = ns.Relations[relation]; rw == nil {
+		rw = This{}
+	}
+
+	return
+}
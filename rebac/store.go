@@ -0,0 +1,98 @@
+package rebac
+
+import (
+	"context"
+	"sync"
+
+	"github.com/angadn/auth"
+)
+
+// Filter selects Tuples by their Object and relation. Empty fields match anything.
+type Filter struct {
+	Namespace auth.ResourceKind
+	ID        auth.ResourceID
+	Relation  auth.RoleName
+}
+
+// matches checks whether the Tuple is selected by the Filter.
+func (filter Filter) matches(tuple Tuple) (ok bool) {
+	if filter.Namespace != "" && filter.Namespace != tuple.Object.Namespace {
+		return
+	}
+
+	if filter.ID != "" && filter.ID != tuple.Object.ID {
+		return
+	}
+
+	if filter.Relation != "" && filter.Relation != tuple.Relation {
+		return
+	}
+
+	ok = true
+	return
+}
+
+// TupleStore defines an interface with which we can persist our Tuples. Write and
+// Delete are idempotent.
+type TupleStore interface {
+	Write(ctx context.Context, tuples ...Tuple) (err error)
+	Delete(ctx context.Context, tuples ...Tuple) (err error)
+	Read(ctx context.Context, filter Filter) (tuples []Tuple, err error)
+}
+
+// MemoryTupleStore implements TupleStore in memory, for embedded use.
+type MemoryTupleStore struct {
+	mu     sync.RWMutex
+	tuples map[Tuple]bool
+}
+
+// NewMemoryTupleStore is a constructor for MemoryTupleStore.
+func NewMemoryTupleStore() (store *MemoryTupleStore) {
+	store = new(MemoryTupleStore)
+	store.tuples = make(map[Tuple]bool)
+	return
+}
+
+// Write persists the given Tuples.
+func (store *MemoryTupleStore) Write(ctx context.Context, tuples ...Tuple) (
+	err error,
+) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, tuple := range tuples {
+		store.tuples[tuple] = true
+	}
+
+	return
+}
+
+// Delete removes the given Tuples.
+func (store *MemoryTupleStore) Delete(ctx context.Context, tuples ...Tuple) (
+	err error,
+) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, tuple := range tuples {
+		delete(store.tuples, tuple)
+	}
+
+	return
+}
+
+// Read lists the Tuples selected by the Filter.
+func (store *MemoryTupleStore) Read(ctx context.Context, filter Filter) (
+	tuples []Tuple, err error,
+) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for tuple := range store.tuples {
+		if filter.matches(tuple) {
+			tuples = append(tuples, tuple)
+		}
+	}
+
+	return
+}
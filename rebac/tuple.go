@@ -0,0 +1,167 @@
+// Package rebac provides a relationship-based access control engine in the style of
+// Google's Zanzibar, built on the same Resource and RoleName vocabulary as `auth`.
+// Relations between Objects and Subjects are persisted as Tuples, such as
+// "campaign:42#editor@user:alice" or "campaign:42#viewer@account:7#member", and a
+// Namespace configuration rewrites relations in terms of one another at check time.
+package rebac
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/angadn/auth"
+)
+
+// UserKind is the ResourceKind of the Objects that represent Users.
+const UserKind = auth.ResourceKind("user")
+
+// Object identifies anything that relations can be defined upon. It implements
+// auth.Resource.
+type Object struct {
+	Namespace auth.ResourceKind
+	ID        auth.ResourceID
+}
+
+// ObjectOf is a convenience-constructor for the Object of an auth.Resource.
+func ObjectOf(resource auth.Resource) (object Object) {
+	object.Namespace = resource.Kind()
+	object.ID = resource.Identifier()
+	return
+}
+
+// Identifier implements auth.Resource.
+func (object Object) Identifier() (id auth.ResourceID) {
+	return object.ID
+}
+
+// Kind implements auth.Resource.
+func (object Object) Kind() (kind auth.ResourceKind) {
+	return object.Namespace
+}
+
+var _ auth.Resource = Object{}
+
+// String formats the Object as "kind:id".
+func (object Object) String() string {
+	return fmt.Sprintf("%s:%s", object.Namespace, object.ID)
+}
+
+// Subject of a Tuple: either an Object itself, such as "user:alice", or a userset of all
+// Subjects holding a relation upon an Object, such as "account:7#member".
+type Subject struct {
+	Object   Object
+	Relation auth.RoleName
+}
+
+// UserSubject is a convenience-constructor for the Subject of an auth.User.
+func UserSubject(user auth.User) (subject Subject) {
+	subject.Object = Object{Namespace: UserKind, ID: auth.ResourceID(user.GetID())}
+	return
+}
+
+// Userset is a convenience-constructor for the Subject of all Subjects holding the
+// relation upon the given Resource.
+func Userset(resource auth.Resource, relation auth.RoleName) (subject Subject) {
+	subject.Object = ObjectOf(resource)
+	subject.Relation = relation
+	return
+}
+
+// IsUserset checks whether the Subject is a userset rather than an Object itself.
+func (subject Subject) IsUserset() (ok bool) {
+	ok = subject.Relation != ""
+	return
+}
+
+// String formats the Subject as "kind:id" or "kind:id#relation".
+func (subject Subject) String() string {
+	if subject.IsUserset() {
+		return fmt.Sprintf("%s#%s", subject.Object, subject.Relation)
+	}
+
+	return subject.Object.String()
+}
+
+// Tuple relates a Subject to an Object: "kind:id#relation@subject".
+type Tuple struct {
+	Object   Object
+	Relation auth.RoleName
+	Subject  Subject
+}
+
+// NewTuple is a convenience-constructor for Tuple.
+func NewTuple(resource auth.Resource, relation auth.RoleName, subject Subject) (
+	tuple Tuple,
+) {
+	tuple.Object = ObjectOf(resource)
+	tuple.Relation = relation
+	tuple.Subject = subject
+	return
+}
+
+// String formats the Tuple as "kind:id#relation@subject".
+func (tuple Tuple) String() string {
+	return fmt.Sprintf("%s#%s@%s", tuple.Object, tuple.Relation, tuple.Subject)
+}
+
+// ErrInvalidTuple when a Tuple can't be parsed.
+var ErrInvalidTuple = fmt.Errorf("invalid relation tuple")
+
+// ParseTuple parses a Tuple formatted as "kind:id#relation@subject".
+func ParseTuple(s string) (tuple Tuple, err error) {
+	var (
+		object, subject string
+		ok              bool
+	)
+
+	if object, subject, ok = cut(s, "@"); !ok {
+		err = fmt.Errorf("%w: %q lacks a subject", ErrInvalidTuple, s)
+		return
+	}
+
+	var relation string
+	if object, relation, ok = cut(object, "#"); !ok || relation == "" {
+		err = fmt.Errorf("%w: %q lacks a relation", ErrInvalidTuple, s)
+		return
+	}
+
+	if tuple.Object, err = parseObject(object); err != nil {
+		return
+	}
+
+	tuple.Relation = auth.RoleName(relation)
+	tuple.Subject, err = ParseSubject(subject)
+	return
+}
+
+// ParseSubject parses a Subject formatted as "kind:id" or "kind:id#relation".
+func ParseSubject(s string) (subject Subject, err error) {
+	object, relation, _ := cut(s, "#")
+	if subject.Object, err = parseObject(object); err != nil {
+		return
+	}
+
+	subject.Relation = auth.RoleName(relation)
+	return
+}
+
+func parseObject(s string) (object Object, err error) {
+	kind, id, ok := cut(s, ":")
+	if !ok || kind == "" || id == "" {
+		err = fmt.Errorf("%w: %q is not of the form kind:id", ErrInvalidTuple, s)
+		return
+	}
+
+	object.Namespace = auth.ResourceKind(kind)
+	object.ID = auth.ResourceID(id)
+	return
+}
+
+// cut slices s around the first instance of sep.
+func cut(s, sep string) (before, after string, ok bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
@@ -27,8 +27,27 @@ func (res resourceImpl) Kind() (kind ResourceKind) {
 	return res.kind
 }
 
+// WildcardResourceID stands for every Resource of a ResourceKind.
+const WildcardResourceID = ResourceID("*")
+
 // PlatformResource is the top level Resource that contains all other Resources.
 var PlatformResource = resourceImpl{
-	id:   ResourceID("*"),
+	id:   WildcardResourceID,
 	kind: ResourceKind("platform"),
 }
+
+// AllOf is a Resource standing for every Resource of the given ResourceKind. Roles upon
+// it satisfy checks for the same Roles upon any Resource of that kind, which allows for
+// admin-style Roles per entity type without resorting to the PlatformResource.
+func AllOf(kind ResourceKind) (resource Resource) {
+	return resourceImpl{
+		id:   WildcardResourceID,
+		kind: kind,
+	}
+}
+
+// IsWildcard checks whether the given Resource stands for every Resource of it's kind.
+func IsWildcard(resource Resource) (ok bool) {
+	ok = resource.Identifier() == WildcardResourceID
+	return
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"sync"
+)
+
+// ResourceParentResolver looks up the parent of a Resource, such as the Account that
+// contains a Campaign. Resolvers are registered per ResourceKind with
+// WithResourceParentResolver, and `ok` is false when the Resource has no parent other
+// than the PlatformResource.
+type ResourceParentResolver interface {
+	Parent(ctx context.Context, resource Resource) (
+		parent Resource, ok bool, err error,
+	)
+}
+
+// ResourceParentResolverFunc adapts an ordinary function into a ResourceParentResolver.
+type ResourceParentResolverFunc func(ctx context.Context, resource Resource) (
+	parent Resource, ok bool, err error,
+)
+
+// Parent calls the underlying function.
+func (fn ResourceParentResolverFunc) Parent(
+	ctx context.Context, resource Resource,
+) (parent Resource, ok bool, err error) {
+	parent, ok, err = fn(ctx, resource)
+	return
+}
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   = make(map[ResourceKind]ResourceParentResolver)
+)
+
+// WithResourceParentResolver registers the ResourceParentResolver for Resources of the
+// given ResourceKind.
+func WithResourceParentResolver(kind ResourceKind, resolver ResourceParentResolver) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+
+	resolvers[kind] = resolver
+}
+
+// resourceKey identifies a Resource by value, regardless of it's underlying type.
+type resourceKey struct {
+	kind ResourceKind
+	id   ResourceID
+}
+
+func keyOf(resource Resource) resourceKey {
+	return resourceKey{resource.Kind(), resource.Identifier()}
+}
+
+// ancestryKey is a non-simple type for the ancestry memo in a context.Context.
+type ancestryKey struct{}
+
+// ancestry memoizes the parents looked up by ResourceParentResolvers.
+type ancestry struct {
+	mu      sync.Mutex
+	parents map[resourceKey]Resource
+	roots   map[resourceKey]bool
+}
+
+func newAncestry() (memo *ancestry) {
+	memo = new(ancestry)
+	memo.parents = make(map[resourceKey]Resource)
+	memo.roots = make(map[resourceKey]bool)
+	return
+}
+
+// WithResolverMemo returns a Context that memoizes parent lookups for the rest of it's
+// lifetime, so that repeated checks against deep hierarchies resolve each Resource's
+// parent only once. Sessions attach it to the Context of every request they Auth, so
+// it's only needed for Contexts of our own, such as those of background jobs.
+func WithResolverMemo(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(ancestryKey{}).(*ancestry); ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, ancestryKey{}, newAncestry())
+}
+
+// Ancestors resolves the chain of parents for the given Resource, starting with the
+// Resource itself and always ending with the PlatformResource. A Resource appearing
+// twice in the chain ends the walk, so cyclic resolvers can't loop forever.
+func Ancestors(ctx context.Context, resource Resource) (chain []Resource, err error) {
+	memo, ok := ctx.Value(ancestryKey{}).(*ancestry)
+	if !ok {
+		memo = newAncestry()
+	}
+
+	seen := make(map[resourceKey]bool)
+	for resource != nil {
+		k := keyOf(resource)
+		if seen[k] || k == keyOf(PlatformResource) {
+			break
+		}
+
+		seen[k] = true
+		chain = append(chain, resource)
+		if resource, err = memo.parent(ctx, resource); err != nil {
+			return
+		}
+	}
+
+	chain = append(chain, PlatformResource)
+	return
+}
+
+// parent looks up the parent of a Resource, consulting the memo first.
+func (memo *ancestry) parent(ctx context.Context, resource Resource) (
+	parent Resource, err error,
+) {
+	k := keyOf(resource)
+
+	memo.mu.Lock()
+	if parent = memo.parents[k]; parent != nil || memo.roots[k] {
+		memo.mu.Unlock()
+		return
+	}
+
+	memo.mu.Unlock()
+
+	resolversMu.RLock()
+	resolver, ok := resolvers[k.kind]
+	resolversMu.RUnlock()
+
+	if ok {
+		if parent, ok, err = resolver.Parent(ctx, resource); err != nil {
+			return
+		}
+	}
+
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+
+	if !ok || parent == nil {
+		parent = nil
+		memo.roots[k] = true
+		return
+	}
+
+	memo.parents[k] = parent
+	return
+}
+
+// Check whether the given User holds the named Role upon the given Resource, any of
+// it's ancestors per the registered ResourceParentResolvers, or the PlatformResource.
+// Roles are expanded with the configured RoleGraph, the whole chain is checked with a
+// single call to `IsUserInAny`, and the configured PolicyEngine is consulted on it's
+// outcome.
+func (repo GroupRepository) Check(
+	ctx context.Context, user User, roleName RoleName, resource Resource,
+) (ok bool, err error) {
+	ok, err = repo.checkAny(ctx, user, "", []RoleName{roleName}, resource)
+	return
+}
+
+// checkAny is Check for any one of many RoleNames, on behalf of an optional Action.
+func (repo GroupRepository) checkAny(
+	ctx context.Context,
+	user User,
+	action Action,
+	names []RoleName,
+	resource Resource,
+) (ok bool, err error) {
+	if ok, err = repo.holdsAny(ctx, user, names, resource); err != nil {
+		return
+	}
+
+	var roles Roles
+	for _, name := range names {
+		roles = append(roles, NewRole(name, resource))
+	}
+
+	ok, err = consult(ctx, PolicyRequest{
+		User:     user,
+		Action:   action,
+		Resource: resource,
+		Roles:    roles,
+		Member:   ok,
+	})
+
+	return
+}
+
+// holdsAny is checkAny without consulting the PolicyEngine, so that PolicyEngines can
+// check role membership themselves.
+func (repo GroupRepository) holdsAny(
+	ctx context.Context, user User, names []RoleName, resource Resource,
+) (ok bool, err error) {
+	var chain []Resource
+	if chain, err = Ancestors(ctx, resource); err != nil {
+		return
+	}
+
+	var roles Roles
+	for _, name := range names {
+		roles = append(roles, RolesFor(name, chain...)...)
+	}
+
+	ok, err = repo.IsUserInAny(ctx, user, roleGraph.Expand(roles...))
+	return
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"sync"
+)
+
+// RoleGraph declares implications between Roles per ResourceKind, such as an "owner"
+// implying an "editor" who in turn implies a "viewer". Implications live in code and are
+// never persisted, so the graph can be reshaped without any migrations upon our Groups.
+// It is opt-in: without a RoleGraph, a Role is only ever satisfied by itself.
+type RoleGraph struct {
+	mu sync.RWMutex
+
+	// implied maps a ResourceKind and a RoleName to the RoleNames that imply it.
+	implied map[ResourceKind]map[RoleName][]RoleName
+}
+
+// NewRoleGraph is a constructor for RoleGraph.
+func NewRoleGraph() (graph *RoleGraph) {
+	graph = new(RoleGraph)
+	graph.implied = make(map[ResourceKind]map[RoleName][]RoleName)
+	return
+}
+
+// Imply declares that holding the Role named `from` upon a Resource of the given
+// ResourceKind also satisfies the Roles named `to`. Implications are transitive, so
+// declaring owner ⇒ editor and editor ⇒ viewer makes an owner satisfy viewer as well.
+func (graph *RoleGraph) Imply(kind ResourceKind, from RoleName, to ...RoleName) {
+	graph.mu.Lock()
+	defer graph.mu.Unlock()
+
+	if _, ok := graph.implied[kind]; !ok {
+		graph.implied[kind] = make(map[RoleName][]RoleName)
+	}
+
+	for _, name := range to {
+		graph.implied[kind][name] = append(graph.implied[kind][name], from)
+	}
+}
+
+// Expand returns the given Roles along with every Role that implies any of them upon the
+// same Resource. Cyclic implications are tolerated, and each Role appears only once.
+func (graph *RoleGraph) Expand(roles ...Role) (expanded Roles) {
+	if graph == nil {
+		expanded = append(expanded, roles...)
+		return
+	}
+
+	graph.mu.RLock()
+	defer graph.mu.RUnlock()
+
+	type key struct {
+		kind ResourceKind
+		id   ResourceID
+		name RoleName
+	}
+
+	seen := make(map[key]bool)
+	queue := append(Roles{}, roles...)
+	for len(queue) > 0 {
+		role := queue[0]
+		queue = queue[1:]
+
+		k := key{role.Resource.Kind(), role.Resource.Identifier(), role.Name}
+		if seen[k] {
+			continue
+		}
+
+		seen[k] = true
+		expanded = append(expanded, role)
+
+		for _, name := range graph.implied[k.kind][role.Name] {
+			queue = append(queue, NewRole(name, role.Resource))
+		}
+	}
+
+	return
+}
+
+var roleGraph *RoleGraph
+
+// WithRoleGraph configures the RoleGraph that `auth` will refer when expanding Roles.
+func WithRoleGraph(graph *RoleGraph) {
+	roleGraph = graph
+}
+
+// IsUserInAnyImplied checks whether the given User has one or more of the given Roles,
+// or any Role that implies them per the RoleGraph configured with WithRoleGraph. The
+// configured PolicyEngine is consulted on it's outcome.
+func (repo GroupRepository) IsUserInAnyImplied(
+	ctx context.Context, user User, roles Roles,
+) (ok bool, err error) {
+	if ok, err = repo.IsUserInAny(ctx, user, roleGraph.Expand(roles...)); err != nil {
+		return
+	}
+
+	ok, err = consult(ctx, rolesRequest(user, roles, ok))
+	return
+}
+
+// IsInAnyImplied is a convenience-method that calls `IsUserInAnyImplied` with the User
+// in the current Context.
+func (repo GroupRepository) IsInAnyImplied(ctx context.Context, roles Roles) (
+	ok bool, err error,
+) {
+	var user User
+	if user, err = FromContext(ctx); err != nil {
+		return
+	}
+
+	ok, err = repo.IsUserInAnyImplied(ctx, user, roles)
+	return
+}
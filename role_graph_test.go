@@ -0,0 +1,104 @@
+package auth_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/authtest"
+)
+
+// namesOf sorts the names of the Roles upon the given Resource.
+func namesOf(roles auth.Roles, resource auth.Resource) (names []string) {
+	for _, role := range roles {
+		if role.Resource == resource {
+			names = append(names, string(role.Name))
+		}
+	}
+
+	sort.Strings(names)
+	return
+}
+
+func TestRoleGraphExpand(t *testing.T) {
+	campaign := testResource{"campaign", "1"}
+	account := testResource{"account", "1"}
+
+	graph := auth.NewRoleGraph()
+	graph.Imply("campaign", "owner", "editor")
+	graph.Imply("campaign", "editor", "viewer")
+	graph.Imply("campaign", "admin", "owner", "viewer")
+	graph.Imply("account", "member", "viewer")
+
+	expanded := graph.Expand(auth.NewRole("viewer", campaign))
+	want := []string{"admin", "editor", "owner", "viewer"}
+	if got := namesOf(expanded, campaign); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expand(viewer) = %v, want %v", got, want)
+	}
+
+	if len(expanded) != len(want) {
+		t.Errorf("Expand(viewer) has %d Roles, want %d", len(expanded), len(want))
+	}
+
+	// Implications are per ResourceKind.
+	expanded = graph.Expand(auth.NewRole("viewer", account))
+	want = []string{"member", "viewer"}
+	if got := namesOf(expanded, account); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expand(account viewer) = %v", got)
+	}
+
+	if got := graph.Expand(auth.NewRole("owner", campaign)); len(got) != 2 {
+		t.Errorf("Expand(owner) = %v, want owner and admin", got)
+	}
+}
+
+func TestRoleGraphCycles(t *testing.T) {
+	campaign := testResource{"campaign", "1"}
+
+	graph := auth.NewRoleGraph()
+	graph.Imply("campaign", "a", "b")
+	graph.Imply("campaign", "b", "c")
+	graph.Imply("campaign", "c", "a")
+
+	got := namesOf(graph.Expand(auth.NewRole("a", campaign)), campaign)
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expand(a) = %v, want %v", got, want)
+	}
+}
+
+func TestNilRoleGraph(t *testing.T) {
+	var graph *auth.RoleGraph
+	roles := auth.Roles{auth.NewRole("viewer", testResource{"campaign", "1"})}
+	if got := graph.Expand(roles...); !reflect.DeepEqual(got, roles) {
+		t.Errorf("Expand = %v, want %v", got, roles)
+	}
+}
+
+func TestIsUserInAnyImplied(t *testing.T) {
+	campaign := testResource{"campaign", "1"}
+	fixture := authtest.Install()
+	alice := authtest.NewUser("alice")
+
+	graph := auth.NewRoleGraph()
+	graph.Imply("campaign", "owner", "viewer")
+	auth.WithRoleGraph(graph)
+	defer auth.WithRoleGraph(nil)
+
+	viewer := auth.Roles{auth.NewRole("viewer", campaign)}
+	if ok, err := fixture.Groups.IsUserInAnyImplied(ctx, alice, viewer); err != nil || ok {
+		t.Fatalf("IsUserInAnyImplied = %v, %v before Add", ok, err)
+	}
+
+	if err := fixture.Groups.Add(ctx, alice, auth.NewRole("owner", campaign)); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := fixture.Groups.IsUserInAnyImplied(ctx, alice, viewer); err != nil || !ok {
+		t.Errorf("IsUserInAnyImplied = %v, %v for an owner", ok, err)
+	}
+
+	if ok, err := fixture.Groups.IsUserInAny(ctx, alice, viewer); err != nil || ok {
+		t.Errorf("IsUserInAny = %v, %v, want implications ignored", ok, err)
+	}
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// scanner is a stand-in for tabular.Scanner that leaves fields untouched for NULL
+// columns just as it does, but scans each row only once. database/sql no longer allows
+// scanning a row again after it was scanned into sql.RawBytes, which tabular.Scanner
+// relies upon to detect NULLs, so it fails with every driver — MySQL's included — and
+// not just SQLite's.
+type scanner struct {
+	fields []interface{}
+}
+
+func newScanner(fields ...interface{}) (s scanner) {
+	s.fields = fields
+	return
+}
+
+// Scan the current row of *sql.Rows into the fields of the scanner.
+func (s scanner) Scan(rows *sql.Rows) (err error) {
+	dests := make([]interface{}, len(s.fields))
+	for i, field := range s.fields {
+		dests[i] = nullable{field}
+	}
+
+	err = rows.Scan(dests...)
+	return
+}
+
+// nullable scans non-NULL values into it's destination, which is either an sql.Scanner
+// or a pointer to a string-kinded type, such as a RoleName.
+type nullable struct {
+	dst interface{}
+}
+
+func (n nullable) Scan(src interface{}) (err error) {
+	if src == nil {
+		return
+	}
+
+	if s, ok := n.dst.(sql.Scanner); ok {
+		err = s.Scan(src)
+		return
+	}
+
+	v := reflect.ValueOf(n.dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.String {
+		err = fmt.Errorf("cannot scan into %T", n.dst)
+		return
+	}
+
+	switch src := src.(type) {
+	case []byte:
+		v.Elem().SetString(string(src))
+	case string:
+		v.Elem().SetString(src)
+	case time.Time:
+		v.Elem().SetString(src.Format(time.RFC3339Nano))
+	default:
+		v.Elem().SetString(fmt.Sprint(src))
+	}
+
+	return
+}
+
+// timestampLayouts are those in which drivers return times as text, such as MySQL's
+// without `parseTime`, and SQLite's.
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	time.RFC3339Nano,
+}
+
+// timestamp scans a time.Time, in UTC, whether the driver returns it as a time.Time or as
+// text.
+type timestamp struct {
+	t *time.Time
+}
+
+func (ts timestamp) Scan(src interface{}) (err error) {
+	var s string
+	switch src := src.(type) {
+	case time.Time:
+		*ts.t = src.UTC()
+		return
+	case []byte:
+		s = string(src)
+	case string:
+		s = src
+	default:
+		err = fmt.Errorf("cannot scan %T into a time", src)
+		return
+	}
+
+	for _, layout := range timestampLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, s, time.UTC); err == nil {
+			*ts.t = t.UTC()
+			return
+		}
+	}
+
+	err = fmt.Errorf("cannot parse %q as a time", s)
+	return
+}
@@ -0,0 +1,59 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/authtest"
+)
+
+// tenantUser is a User of a single tenant.
+type tenantUser struct {
+	authtest.User
+	tenant auth.TenantID
+}
+
+func (user tenantUser) GetTenantID() auth.TenantID {
+	return user.tenant
+}
+
+func TestHTTPSessionTenant(t *testing.T) {
+	auth.ForgetTenantsInUse(t)
+
+	var (
+		alice = authtest.User{ID: "alice", Secret: "secret", IsVerified: true}
+		bob   = tenantUser{authtest.User{ID: "bob", Secret: "secret", IsVerified: true}, "acme"}
+	)
+
+	authtest.Install(alice, bob)
+
+	for _, c := range []struct {
+		id, tenant string
+		want       auth.TenantID
+		err        error
+	}{
+		{"alice", "", "", nil},
+		{"alice", "acme", "", auth.ErrInvalidUserCredentials},
+		{"bob", "", "acme", nil},
+		{"bob", "acme", "acme", nil},
+		{"bob", "globex", "", auth.ErrInvalidUserCredentials},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Auth-User-ID", c.id)
+		req.Header.Set("X-Auth-Secret", "secret")
+		req.Header.Set("X-Auth-Tenant-ID", c.tenant)
+
+		ctx, err := auth.NewHTTPSession(httptest.NewRecorder(), req).Auth()
+		if !errors.Is(err, c.err) {
+			t.Errorf("%s in %q: expected %v, got %v", c.id, c.tenant, c.err, err)
+		} else if err == nil && auth.TenantFromContext(ctx) != c.want {
+			t.Errorf(
+				"%s in %q: expected tenant %q, got %q",
+				c.id, c.tenant, c.want, auth.TenantFromContext(ctx),
+			)
+		}
+	}
+}
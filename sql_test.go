@@ -0,0 +1,80 @@
+package auth_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/grouptest"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+// openDSN opens the database of the DSN in the given environment variable with the given
+// driver, migrated to the Schema and without any rows, and skips the test unless it's
+// set. The database is shared, so such tests mustn't run in parallel. Identifiers are
+// quoted with the given quote, as `groups` is reserved in MySQL.
+func openDSN(
+	t *testing.T, env string, driver string, schema auth.Schema, quote string,
+) (db *sql.DB) {
+	t.Helper()
+
+	dsn := os.Getenv(env)
+	if dsn == "" {
+		t.Skipf("%s is not set", env)
+	}
+
+	var err error
+	if db, err = sql.Open(driver, dsn); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	if err = schema.Migrate(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range []string{"groups", "team_members", "invitations"} {
+		if _, err = db.ExecContext(
+			ctx, "DELETE FROM "+quote+table+quote,
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return
+}
+
+func openMySQL(t *testing.T) *sql.DB {
+	return openDSN(t, "AUTH_TEST_MYSQL_DSN", "mysql", auth.MySQLSchema, "`")
+}
+
+func openPostgres(t *testing.T) *sql.DB {
+	return openDSN(t, "AUTH_TEST_POSTGRES_DSN", "postgres", auth.PostgresSchema, `"`)
+}
+
+func TestGroupMySQLRepository(t *testing.T) {
+	grouptest.Run(t, func(t *testing.T) auth.GroupRepository {
+		repo, err := auth.NewGroupMySQLRepositoryImpl(openMySQL(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return repo
+	})
+}
+
+func TestGroupPostgresRepository(t *testing.T) {
+	grouptest.Run(t, func(t *testing.T) auth.GroupRepository {
+		repo, err := auth.NewGroupPostgresRepositoryImpl(openPostgres(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return repo
+	})
+}
@@ -0,0 +1,210 @@
+package auth_test
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angadn/auth"
+	"github.com/angadn/auth/authtest"
+	"github.com/angadn/auth/grouptest"
+	_ "modernc.org/sqlite"
+)
+
+// openSQLite opens an in-memory SQLite database, closed along with the test. It's
+// limited to a single connection, as each would otherwise get it's own database.
+func openSQLite(t *testing.T) (db *sql.DB) {
+	t.Helper()
+
+	var err error
+	if db, err = sql.Open("sqlite", ":memory:"); err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	return
+}
+
+func TestGroupSQLiteRepository(t *testing.T) {
+	grouptest.Run(t, func(t *testing.T) auth.GroupRepository {
+		repo, err := auth.NewGroupSQLiteRepositoryImpl(openSQLite(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return repo
+	})
+}
+
+func TestGroupSQLiteCache(t *testing.T) {
+	grouptest.Run(t, func(t *testing.T) auth.GroupRepository {
+		repo, err := auth.NewGroupSQLiteRepositoryImpl(openSQLite(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return auth.NewGroupCacheImpl(repo)
+	})
+}
+
+func TestGroupSQLiteInTxResolvesTeams(t *testing.T) {
+	db := openSQLite(t)
+	groups, err := auth.NewGroupSQLiteRepositoryImpl(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	teams, err := auth.NewTeamSQLiteRepositoryImpl(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := auth.Teams
+	auth.WithTeamRepository(teams)
+	t.Cleanup(func() {
+		auth.Teams = prev
+	})
+
+	var (
+		alice = authtest.NewUser("alice")
+		role  = auth.NewRole("editor", testResource{"campaign", "1"})
+	)
+
+	if err = auth.Teams.AddMember(ctx, "t", auth.PrincipalOf(alice)); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tx.Rollback()
+
+	// The transaction holds the only connection, so Teams resolved outside it would wait
+	// upon it forever.
+	txGroups, err := groups.InTx(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = txGroups.AddTeam(ctx, "t", role); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		ok, err := txGroups.IsUserInAny(ctx, alice, auth.Roles{role})
+		if err == nil && !ok {
+			err = fmt.Errorf("expected alice to hold %s through her Team", role.Name)
+		}
+
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("IsUserInAny within InTx waited upon a connection outside it")
+	}
+}
+
+func TestGroupSQLiteCacheEvictsOnCommit(t *testing.T) {
+	// A file rather than memory, so that reads outside the transaction have connections
+	// of their own.
+	db, err := sql.Open(
+		"sqlite", filepath.Join(t.TempDir(), "auth.db")+"?_pragma=busy_timeout(5000)",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	groups, err := auth.NewGroupSQLiteRepositoryImpl(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cache  = auth.NewGroupCacheImpl(groups)
+		alice  = authtest.NewUser("alice")
+		editor = auth.Roles{auth.NewRole("editor", testResource{"campaign", "1"})}
+	)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txCtx := auth.WithTx(ctx, tx)
+	if err = cache.Add(txCtx, alice, editor[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// A check that races the transaction caches what it's yet to commit.
+	if ok, err := cache.IsUserInAny(ctx, alice, editor); err != nil || ok {
+		t.Fatalf("expected alice to lack editor before the commit, got %v, %v", ok, err)
+	}
+
+	if err = auth.Commit(txCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := cache.IsUserInAny(ctx, alice, editor); err != nil || !ok {
+		t.Errorf("expected alice to hold editor once committed, got %v, %v", ok, err)
+	}
+}
+
+func TestSQLiteSchemaDrift(t *testing.T) {
+	db := openSQLite(t)
+	if _, err := auth.NewGroupSQLiteRepositoryImpl(db); err != nil {
+		t.Fatal(err)
+	}
+
+	drift, err := auth.SQLiteSchema.Drift(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(drift) != 0 {
+		t.Errorf("Drift = %v, want none", drift)
+	}
+
+	// Every query filters on `tenant_id`, so it leads each index upon `groups`.
+	rows, err := db.QueryContext(
+		ctx, "SELECT `name`, `sql` FROM `sqlite_master` WHERE `type` = 'index' AND `tbl_name` = 'groups' AND `sql` IS NOT NULL",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, stmt string
+		if err = rows.Scan(&name, &stmt); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(stmt, "(`tenant_id`") {
+			t.Errorf("index %s isn't led by tenant_id: %s", name, stmt)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/angadn/tabular"
+)
+
+// TeamID identifies a Team: a named set of Users and other Teams that can be assigned
+// Roles as a whole, so that granting a Team access to an Account takes a single row no
+// matter how many members it has.
+type TeamID string
+
+// PrincipalType distinguishes the kinds of members a Group can have.
+type PrincipalType string
+
+const (
+	// UserPrincipal is an individual User.
+	UserPrincipal = PrincipalType("user")
+
+	// TeamPrincipal is a Team, whose members transitively inherit it's Roles.
+	TeamPrincipal = PrincipalType("team")
+)
+
+// Principal is anything that can be assigned a Role or belong to a Team.
+type Principal struct {
+	Type PrincipalType
+	ID   string
+}
+
+// PrincipalOf is a convenience-constructor for the Principal of a User.
+func PrincipalOf(user User) (principal Principal) {
+	principal.Type = UserPrincipal
+	principal.ID = user.GetID()
+	return
+}
+
+// Principal is a convenience-constructor for the Principal of a Team.
+func (team TeamID) Principal() (principal Principal) {
+	principal.Type = TeamPrincipal
+	principal.ID = string(team)
+	return
+}
+
+// MaxTeamDepth bounds how deeply Teams may be nested within one another when resolving
+// the Teams a User transitively belongs to.
+var MaxTeamDepth = 8
+
+// ErrTeamTooDeep when Teams are nested deeper than MaxTeamDepth.
+var ErrTeamTooDeep = fmt.Errorf("teams nested too deeply")
+
+// teamTable is a tabular representation of Team memberships, and helps us persist them
+// in an SQL database.
+var teamTable = tabular.New(
+	"team_members",
+
+	"tenant_id",
+	"team_id",
+	"member_type",
+	"member_id",
+	"created_at",
+	"updated_at",
+)
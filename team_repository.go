@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/angadn/tabular"
+)
+
+// Teams exposes our internal TeamRepository as a public API for our business layer.
+var Teams TeamRepository
+
+// WithTeamRepository configures the TeamRepository implementation that `auth` will refer.
+func WithTeamRepository(r TeamRepository) {
+	Teams = r
+}
+
+// TeamRepositoryImpl defines an interface with which we can persist Team memberships.
+// Every method reads and writes within the tenant that it's Context is scoped to alone.
+type TeamRepositoryImpl interface {
+	AddMember(ctx context.Context, team TeamID, member Principal) (err error)
+	RemoveMember(ctx context.Context, team TeamID, member Principal) (err error)
+	Members(ctx context.Context, team TeamID) (members []Principal, err error)
+	TeamsOf(ctx context.Context, members ...Principal) (teams []TeamID, err error)
+}
+
+// TeamRepository persists our Team memberships using an underlying TeamRepositoryImpl.
+type TeamRepository struct {
+	TeamRepositoryImpl
+}
+
+// Resolve lists every Team the given User belongs to, directly or through other Teams.
+// Cyclic memberships are tolerated, but nesting deeper than MaxTeamDepth fails with
+// ErrTeamTooDeep. Without a TeamRepositoryImpl configured, a User belongs to no Teams.
+func (repo TeamRepository) Resolve(ctx context.Context, user User) (
+	teams []TeamID, err error,
+) {
+	if repo.TeamRepositoryImpl == nil {
+		return
+	}
+
+	var (
+		seen     = make(map[TeamID]bool)
+		frontier = []Principal{PrincipalOf(user)}
+	)
+
+	// Teams at MaxTeamDepth are still looked up, but only fail if they're within Teams
+	// that we haven't already seen.
+	for depth := 0; len(frontier) > 0; depth++ {
+		var direct []TeamID
+		if direct, err = repo.TeamsOf(ctx, frontier...); err != nil {
+			return
+		}
+
+		frontier = nil
+		for _, team := range direct {
+			if seen[team] {
+				continue
+			}
+
+			if depth == MaxTeamDepth {
+				err = ErrTeamTooDeep
+				return
+			}
+
+			seen[team] = true
+			teams = append(teams, team)
+			frontier = append(frontier, team.Principal())
+		}
+	}
+
+	return
+}
+
+// AddMember adds a User or another Team to a Team, evicting the member's decisions if
+// `Groups` caches them, per memberInvalidation.
+func (repo TeamRepository) AddMember(
+	ctx context.Context, team TeamID, member Principal,
+) (err error) {
+	err = repo.TeamRepositoryImpl.AddMember(ctx, team, member)
+	Groups.invalidate(ctx, memberInvalidation(ctx, member))
+	return
+}
+
+// RemoveMember removes a User or another Team from a Team, evicting the member's
+// decisions if `Groups` caches them, per memberInvalidation.
+func (repo TeamRepository) RemoveMember(
+	ctx context.Context, team TeamID, member Principal,
+) (err error) {
+	err = repo.TeamRepositoryImpl.RemoveMember(ctx, team, member)
+	Groups.invalidate(ctx, memberInvalidation(ctx, member))
+	return
+}
+
+// memberInvalidation names the decisions that a change to a Team's member may affect:
+// those of a User, or every one of the tenant for a Team, as it's Users aren't known
+// without resolving them.
+func memberInvalidation(ctx context.Context, member Principal) (inv Invalidation) {
+	if inv.Tenant = TenantFromContext(ctx); member.Type == UserPrincipal {
+		inv.User = member.ID
+	}
+
+	return
+}
+
+// teamSQLRepository implements TeamRepository in SQL, per it's dialect.
+type teamSQLRepository struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// TeamMySQLRepository implements TeamRepository in MySQL.
+type TeamMySQLRepository struct {
+	teamSQLRepository
+}
+
+// NewTeamMySQLRepositoryImpl is a constructor for TeamMySQLRepository.
+func NewTeamMySQLRepositoryImpl(db *sql.DB) (repo TeamRepository, err error) {
+	mysqlRepo := new(TeamMySQLRepository)
+	mysqlRepo.db = db
+	mysqlRepo.dialect = mysqlDialect{}
+	repo.TeamRepositoryImpl = mysqlRepo
+	err = mysqlRepo.db.Ping()
+	return
+}
+
+// teamKey is the unique key of our table, which `AddMember` depends upon to be
+// idempotent.
+var teamKey = []string{"tenant_id", "team_id", "member_type", "member_id"}
+
+func (repo *teamSQLRepository) exec(
+	ctx context.Context, query string, args ...interface{},
+) (sql.Result, error) {
+	return connOf(ctx, repo.db).ExecContext(ctx, repo.dialect.rebind(query), args...)
+}
+
+func (repo *teamSQLRepository) query(
+	ctx context.Context, query string, args ...interface{},
+) (*sql.Rows, error) {
+	return connOf(ctx, repo.db).QueryContext(ctx, repo.dialect.rebind(query), args...)
+}
+
+// AddMember adds a User or another Team to a Team. AddMember is an idempotent action.
+func (repo *teamSQLRepository) AddMember(
+	ctx context.Context, team TeamID, member Principal,
+) (err error) {
+	_, err = repo.exec(ctx, teamTable.Insertion(
+		"%s "+repo.dialect.upsert(teamKey, "updated_at"),
+		"created_at", "CURRENT_TIMESTAMP",
+		"updated_at", "CURRENT_TIMESTAMP",
+	),
+		string(TenantFromContext(ctx)),
+		string(team),
+		string(member.Type),
+		member.ID,
+	)
+
+	return
+}
+
+// RemoveMember removes a User or another Team from a Team.
+func (repo *teamSQLRepository) RemoveMember(
+	ctx context.Context, team TeamID, member Principal,
+) (err error) {
+	_, err = repo.exec(
+		ctx,
+		"DELETE FROM `team_members` WHERE `tenant_id` = ? AND `team_id` = ? AND `member_type` = ? AND `member_id` = ?",
+		string(TenantFromContext(ctx)),
+		string(team),
+		string(member.Type),
+		member.ID,
+	)
+
+	return
+}
+
+// Members lists the direct members of a Team.
+func (repo *teamSQLRepository) Members(ctx context.Context, team TeamID) (
+	members []Principal, err error,
+) {
+	var rows *sql.Rows
+	if rows, err = repo.query(ctx, teamTable.Selection(
+		"SELECT %s FROM `team_members` WHERE `team_members`.`tenant_id` = ? AND `team_members`.`team_id` = ?",
+	),
+		string(TenantFromContext(ctx)),
+		string(team),
+	); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var member Principal
+		if err = newScanner(
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+			&member.Type,
+			&member.ID,
+			&tabular.Scapegoat{},
+			&tabular.Scapegoat{},
+		).Scan(rows); err != nil {
+			return
+		}
+
+		members = append(members, member)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// TeamsOf lists the Teams that any of the given Principals directly belong to.
+func (repo *teamSQLRepository) TeamsOf(
+	ctx context.Context, members ...Principal,
+) (teams []TeamID, err error) {
+	if len(members) == 0 {
+		return
+	}
+
+	args := []interface{}{string(TenantFromContext(ctx))}
+	for _, m := range members {
+		args = append(args, string(m.Type), m.ID)
+	}
+
+	var rows *sql.Rows
+	if rows, err = repo.query(ctx, fmt.Sprintf(
+		"SELECT DISTINCT `team_members`.`team_id` FROM `team_members` WHERE `team_members`.`tenant_id` = ? AND (%s)",
+		strings.TrimRight(strings.Repeat(
+			"(`team_members`.`member_type` = ? AND `team_members`.`member_id` = ?) OR", len(members),
+		), " OR"),
+	), args...); err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var team TeamID
+		if err = rows.Scan(&team); err != nil {
+			return
+		}
+
+		teams = append(teams, team)
+	}
+
+	err = rows.Err()
+	return
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// TenantID identifies a tenant: an isolated set of Users, Groups and Teams that shares
+// it's database with others. Every GroupRepository and TeamRepository method reads and
+// writes within the tenant in it's Context alone, so that even a Role upon the
+// PlatformResource only grants access within it's tenant. The zero TenantID is the
+// default tenant, which single-tenant deployments never need to leave.
+type TenantID string
+
+// tenantKey is a non-simple type for the TenantID in a context.Context.
+type tenantKey struct{}
+
+// tenantsInUse is set once any tenant but the default one is named, with WithTenant or
+// ConfigTenantMaster, after which a Query must name it's tenant.
+var tenantsInUse int32
+
+// TenantsInUse checks whether any tenant but the default one has been named, with
+// WithTenant or ConfigTenantMaster.
+func TenantsInUse() (ok bool) {
+	ok = atomic.LoadInt32(&tenantsInUse) == 1
+	return
+}
+
+func useTenant(tenant TenantID) {
+	if tenant != "" {
+		atomic.StoreInt32(&tenantsInUse, 1)
+	}
+}
+
+// WithTenant returns a Context scoped to the given tenant. Sessions scope their Contexts
+// to the tenant of the request before looking it's User up in our Repository, which may
+// in turn use `TenantFromContext`.
+func WithTenant(ctx context.Context, tenant TenantID) context.Context {
+	useTenant(tenant)
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant that the Context is scoped to, which is the
+// default tenant unless it was scoped `WithTenant`.
+func TenantFromContext(ctx context.Context) (tenant TenantID) {
+	tenant, _ = ctx.Value(tenantKey{}).(TenantID)
+	return
+}
+
+// TenantUser is a User that belongs to a single tenant. Sessions refuse it's credentials
+// for requests to any other tenant, and scope requests that don't name a tenant to it's
+// own.
+type TenantUser interface {
+	User
+	GetTenantID() TenantID
+}
+
+var (
+	tenantMastersMu sync.RWMutex
+	tenantMasters   = make(map[TenantID]User)
+)
+
+// ConfigTenantMaster sets the details for the user that has access to everything within
+// the given tenant, and nothing outside it. The Master configured with ConfigMaster
+// retains access to every tenant.
+func ConfigTenantMaster(tenant TenantID, id string, secret string) {
+	useTenant(tenant)
+
+	tenantMastersMu.Lock()
+	defer tenantMastersMu.Unlock()
+
+	tenantMasters[tenant] = userImpl{
+		id:     id,
+		secret: secret,
+	}
+}
+
+// IsTenantMaster checks whether the credentials are those of the given tenant's master.
+func IsTenantMaster(tenant TenantID, id, secret string) (ok bool) {
+	tenantMastersMu.RLock()
+	master, isSet := tenantMasters[tenant]
+	tenantMastersMu.RUnlock()
+
+	ok = isSet && id == master.GetID() && secret == master.GetSecret()
+	return
+}
+
+// IsMasterIn checks whether the User is the Master, or the master of the tenant that the
+// Context is scoped to, either of whom bypass all checks.
+func IsMasterIn(ctx context.Context, user User) (ok bool) {
+	if ok = IsMaster(user.GetID(), user.GetSecret()); ok {
+		return
+	}
+
+	ok = IsTenantMaster(TenantFromContext(ctx), user.GetID(), user.GetSecret())
+	return
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrTxUnsupported when a GroupRepositoryImpl can't run within an *sql.Tx.
+	ErrTxUnsupported = fmt.Errorf("transactions unsupported")
+
+	// ErrNoTx when committing or rolling back a Context that carries no transaction.
+	ErrNoTx = fmt.Errorf("no transaction in context")
+)
+
+// txKey is a non-simple type for the txState in a context.Context.
+type txKey struct{}
+
+// txState is the transaction that a Context carries, along with the functions to run
+// once it commits.
+type txState struct {
+	tx *sql.Tx
+
+	mu          sync.Mutex
+	afterCommit []func()
+}
+
+// WithTx returns a Context that carries the caller's transaction, within which our SQL
+// repositories run every method that they're called upon with it, so that creating a
+// Resource and granting it's owner a Role either commit together or not at all. The
+// transaction must be of the same database as the repositories, and isn't committed or
+// rolled back by them. Commit it with `Commit` rather than `tx.Commit`, so that caches
+// evict what it wrote once it's visible to others.
+func WithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok && state.tx == tx {
+		return ctx
+	}
+
+	return context.WithValue(ctx, txKey{}, &txState{tx: tx})
+}
+
+// TxFromContext returns the transaction that the Context carries, if any.
+func TxFromContext(ctx context.Context) (tx *sql.Tx, ok bool) {
+	var state *txState
+	if state, ok = ctx.Value(txKey{}).(*txState); ok {
+		tx = state.tx
+	}
+
+	ok = ok && tx != nil
+	return
+}
+
+// AfterCommit registers a function to run once the transaction that the Context carries
+// commits with `Commit`, returning false without registering it if there's none.
+func AfterCommit(ctx context.Context, fn func()) (ok bool) {
+	var state *txState
+	if state, ok = ctx.Value(txKey{}).(*txState); !ok || state.tx == nil {
+		ok = false
+		return
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.afterCommit = append(state.afterCommit, fn)
+	return
+}
+
+// Commit the transaction that the Context carries, and then run the functions registered
+// with AfterCommit, failing with ErrNoTx if there's none.
+func Commit(ctx context.Context) (err error) {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok || state.tx == nil {
+		err = ErrNoTx
+		return
+	}
+
+	if err = state.tx.Commit(); err != nil {
+		return
+	}
+
+	state.mu.Lock()
+	afterCommit := state.afterCommit
+	state.afterCommit = nil
+	state.mu.Unlock()
+
+	for _, fn := range afterCommit {
+		fn()
+	}
+
+	return
+}
+
+// Rollback the transaction that the Context carries, discarding the functions registered
+// with AfterCommit, failing with ErrNoTx if there's none.
+func Rollback(ctx context.Context) (err error) {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok || state.tx == nil {
+		err = ErrNoTx
+		return
+	}
+
+	state.mu.Lock()
+	state.afterCommit = nil
+	state.mu.Unlock()
+
+	err = state.tx.Rollback()
+	return
+}
+
+// conn is what our SQL repositories' queries run upon: either an *sql.DB or an *sql.Tx.
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// connOf is the transaction that the Context carries, if any, or else the database.
+func connOf(ctx context.Context, db *sql.DB) conn {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+
+	return db
+}
+
+// databaseImpl is implemented by repositories that persist to an *sql.DB, so that others
+// can tell whether they may share it's transactions.
+type databaseImpl interface {
+	database() *sql.DB
+}
+
+// databaseOf is the *sql.DB that a repository persists to, looking through the
+// GroupRepository and InvitationRepository that decorate it, or nil if there's none.
+func databaseOf(repo interface{}) (db *sql.DB) {
+	switch repo := repo.(type) {
+	case GroupRepository:
+		db = databaseOf(repo.GroupRepositoryImpl)
+	case InvitationRepository:
+		db = databaseOf(repo.InvitationRepositoryImpl)
+	case databaseImpl:
+		db = repo.database()
+	}
+
+	return
+}
+
+// sameDatabase checks whether both repositories persist to the same *sql.DB.
+func sameDatabase(a, b interface{}) (ok bool) {
+	db := databaseOf(a)
+	ok = db != nil && db == databaseOf(b)
+	return
+}
+
+func (repo *groupSQLRepository) database() *sql.DB {
+	return repo.db
+}
+
+func (repo *invitationSQLRepository) database() *sql.DB {
+	return repo.db
+}
+
+// TxRepositoryImpl is implemented by GroupRepositoryImpls that can run within an *sql.Tx
+// of the caller's.
+type TxRepositoryImpl interface {
+	InTx(tx *sql.Tx) GroupRepositoryImpl
+}
+
+// InTx returns a GroupRepository that runs every method within the given transaction, so
+// that Groups commit atomically with the caller's own writes, failing with
+// ErrTxUnsupported unless it's GroupRepositoryImpl is a TxRepositoryImpl. Our SQL
+// repositories are, as long as the transaction is of their own database.
+func (repo GroupRepository) InTx(tx *sql.Tx) (txRepo GroupRepository, err error) {
+	impl, ok := repo.GroupRepositoryImpl.(TxRepositoryImpl)
+	if !ok {
+		err = ErrTxUnsupported
+		return
+	}
+
+	txRepo.GroupRepositoryImpl = impl.InTx(tx)
+	return
+}
+
+// InTx returns a copy of the repository that runs within the given transaction.
+func (repo *groupSQLRepository) InTx(tx *sql.Tx) GroupRepositoryImpl {
+	txRepo := *repo
+	txRepo.tx = tx
+	return &txRepo
+}
+
+// conn is the transaction that the repository runs within, if any, or that of the
+// Context, or else it's database.
+func (repo *groupSQLRepository) conn(ctx context.Context) conn {
+	if repo.tx != nil {
+		return repo.tx
+	}
+
+	return connOf(ctx, repo.db)
+}
+
+// txContext is the Context carrying the repository's transaction, if any, for the other
+// repositories that it's methods call upon, such as `Teams`.
+func (repo *groupSQLRepository) txContext(ctx context.Context) context.Context {
+	if repo.tx != nil {
+		return WithTx(ctx, repo.tx)
+	}
+
+	return ctx
+}
+
+// transact runs the function within the repository's transaction or that of the
+// Context, or else within one of it's own that it commits unless the function fails.
+func (repo *groupSQLRepository) transact(
+	ctx context.Context, fn func(txRepo *groupSQLRepository) error,
+) (err error) {
+	if _, ok := TxFromContext(ctx); ok || repo.tx != nil {
+		err = fn(repo)
+		return
+	}
+
+	var tx *sql.Tx
+	if tx, err = repo.db.BeginTx(ctx, nil); err != nil {
+		return
+	}
+
+	if err = fn(repo.InTx(tx).(*groupSQLRepository)); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
@@ -15,6 +15,13 @@ type User interface {
 	GetIsVerified() bool
 }
 
+// EmailUser is a User with an email address, such as the one that an Invitation must be
+// sent to for her to accept it.
+type EmailUser interface {
+	User
+	GetEmail() string
+}
+
 // RBACUser provides stubs for User#Secret and User#IsVerified as RBAC-implementations
 // often do not maintain these values as part of their business logic, but instead
 // delegate it to their RBAC system.